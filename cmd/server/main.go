@@ -3,15 +3,39 @@ package main
 import (
 	"flag"
 	"log"
+	"net/http"
+	"strings"
 
 	"github.com/Shaso41/Backend-SystemFocus/internal/server"
 )
 
 func main() {
-	// Parse command-line flags
-	address := flag.String("addr", ":6379", "Server address (host:port)")
+	// Parse command-line flags: a configuration file plus one flag for each
+	// configuration parameter, which overrides the file
+	configFile := flag.String("config", "", "redis.conf-style configuration file")
+	metricsAddr := flag.String("metrics-addr", "", "Serve Prometheus metrics over HTTP at /metrics on this address")
+	values := make(map[string]*string)
+	for _, param := range server.Params() {
+		values[param.Name] = flag.String(param.Name, param.Default, param.Doc)
+	}
 	flag.Parse()
 
+	var opts []server.Option
+	if *configFile != "" {
+		opts = append(opts, server.WithConfigFile(*configFile))
+	}
+	flag.Visit(func(f *flag.Flag) {
+		value, ok := values[f.Name]
+		if !ok {
+			return
+		}
+		if f.Name == "addr" {
+			// Addresses may also be separated by commas on the command line
+			*value = strings.ReplaceAll(*value, ",", " ")
+		}
+		opts = append(opts, server.WithConfigValue(f.Name, *value))
+	})
+
 	// ASCII art banner
 	banner := `
 ╔═══════════════════════════════════════════════════════════╗
@@ -31,7 +55,19 @@ func main() {
 	log.Println(banner)
 
 	// Create and start server
-	srv := server.New(*address)
+	srv := server.New(":6379", opts...)
+
+	if *metricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", srv.MetricsHandler())
+		go func() {
+			log.Printf("📈 Serving metrics on http://%s/metrics", *metricsAddr)
+			if err := http.ListenAndServe(*metricsAddr, mux); err != nil {
+				log.Fatalf("Failed to serve metrics: %v", err)
+			}
+		}()
+	}
+
 	if err := srv.Start(); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
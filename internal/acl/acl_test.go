@@ -0,0 +1,279 @@
+package acl
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testCommands = map[string][]string{
+	"get":         {"read", "string", "fast"},
+	"set":         {"write", "string", "slow"},
+	"del":         {"keyspace", "write", "slow"},
+	"keys":        {"keyspace", "read", "slow", "dangerous"},
+	"acl|whoami":  {"slow"},
+	"acl|setuser": {"admin", "slow", "dangerous"},
+}
+
+func TestACL_DefaultUser(t *testing.T) {
+	a := New(testCommands)
+
+	assert.True(t, a.NoPass(DefaultUser))
+	assert.True(t, a.Authenticate(DefaultUser, "anything"))
+	_, _, ok := a.Check(DefaultUser, "keys", nil, nil)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"user default on nopass ~* &* +@all"}, a.List())
+}
+
+func TestACL_RequirePass(t *testing.T) {
+	a := New(testCommands)
+	assert.NoError(t, a.SetUser(DefaultUser, "resetpass", ">secret"))
+
+	assert.False(t, a.NoPass(DefaultUser))
+	assert.False(t, a.Authenticate(DefaultUser, "wrong"))
+	assert.True(t, a.Authenticate(DefaultUser, "secret"))
+}
+
+func TestACL_NewUserIsDisabled(t *testing.T) {
+	a := New(testCommands)
+	assert.NoError(t, a.SetUser("alice"))
+
+	assert.False(t, a.Authenticate("alice", ""))
+	assert.Equal(t, "user alice off -@all", a.List()[0])
+
+	_, _, ok := a.Check("alice", "get", nil, nil)
+	assert.False(t, ok)
+}
+
+func TestACL_CategoriesAndCommands(t *testing.T) {
+	a := New(testCommands)
+	assert.NoError(t, a.SetUser("alice", "on", ">pw", "+@all", "-@dangerous", "-set"))
+
+	_, _, ok := a.Check("alice", "get", nil, nil)
+	assert.True(t, ok)
+
+	reason, object, ok := a.Check("alice", "keys", nil, nil)
+	assert.False(t, ok)
+	assert.Equal(t, ReasonCommand, reason)
+	assert.Equal(t, "keys", object)
+
+	_, _, ok = a.Check("alice", "set", nil, nil)
+	assert.False(t, ok)
+
+	_, _, ok = a.Check("alice", "acl", []byte("WHOAMI"), nil)
+	assert.True(t, ok)
+	_, object, ok = a.Check("alice", "acl", []byte("SETUSER"), nil)
+	assert.False(t, ok)
+	assert.Equal(t, "acl|setuser", object)
+
+	info, _ := a.GetUser("alice")
+	assert.Equal(t, "+@all -@dangerous -set", info.Commands)
+}
+
+func TestACL_Subcommands(t *testing.T) {
+	a := New(testCommands)
+	assert.NoError(t, a.SetUser("bob", "on", "nopass", "+acl|whoami"))
+
+	_, _, ok := a.Check("bob", "acl", []byte("whoami"), nil)
+	assert.True(t, ok)
+	_, _, ok = a.Check("bob", "acl", []byte("setuser"), nil)
+	assert.False(t, ok)
+
+	assert.NoError(t, a.SetUser("bob", "-acl", "+acl"))
+	_, _, ok = a.Check("bob", "acl", []byte("setuser"), nil)
+	assert.True(t, ok)
+}
+
+func TestACL_ContainerRunWithoutSubcommand(t *testing.T) {
+	a := New(map[string][]string{
+		"command":      {"slow", "connection"},
+		"command|info": {"slow", "connection"},
+		"get":          {"read", "string", "fast"},
+	})
+	assert.NoError(t, a.SetUser("bob", "on", "nopass", "+command"))
+
+	_, _, ok := a.Check("bob", "command", nil, nil)
+	assert.True(t, ok)
+	_, _, ok = a.Check("bob", "command", []byte("info"), nil)
+	assert.True(t, ok)
+
+	assert.NoError(t, a.SetUser("bob", "-command", "+command|info"))
+	reason, object, ok := a.Check("bob", "command", nil, nil)
+	assert.False(t, ok)
+	assert.Equal(t, ReasonCommand, reason)
+	assert.Equal(t, "command", object)
+}
+
+func TestACL_KeyPatterns(t *testing.T) {
+	a := New(testCommands)
+	assert.NoError(t, a.SetUser("cache", "on", "nopass", "~cache:*", "+@read"))
+
+	_, _, ok := a.Check("cache", "get", nil, [][]byte{[]byte("cache:1")})
+	assert.True(t, ok)
+
+	reason, object, ok := a.Check("cache", "get", nil, [][]byte{[]byte("session:1")})
+	assert.False(t, ok)
+	assert.Equal(t, ReasonKey, reason)
+	assert.Equal(t, "session:1", object)
+
+	assert.NoError(t, a.SetUser("cache", "allkeys"))
+	_, _, ok = a.Check("cache", "get", nil, [][]byte{[]byte("session:1")})
+	assert.True(t, ok)
+
+	// A pattern after all keys has no effect, so it is refused
+	assert.NoError(t, a.SetUser("cache", "~*"))
+	assert.EqualError(t, a.SetUser("cache", "~cache:*"),
+		"Error in ACL SETUSER modifier '~cache:*': Adding a pattern after the * pattern (or the 'allkeys' flag) is not valid and does not have any effect. Try 'resetkeys' to start with an empty list of patterns")
+	assert.NoError(t, a.SetUser("cache", "resetkeys", "~cache:*"))
+	info, _ := a.GetUser("cache")
+	assert.Equal(t, "~cache:*", info.Keys)
+}
+
+func TestACL_ChannelPatterns(t *testing.T) {
+	a := New(testCommands)
+	assert.NoError(t, a.SetUser("pub", "on", "&news.*"))
+
+	assert.True(t, a.CheckChannel("pub", "news.sport"))
+	assert.False(t, a.CheckChannel("pub", "chat"))
+	assert.True(t, a.CheckChannel(DefaultUser, "chat"))
+
+	// Channels are described like keys, and left out like them when empty
+	info, _ := a.GetUser("pub")
+	assert.Equal(t, "", info.Keys)
+	assert.Equal(t, "&news.*", info.Channels)
+	assert.NoError(t, a.SetUser("pub", "resetchannels", "~k"))
+	assert.Equal(t, "user default on nopass ~* &* +@all", a.List()[0])
+	assert.Equal(t, "user pub on ~k -@all", a.List()[1])
+
+	assert.EqualError(t, a.SetUser("pub", "allchannels", "&chat"),
+		"Error in ACL SETUSER modifier '&chat': Adding a pattern after the * pattern (or the 'allchannels' flag) is not valid and does not have any effect. Try 'resetchannels' to start with an empty list of channels")
+	assert.False(t, a.CheckChannel("pub", "chat"))
+}
+
+func TestACL_PasswordHashes(t *testing.T) {
+	a := New(testCommands)
+	hash := hashPassword("hunter2")
+
+	assert.NoError(t, a.SetUser("carol", "on", "#"+hash))
+	assert.True(t, a.Authenticate("carol", "hunter2"))
+
+	info, _ := a.GetUser("carol")
+	assert.Equal(t, []string{hash}, info.Passwords)
+
+	assert.NoError(t, a.SetUser("carol", "<hunter2"))
+	assert.False(t, a.Authenticate("carol", "hunter2"))
+
+	assert.EqualError(t, a.SetUser("carol", "#abc"),
+		"Error in ACL SETUSER modifier '#abc': The password hash must be exactly 64 characters and contain only lowercase hexadecimal characters")
+	assert.EqualError(t, a.SetUser("carol", "<nope"),
+		"Error in ACL SETUSER modifier '<nope': The password you are trying to remove from the user does not exist")
+}
+
+func TestACL_SetUserIsAtomic(t *testing.T) {
+	a := New(testCommands)
+	assert.NoError(t, a.SetUser("dave", "on", "nopass"))
+
+	err := a.SetUser("dave", "off", "+nosuchcommand")
+	assert.EqualError(t, err, "Error in ACL SETUSER modifier '+nosuchcommand': Unknown command or category name in ACL")
+
+	info, _ := a.GetUser("dave")
+	assert.Equal(t, []string{"on", "nopass"}, info.Flags)
+}
+
+func TestACL_DelUser(t *testing.T) {
+	a := New(testCommands)
+	assert.NoError(t, a.SetUser("erin"))
+
+	_, err := a.DelUser(DefaultUser)
+	assert.Error(t, err)
+
+	n, err := a.DelUser("erin", "nobody")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	reason, _, ok := a.Check("erin", "get", nil, nil)
+	assert.False(t, ok)
+	assert.Equal(t, ReasonAuth, reason)
+}
+
+func TestACL_CategoryCommands(t *testing.T) {
+	a := New(testCommands)
+
+	names, ok := a.CategoryCommands("dangerous")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"acl|setuser", "keys"}, names)
+
+	_, ok = a.CategoryCommands("nope")
+	assert.False(t, ok)
+}
+
+func TestACL_Log(t *testing.T) {
+	a := New(testCommands)
+
+	a.LogDenial(ReasonCommand, "toplevel", "get", "alice", "id=1")
+	a.LogDenial(ReasonKey, "toplevel", "secret", "alice", "id=1")
+	a.LogDenial(ReasonCommand, "toplevel", "get", "alice", "id=2")
+
+	entries := a.Log(-1)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "get", entries[0].Object)
+	assert.Equal(t, 2, entries[0].Count)
+	assert.Equal(t, "id=2", entries[0].ClientInfo)
+	assert.Equal(t, "secret", entries[1].Object)
+
+	assert.Len(t, a.Log(1), 1)
+
+	a.ResetLog()
+	assert.Empty(t, a.Log(-1))
+}
+
+func TestACL_LogIsBounded(t *testing.T) {
+	a := New(testCommands)
+	for i := 0; i < maxLogEntries+10; i++ {
+		a.LogDenial(ReasonKey, "toplevel", string(rune('a'+i%26))+string(rune('0'+i/26)), "alice", "")
+	}
+	assert.Len(t, a.Log(-1), maxLogEntries)
+}
+
+func TestACL_LoadAndSaveFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.acl")
+	content := "# users\n" +
+		"user default on >secret ~* &* +@all\n" +
+		"\n" +
+		"user cache on nopass ~cache:* -@all +get\n"
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	a := New(testCommands)
+	assert.NoError(t, a.LoadFile(path))
+	assert.Equal(t, []string{"cache", "default"}, a.Users())
+	assert.True(t, a.Authenticate(DefaultUser, "secret"))
+
+	_, _, ok := a.Check("cache", "get", nil, [][]byte{[]byte("cache:x")})
+	assert.True(t, ok)
+
+	assert.NoError(t, a.SetUser("extra", "on", "nopass"))
+	assert.NoError(t, a.Save())
+
+	b := New(testCommands)
+	assert.NoError(t, b.LoadFile(path))
+	assert.Equal(t, a.List(), b.List())
+}
+
+func TestACL_LoadFileErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.acl")
+	assert.NoError(t, os.WriteFile(path, []byte("user alice on +nosuch\n"), 0o600))
+
+	a := New(testCommands)
+	assert.NoError(t, a.SetUser("bob"))
+
+	err := a.LoadFile(path)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), ":1: ")
+
+	// Users are unchanged after a failed load
+	assert.Equal(t, []string{"bob", "default"}, a.Users())
+
+	assert.ErrorIs(t, New(testCommands).Load(), ErrNoFile)
+}
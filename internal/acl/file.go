@@ -0,0 +1,107 @@
+package acl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNoFile is returned by Load and Save when no ACL file is configured
+var ErrNoFile = errors.New("This Redis instance is not configured to use an ACL file")
+
+// LoadFile loads users from the ACL file at path and remembers the path
+// for later Load and Save calls
+func (a *ACL) LoadFile(path string) error {
+	a.mu.Lock()
+	a.file = path
+	a.mu.Unlock()
+
+	return a.Load()
+}
+
+// Load replaces all users with those of the configured ACL file. Each line
+// reads "user <name> <rules...>"; blank lines and lines starting with # are
+// ignored. The current default user is kept if the file does not define
+// one. On error the current users are kept.
+func (a *ACL) Load() error {
+	a.mu.RLock()
+	path := a.file
+	a.mu.RUnlock()
+	if path == "" {
+		return ErrNoFile
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	users := make(map[string]*user)
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if fields[0] != "user" || len(fields) < 2 {
+			return fmt.Errorf("%s:%d: line should start with user keyword", path, n)
+		}
+
+		name := fields[1]
+		if _, dup := users[name]; dup {
+			return fmt.Errorf("%s:%d: duplicate user '%s' found", path, n, name)
+		}
+		u := newUser(name)
+		for _, rule := range fields[2:] {
+			if err := a.apply(u, rule); err != nil {
+				return fmt.Errorf("%s:%d: Error in applying operation '%s': %v", path, n, rule, err)
+			}
+		}
+		users[name] = u
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	if _, ok := users[DefaultUser]; !ok {
+		users[DefaultUser] = a.users[DefaultUser]
+	}
+	a.users = users
+	a.mu.Unlock()
+	return nil
+}
+
+// Save writes all users to the configured ACL file, replacing it
+// atomically
+func (a *ACL) Save() error {
+	a.mu.RLock()
+	path := a.file
+	a.mu.RUnlock()
+	if path == "" {
+		return ErrNoFile
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".acl-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	for _, line := range a.List() {
+		w.WriteString(line + "\n")
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
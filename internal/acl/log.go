@@ -0,0 +1,83 @@
+package acl
+
+import "time"
+
+// maxLogEntries is the number of refused requests ACL LOG remembers
+const maxLogEntries = 128
+
+// logGroupWindow is how long a refusal is merged into an identical earlier
+// entry instead of being logged again
+const logGroupWindow = 60 * time.Second
+
+// LogEntry records a refused request, or a series of identical ones
+type LogEntry struct {
+	Count      int
+	Reason     string
+	Context    string
+	Object     string
+	Username   string
+	ClientInfo string
+	Created    time.Time
+	Updated    time.Time
+}
+
+// LogDenial records a refused request. A refusal identical to a recent
+// entry only bumps that entry's count.
+func (a *ACL) LogDenial(reason, context, object, username, clientInfo string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	for i, e := range a.log {
+		if e.Reason == reason && e.Context == context && e.Object == object &&
+			e.Username == username && now.Sub(e.Updated) < logGroupWindow {
+			e.Count++
+			e.Updated = now
+			e.ClientInfo = clientInfo
+			// Keep the log ordered by last update, newest first
+			copy(a.log[1:i+1], a.log[:i])
+			a.log[0] = e
+			return
+		}
+	}
+
+	entry := &LogEntry{
+		Count:      1,
+		Reason:     reason,
+		Context:    context,
+		Object:     object,
+		Username:   username,
+		ClientInfo: clientInfo,
+		Created:    now,
+		Updated:    now,
+	}
+	if len(a.log) < maxLogEntries {
+		a.log = append(a.log, nil)
+	}
+	copy(a.log[1:], a.log)
+	a.log[0] = entry
+}
+
+// Log returns up to count log entries, newest first. A negative count
+// returns them all.
+func (a *ACL) Log(count int) []LogEntry {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if count < 0 || count > len(a.log) {
+		count = len(a.log)
+	}
+	entries := make([]LogEntry, count)
+	for i := range entries {
+		entries[i] = *a.log[i]
+	}
+	return entries
+}
+
+// ResetLog clears the log
+func (a *ACL) ResetLog() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.log = nil
+}
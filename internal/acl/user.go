@@ -0,0 +1,292 @@
+package acl
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/Shaso41/Backend-SystemFocus/internal/glob"
+)
+
+// Errors reported for invalid rules, as in Redis
+var (
+	errSyntax        = errors.New("Syntax error")
+	errUnknown       = errors.New("Unknown command or category name in ACL")
+	errBadHash       = errors.New("The password hash must be exactly 64 characters and contain only lowercase hexadecimal characters")
+	errNoSuchPass    = errors.New("The password you are trying to remove from the user does not exist")
+	errNoSubcommand  = errors.New("Allowing first-arg of a subcommand is not supported")
+	errKeysAfterAll  = errors.New("Adding a pattern after the * pattern (or the 'allkeys' flag) is not valid and does not have any effect. Try 'resetkeys' to start with an empty list of patterns")
+	errChansAfterAll = errors.New("Adding a pattern after the * pattern (or the 'allchannels' flag) is not valid and does not have any effect. Try 'resetchannels' to start with an empty list of channels")
+)
+
+// user is a single ACL user. Users are never modified once published in
+// the ACL map; SetUser replaces them with a modified clone.
+type user struct {
+	name      string
+	enabled   bool
+	noPass    bool
+	passwords []string // hex SHA-256 hashes
+
+	// allowed holds the commands, and subcommands of container commands,
+	// the user may run. commandRules is the sequence of rules that built
+	// it, reported back by ACL GETUSER and ACL LIST.
+	allowed      map[string]bool
+	allCommands  bool
+	commandRules []string
+
+	allKeys     bool
+	keys        []string
+	allChannels bool
+	channels    []string
+}
+
+// newUser returns a disabled user that may do nothing
+func newUser(name string) *user {
+	return &user{
+		name:    name,
+		allowed: make(map[string]bool),
+	}
+}
+
+// clone returns a deep copy of u
+func (u *user) clone() *user {
+	c := *u
+	c.passwords = append([]string(nil), u.passwords...)
+	c.commandRules = append([]string(nil), u.commandRules...)
+	c.keys = append([]string(nil), u.keys...)
+	c.channels = append([]string(nil), u.channels...)
+	c.allowed = make(map[string]bool, len(u.allowed))
+	for name := range u.allowed {
+		c.allowed[name] = true
+	}
+	return &c
+}
+
+// apply applies a single rule to u
+func (a *ACL) apply(u *user, rule string) error {
+	switch strings.ToLower(rule) {
+	case "on":
+		u.enabled = true
+	case "off":
+		u.enabled = false
+	case "nopass":
+		u.noPass = true
+		u.passwords = nil
+	case "resetpass":
+		u.noPass = false
+		u.passwords = nil
+	case "allkeys":
+		u.allKeys, u.keys = true, nil
+	case "resetkeys":
+		u.allKeys, u.keys = false, nil
+	case "allchannels":
+		u.allChannels, u.channels = true, nil
+	case "resetchannels":
+		u.allChannels, u.channels = false, nil
+	case "allcommands":
+		return a.applyCommand(u, "+@all")
+	case "nocommands":
+		return a.applyCommand(u, "-@all")
+	case "reset":
+		for _, r := range []string{"resetpass", "resetkeys", "resetchannels", "off", "-@all"} {
+			if err := a.apply(u, r); err != nil {
+				return err
+			}
+		}
+	default:
+		if rule == "" {
+			return errSyntax
+		}
+		switch rule[0] {
+		case '>':
+			u.addPassword(hashPassword(rule[1:]))
+		case '<':
+			return u.removePassword(hashPassword(rule[1:]))
+		case '#':
+			if !validHash(rule[1:]) {
+				return errBadHash
+			}
+			u.addPassword(rule[1:])
+		case '!':
+			if !validHash(rule[1:]) {
+				return errBadHash
+			}
+			return u.removePassword(rule[1:])
+		case '~':
+			// A pattern cannot narrow all keys, so adding one is refused
+			if rule == "~*" {
+				u.allKeys, u.keys = true, nil
+			} else if u.allKeys {
+				return errKeysAfterAll
+			} else {
+				u.keys = appendPattern(u.keys, rule[1:])
+			}
+		case '&':
+			if rule == "&*" {
+				u.allChannels, u.channels = true, nil
+			} else if u.allChannels {
+				return errChansAfterAll
+			} else {
+				u.channels = appendPattern(u.channels, rule[1:])
+			}
+		case '+', '-':
+			return a.applyCommand(u, rule)
+		default:
+			return errSyntax
+		}
+	}
+	return nil
+}
+
+// applyCommand applies a +/- rule on a command, subcommand or @category
+func (a *ACL) applyCommand(u *user, rule string) error {
+	allow := rule[0] == '+'
+	target := strings.ToLower(rule[1:])
+
+	switch {
+	case strings.HasPrefix(target, "@"):
+		category := target[1:]
+		if !knownCategory(category) {
+			return errUnknown
+		}
+		for name, cats := range a.commands {
+			if category == "all" || hasCategory(cats, category) {
+				u.setAllowed(name, allow)
+			}
+		}
+	case strings.Contains(target, "|"):
+		if _, ok := a.commands[target]; !ok {
+			parent, _, _ := strings.Cut(target, "|")
+			if _, ok := a.commands[parent]; ok {
+				return errNoSubcommand
+			}
+			return errUnknown
+		}
+		u.setAllowed(target, allow)
+	case a.containers[target]:
+		// Along with the container itself when it runs without a subcommand
+		for name := range a.commands {
+			if name == target || strings.HasPrefix(name, target+"|") {
+				u.setAllowed(name, allow)
+			}
+		}
+	default:
+		if _, ok := a.commands[target]; !ok {
+			return errUnknown
+		}
+		u.setAllowed(target, allow)
+	}
+
+	// Rules on all commands make every earlier rule irrelevant
+	if target == "@all" {
+		u.commandRules = u.commandRules[:0]
+	}
+	u.commandRules = append(u.commandRules, rule[:1]+target)
+	u.allCommands = len(u.allowed) == len(a.commands)
+	return nil
+}
+
+func (u *user) setAllowed(name string, allow bool) {
+	if allow {
+		u.allowed[name] = true
+	} else {
+		delete(u.allowed, name)
+	}
+}
+
+func (u *user) addPassword(hash string) {
+	u.noPass = false
+	for _, h := range u.passwords {
+		if h == hash {
+			return
+		}
+	}
+	u.passwords = append(u.passwords, hash)
+}
+
+func (u *user) removePassword(hash string) error {
+	for i, h := range u.passwords {
+		if h == hash {
+			u.passwords = append(u.passwords[:i], u.passwords[i+1:]...)
+			return nil
+		}
+	}
+	return errNoSuchPass
+}
+
+// matchKey reports whether key matches one of the user's key patterns
+func (u *user) matchKey(key string) bool {
+	for _, pattern := range u.keys {
+		if glob.Match(pattern, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// describe returns the rules that recreate u, prefixed with "user <name>"
+func (u *user) describe() string {
+	parts := []string{"user", u.name}
+	if u.enabled {
+		parts = append(parts, "on")
+	} else {
+		parts = append(parts, "off")
+	}
+	if u.noPass {
+		parts = append(parts, "nopass")
+	}
+	for _, h := range u.passwords {
+		parts = append(parts, "#"+h)
+	}
+	// New users start without keys or channels, so empty lists are left
+	// out
+	if keys := describePatterns("~", u.allKeys, u.keys); keys != "" {
+		parts = append(parts, keys)
+	}
+	if channels := describePatterns("&", u.allChannels, u.channels); channels != "" {
+		parts = append(parts, channels)
+	}
+	parts = append(parts, u.describeCommands())
+	return strings.Join(parts, " ")
+}
+
+func (u *user) describeCommands() string {
+	if len(u.commandRules) == 0 {
+		return "-@all"
+	}
+	return strings.Join(u.commandRules, " ")
+}
+
+// describePatterns returns the rules for a key or channel list, with
+// prefix "~" or "&", or "" for an empty list
+func describePatterns(prefix string, all bool, patterns []string) string {
+	if all {
+		return prefix + "*"
+	}
+	parts := make([]string, len(patterns))
+	for i, p := range patterns {
+		parts[i] = prefix + p
+	}
+	return strings.Join(parts, " ")
+}
+
+func appendPattern(patterns []string, pattern string) []string {
+	for _, p := range patterns {
+		if p == pattern {
+			return patterns
+		}
+	}
+	return append(patterns, pattern)
+}
+
+// validHash reports whether h is a lower-case hex SHA-256 hash
+func validHash(h string) bool {
+	if len(h) != 64 {
+		return false
+	}
+	for i := 0; i < len(h); i++ {
+		if !('0' <= h[i] && h[i] <= '9') && !('a' <= h[i] && h[i] <= 'f') {
+			return false
+		}
+	}
+	return true
+}
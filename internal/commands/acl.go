@@ -0,0 +1,242 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Shaso41/Backend-SystemFocus/internal/acl"
+)
+
+var (
+	errNoAuth    = errors.New("NOAUTH Authentication required.")
+	errWrongPass = errors.New("WRONGPASS invalid username-password pair or user is disabled.")
+)
+
+// aclCommands maps every command and subcommand, by its lower-case ACL
+// name, to its categories. Container commands that run without a
+// subcommand, such as COMMAND, are listed themselves as well.
+func aclCommands() map[string][]string {
+	commands := make(map[string][]string)
+	for _, spec := range commandSpecs {
+		if spec.subcommands == nil || spec.categories != nil {
+			commands[spec.name] = spec.categories
+		}
+		for _, sub := range spec.subcommands {
+			commands[sub.name] = sub.categories
+		}
+	}
+	return commands
+}
+
+// authorize checks that the session's user may run a command on its keys.
+// Refusals are recorded in the ACL log.
+func (h *Handler) authorize(sess *Session, spec *commandSpec, args [][]byte) error {
+	if spec.flags&flagNoAuth != 0 {
+		return nil
+	}
+	if sess.User == "" {
+		return errNoAuth
+	}
+
+	var sub []byte
+	if spec.subcommands != nil && len(args) > 1 {
+		sub = args[1]
+	}
+	var keyBuf [4][]byte
+	keys := spec.keys(keyBuf[:0], args)
+
+	reason, object, ok := h.acl.Check(sess.User, spec.name, sub, keys)
+	if ok {
+		return nil
+	}
+	if reason == acl.ReasonAuth {
+		// The user was deleted since the client authenticated
+		sess.User = ""
+		sess.publish()
+		return errNoAuth
+	}
+
+	h.acl.LogDenial(reason, "toplevel", object, sess.User, sess.clientInfo())
+	if reason == acl.ReasonCommand {
+		return fmt.Errorf("NOPERM this user has no permissions to run the '%s' command", object)
+	}
+	return fmt.Errorf("NOPERM this user has no permissions to access one of the keys used as arguments")
+}
+
+// authenticate logs the session in as the named user if password is valid
+func (h *Handler) authenticate(sess *Session, name, password string) error {
+	if !h.acl.Authenticate(name, password) {
+		h.acl.LogDenial(acl.ReasonAuth, "toplevel", "AUTH", name, sess.clientInfo())
+		return errWrongPass
+	}
+	sess.User = name
+	sess.publish()
+	return nil
+}
+
+// handleAuth handles AUTH command
+// AUTH [username] password
+func (h *Handler) handleAuth(sess *Session, args [][]byte) (interface{}, error) {
+	if len(args) != 2 && len(args) != 3 {
+		return nil, fmt.Errorf("ERR wrong number of arguments for 'auth' command")
+	}
+
+	name, password := acl.DefaultUser, string(args[1])
+	if len(args) == 3 {
+		name, password = string(args[1]), string(args[2])
+	} else if h.acl.NoPass(acl.DefaultUser) {
+		return nil, fmt.Errorf("ERR AUTH <password> called without any password configured for the default user. Are you sure your configuration is correct?")
+	}
+
+	if err := h.authenticate(sess, name, password); err != nil {
+		return nil, err
+	}
+	return SimpleString("OK"), nil
+}
+
+// handleACL handles ACL command
+// ACL SETUSER|GETUSER|DELUSER|LIST|WHOAMI|CAT|LOG|LOAD|SAVE [arg ...]
+func (h *Handler) handleACL(sess *Session, args [][]byte) (interface{}, error) {
+	sub := strings.ToLower(string(args[1]))
+	arityErr := fmt.Errorf("ERR wrong number of arguments for 'acl|%s' command", sub)
+
+	switch sub {
+	case "setuser":
+		rules := make([]string, len(args)-3)
+		for i, arg := range args[3:] {
+			rules[i] = string(arg)
+		}
+		if err := h.acl.SetUser(string(args[2]), rules...); err != nil {
+			return nil, fmt.Errorf("ERR %v", err)
+		}
+		return SimpleString("OK"), nil
+
+	case "getuser":
+		info, ok := h.acl.GetUser(string(args[2]))
+		if !ok {
+			return nil, nil
+		}
+		return Map{
+			{BulkString("flags"), info.Flags},
+			{BulkString("passwords"), info.Passwords},
+			{BulkString("commands"), BulkString(info.Commands)},
+			{BulkString("keys"), BulkString(info.Keys)},
+			{BulkString("channels"), BulkString(info.Channels)},
+			{BulkString("selectors"), []interface{}{}},
+		}, nil
+
+	case "deluser":
+		names := make([]string, len(args)-2)
+		for i, arg := range args[2:] {
+			names[i] = string(arg)
+		}
+		n, err := h.acl.DelUser(names...)
+		if err != nil {
+			return nil, fmt.Errorf("ERR %v", err)
+		}
+		return int64(n), nil
+
+	case "list":
+		return h.acl.List(), nil
+
+	case "whoami":
+		return BulkString(sess.User), nil
+
+	case "cat":
+		if len(args) > 3 {
+			return nil, arityErr
+		}
+		if len(args) == 2 {
+			return acl.Categories, nil
+		}
+		names, ok := h.acl.CategoryCommands(string(args[2]))
+		if !ok {
+			return nil, fmt.Errorf("ERR Unknown category '%s'", args[2])
+		}
+		return names, nil
+
+	case "log":
+		return h.aclLog(args, arityErr)
+
+	case "load", "save":
+		var err error
+		if sub == "load" {
+			err = h.acl.Load()
+		} else {
+			err = h.acl.Save()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ERR %v", err)
+		}
+		return SimpleString("OK"), nil
+
+	default:
+		return nil, fmt.Errorf("ERR unknown subcommand '%s'. Try ACL HELP.", args[1])
+	}
+}
+
+// aclLog handles ACL LOG [count|RESET]
+func (h *Handler) aclLog(args [][]byte, arityErr error) (interface{}, error) {
+	if len(args) > 3 {
+		return nil, arityErr
+	}
+
+	count := 10
+	if len(args) == 3 {
+		if strings.EqualFold(string(args[2]), "RESET") {
+			h.acl.ResetLog()
+			return SimpleString("OK"), nil
+		}
+		n, err := strconv.Atoi(string(args[2]))
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("ERR value is out of range, must be positive")
+		}
+		count = n
+	}
+
+	now := time.Now()
+	entries := h.acl.Log(count)
+	reply := make([]interface{}, len(entries))
+	for i, e := range entries {
+		reply[i] = Map{
+			{BulkString("count"), int64(e.Count)},
+			{BulkString("reason"), BulkString(e.Reason)},
+			{BulkString("context"), BulkString(e.Context)},
+			{BulkString("object"), BulkString(e.Object)},
+			{BulkString("username"), BulkString(e.Username)},
+			{BulkString("age-seconds"), Double(now.Sub(e.Created).Seconds())},
+			{BulkString("client-info"), BulkString(e.ClientInfo)},
+		}
+	}
+	return reply, nil
+}
+
+// secretArg reports whether argument i of a command holds credentials,
+// which logs of the command line show redacted: the arguments of AUTH,
+// those following HELLO's AUTH option, ACL SETUSER password rules and
+// the value of CONFIG SET requirepass
+func secretArg(args [][]byte, i int) bool {
+	name := args[0]
+	switch {
+	case strings.EqualFold(string(name), "AUTH"):
+		return i > 0
+	case strings.EqualFold(string(name), "HELLO"):
+		return i >= 3 && (strings.EqualFold(string(args[i-1]), "AUTH") ||
+			i >= 4 && strings.EqualFold(string(args[i-2]), "AUTH"))
+	case strings.EqualFold(string(name), "ACL"):
+		if i < 3 || !strings.EqualFold(string(args[1]), "SETUSER") || len(args[i]) == 0 {
+			return false
+		}
+		switch args[i][0] {
+		case '>', '<', '#', '!':
+			return true
+		}
+	case strings.EqualFold(string(name), "CONFIG"):
+		return i >= 3 && i%2 == 1 && strings.EqualFold(string(args[1]), "SET") &&
+			strings.EqualFold(string(args[i-1]), "requirepass")
+	}
+	return false
+}
@@ -0,0 +1,172 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/Shaso41/Backend-SystemFocus/internal/acl"
+	"github.com/Shaso41/Backend-SystemFocus/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler_RequirePass(t *testing.T) {
+	s := store.New()
+	defer s.Close()
+	h := NewHandler(s)
+	assert.NoError(t, h.ACL().SetUser(acl.DefaultUser, "resetpass", ">secret"))
+	sess := h.NewSession()
+
+	_, err := h.Exec(sess, argv("GET", "k"))
+	assert.EqualError(t, err, "NOAUTH Authentication required.")
+
+	_, err = h.Exec(sess, argv("HELLO", "3"))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "NOAUTH")
+
+	_, err = h.Exec(sess, argv("AUTH", "wrong"))
+	assert.EqualError(t, err, "WRONGPASS invalid username-password pair or user is disabled.")
+
+	result, err := h.Exec(sess, argv("AUTH", "secret"))
+	assert.NoError(t, err)
+	assert.Equal(t, SimpleString("OK"), result)
+
+	_, err = h.Exec(sess, argv("GET", "k"))
+	assert.NoError(t, err)
+}
+
+func TestHandler_AuthWithoutPassword(t *testing.T) {
+	s := store.New()
+	defer s.Close()
+	h := NewHandler(s)
+	sess := h.NewSession()
+
+	_, err := h.Exec(sess, argv("AUTH", "secret"))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "without any password configured")
+}
+
+func TestHandler_ACLPermissions(t *testing.T) {
+	s := store.New()
+	defer s.Close()
+	h := NewHandler(s)
+
+	_, err := h.Execute([]interface{}{"ACL", "SETUSER", "cache", "on", ">pw", "~cache:*", "+@read", "+@write", "-@dangerous", "+acl|whoami"})
+	assert.NoError(t, err)
+
+	sess := h.NewSession()
+	sess.Addr = "127.0.0.1:5000"
+	_, err = h.Exec(sess, argv("HELLO", "2", "AUTH", "cache", "pw"))
+	assert.NoError(t, err)
+
+	result, err := h.Exec(sess, argv("ACL", "WHOAMI"))
+	assert.NoError(t, err)
+	assert.Equal(t, BulkString("cache"), result)
+
+	_, err = h.Exec(sess, argv("SET", "cache:1", "v"))
+	assert.NoError(t, err)
+
+	_, err = h.Exec(sess, argv("SET", "session:1", "v"))
+	assert.EqualError(t, err, "NOPERM this user has no permissions to access one of the keys used as arguments")
+
+	_, err = h.Exec(sess, argv("KEYS", "*"))
+	assert.EqualError(t, err, "NOPERM this user has no permissions to run the 'keys' command")
+
+	_, err = h.Exec(sess, argv("ACL", "SETUSER", "cache", "+@all"))
+	assert.EqualError(t, err, "NOPERM this user has no permissions to run the 'acl|setuser' command")
+
+	// Refusals end up in the log, newest first
+	result, err = h.Execute([]interface{}{"ACL", "LOG"})
+	assert.NoError(t, err)
+	entries := result.([]interface{})
+	assert.Len(t, entries, 3)
+
+	latest := entries[0].(Map)
+	assert.Equal(t, MapEntry{BulkString("reason"), BulkString("command")}, latest[1])
+	assert.Equal(t, MapEntry{BulkString("object"), BulkString("acl|setuser")}, latest[3])
+	assert.Equal(t, MapEntry{BulkString("username"), BulkString("cache")}, latest[4])
+	assert.Contains(t, string(latest[6].Value.(BulkString)), "addr=127.0.0.1:5000")
+
+	result, err = h.Execute([]interface{}{"ACL", "LOG", "RESET"})
+	assert.NoError(t, err)
+	assert.Equal(t, SimpleString("OK"), result)
+}
+
+func TestHandler_ACLUserManagement(t *testing.T) {
+	s := store.New()
+	defer s.Close()
+	h := NewHandler(s)
+
+	_, err := h.Execute([]interface{}{"ACL", "SETUSER", "alice", "on", "nopass", "~*", "+get"})
+	assert.NoError(t, err)
+
+	result, err := h.Execute([]interface{}{"ACL", "GETUSER", "alice"})
+	assert.NoError(t, err)
+	user := result.(Map)
+	assert.Equal(t, MapEntry{BulkString("flags"), []string{"on", "nopass"}}, user[0])
+	assert.Equal(t, MapEntry{BulkString("commands"), BulkString("+get")}, user[2])
+	assert.Equal(t, MapEntry{BulkString("keys"), BulkString("~*")}, user[3])
+
+	result, err = h.Execute([]interface{}{"ACL", "LIST"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"user alice on nopass ~* +get",
+		"user default on nopass ~* &* +@all",
+	}, result)
+
+	_, err = h.Execute([]interface{}{"ACL", "SETUSER", "alice", "+bogus"})
+	assert.EqualError(t, err, "ERR Error in ACL SETUSER modifier '+bogus': Unknown command or category name in ACL")
+
+	result, err = h.Execute([]interface{}{"ACL", "DELUSER", "alice", "nobody"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), result)
+
+	result, err = h.Execute([]interface{}{"ACL", "GETUSER", "alice"})
+	assert.NoError(t, err)
+	assert.Nil(t, result)
+
+	_, err = h.Execute([]interface{}{"ACL", "DELUSER", "default"})
+	assert.Error(t, err)
+}
+
+func TestHandler_ACLDeletedUserMustReauthenticate(t *testing.T) {
+	s := store.New()
+	defer s.Close()
+	h := NewHandler(s)
+
+	_, err := h.Execute([]interface{}{"ACL", "SETUSER", "temp", "on", ">pw", "allcommands", "allkeys"})
+	assert.NoError(t, err)
+
+	sess := h.NewSession()
+	_, err = h.Exec(sess, argv("AUTH", "temp", "pw"))
+	assert.NoError(t, err)
+
+	_, err = h.Execute([]interface{}{"ACL", "DELUSER", "temp"})
+	assert.NoError(t, err)
+
+	_, err = h.Exec(sess, argv("GET", "k"))
+	assert.EqualError(t, err, "NOAUTH Authentication required.")
+}
+
+func TestHandler_ACLCat(t *testing.T) {
+	s := store.New()
+	defer s.Close()
+	h := NewHandler(s)
+
+	result, err := h.Execute([]interface{}{"ACL", "CAT"})
+	assert.NoError(t, err)
+	assert.Contains(t, result, "dangerous")
+
+	result, err = h.Execute([]interface{}{"ACL", "CAT", "bitmap"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"bitcount", "bitfield", "bitfield_ro", "bitop", "bitpos", "getbit", "setbit"}, result)
+
+	_, err = h.Execute([]interface{}{"ACL", "CAT", "nope"})
+	assert.EqualError(t, err, "ERR Unknown category 'nope'")
+}
+
+func TestCommandSpec_Keys(t *testing.T) {
+	assert.Equal(t, [][]byte{[]byte("dest"), []byte("a"), []byte("b")},
+		commandSpecs["BITOP"].keys(nil, argv("BITOP", "AND", "dest", "a", "b")))
+	assert.Equal(t, [][]byte{[]byte("k")}, commandSpecs["GET"].keys(nil, argv("GET", "k")))
+	assert.Empty(t, commandSpecs["GET"].keys(nil, argv("GET")))
+	assert.Empty(t, commandSpecs["KEYS"].keys(nil, argv("KEYS", "*")))
+}
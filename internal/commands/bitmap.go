@@ -0,0 +1,310 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Shaso41/Backend-SystemFocus/internal/store"
+)
+
+// maxBitOffset is the largest bit offset accepted by bit commands, which
+// limits bitmaps to 512MB like Redis
+const maxBitOffset = 1<<32 - 1
+
+// handleSetBit handles SETBIT command
+// SETBIT key offset value
+func (h *Handler) handleSetBit(args [][]byte) (interface{}, error) {
+	key := string(args[1])
+
+	offset, err := parseBitOffset(args[2], false, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	value := string(args[3])
+	if value != "0" && value != "1" {
+		return nil, fmt.Errorf("ERR bit is not an integer or out of range")
+	}
+
+	return int64(h.store.SetBit(key, offset, value == "1")), nil
+}
+
+// handleGetBit handles GETBIT command
+// GETBIT key offset
+func (h *Handler) handleGetBit(args [][]byte) (interface{}, error) {
+	key := string(args[1])
+
+	offset, err := parseBitOffset(args[2], false, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return int64(h.store.GetBit(key, offset)), nil
+}
+
+// handleBitCount handles BITCOUNT command
+// BITCOUNT key [start end [BYTE|BIT]]
+func (h *Handler) handleBitCount(args [][]byte) (interface{}, error) {
+	if len(args) == 3 || len(args) > 5 {
+		return nil, fmt.Errorf("ERR syntax error")
+	}
+
+	key := string(args[1])
+
+	if len(args) == 2 {
+		return h.store.BitCount(key, 0, -1, false, false), nil
+	}
+
+	start, err := parseInt(args[2])
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseInt(args[3])
+	if err != nil {
+		return nil, err
+	}
+
+	bitUnit := false
+	if len(args) == 5 {
+		if bitUnit, err = parseBitUnit(args[4]); err != nil {
+			return nil, err
+		}
+	}
+
+	return h.store.BitCount(key, start, end, true, bitUnit), nil
+}
+
+// handleBitPos handles BITPOS command
+// BITPOS key bit [start [end [BYTE|BIT]]]
+func (h *Handler) handleBitPos(args [][]byte) (interface{}, error) {
+	if len(args) > 6 {
+		return nil, fmt.Errorf("ERR syntax error")
+	}
+
+	key := string(args[1])
+
+	bitArg := string(args[2])
+	if bitArg != "0" && bitArg != "1" {
+		return nil, fmt.Errorf("ERR The bit argument must be 1 or 0.")
+	}
+	bit := int(bitArg[0] - '0')
+
+	var start, end int64
+	var err error
+	hasStart, hasEnd, bitUnit := len(args) > 3, len(args) > 4, false
+	if hasStart {
+		if start, err = parseInt(args[3]); err != nil {
+			return nil, err
+		}
+	}
+	if hasEnd {
+		if end, err = parseInt(args[4]); err != nil {
+			return nil, err
+		}
+	}
+	if len(args) == 6 {
+		if bitUnit, err = parseBitUnit(args[5]); err != nil {
+			return nil, err
+		}
+	}
+
+	return h.store.BitPos(key, bit, start, end, hasStart, hasEnd, bitUnit), nil
+}
+
+// handleBitOp handles BITOP command
+// BITOP AND|OR|XOR|NOT destkey key [key ...]
+func (h *Handler) handleBitOp(args [][]byte) (interface{}, error) {
+	keys := make([]string, len(args)-1)
+	for i, arg := range args[1:] {
+		keys[i] = string(arg)
+	}
+
+	var op store.BitOp
+	switch strings.ToUpper(keys[0]) {
+	case "AND":
+		op = store.BitOpAnd
+	case "OR":
+		op = store.BitOpOr
+	case "XOR":
+		op = store.BitOpXor
+	case "NOT":
+		op = store.BitOpNot
+		if len(keys) != 3 {
+			return nil, fmt.Errorf("ERR BITOP NOT must be called with a single source key.")
+		}
+	default:
+		return nil, fmt.Errorf("ERR syntax error")
+	}
+
+	return h.store.BitOp(op, keys[1], keys[2:]), nil
+}
+
+// handleBitField handles BITFIELD command
+// BITFIELD key [GET encoding offset] [SET encoding offset value]
+// [INCRBY encoding offset increment] [OVERFLOW WRAP|SAT|FAIL] ...
+func (h *Handler) handleBitField(args [][]byte) (interface{}, error) {
+	return h.bitField(args, false)
+}
+
+// handleBitFieldRO handles BITFIELD_RO command, which only allows GET
+func (h *Handler) handleBitFieldRO(args [][]byte) (interface{}, error) {
+	return h.bitField(args, true)
+}
+
+// bitField parses BITFIELD subcommands and runs them against the store
+func (h *Handler) bitField(args [][]byte, readOnly bool) (interface{}, error) {
+	key := string(args[1])
+
+	var ops []store.BitFieldOp
+	overflow := store.OverflowWrap
+	for i := 2; i < len(args); {
+		sub := strings.ToUpper(string(args[i]))
+
+		if sub == "OVERFLOW" {
+			if readOnly {
+				return nil, fmt.Errorf("ERR BITFIELD_RO only supports the GET subcommand")
+			}
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("ERR syntax error")
+			}
+			switch strings.ToUpper(string(args[i+1])) {
+			case "WRAP":
+				overflow = store.OverflowWrap
+			case "SAT":
+				overflow = store.OverflowSat
+			case "FAIL":
+				overflow = store.OverflowFail
+			default:
+				return nil, fmt.Errorf("ERR Invalid OVERFLOW type specified")
+			}
+			i += 2
+			continue
+		}
+
+		var kind store.BitFieldKind
+		argc := 3
+		switch sub {
+		case "GET":
+			kind = store.BitFieldGet
+		case "SET":
+			kind, argc = store.BitFieldSet, 4
+		case "INCRBY":
+			kind, argc = store.BitFieldIncrBy, 4
+		default:
+			return nil, fmt.Errorf("ERR syntax error")
+		}
+		if readOnly && kind != store.BitFieldGet {
+			return nil, fmt.Errorf("ERR BITFIELD_RO only supports the GET subcommand")
+		}
+		if i+argc > len(args) {
+			return nil, fmt.Errorf("ERR syntax error")
+		}
+
+		signed, width, err := parseBitFieldType(args[i+1])
+		if err != nil {
+			return nil, err
+		}
+		offset, err := parseBitOffset(args[i+2], true, width)
+		if err != nil {
+			return nil, err
+		}
+
+		op := store.BitFieldOp{
+			Kind:     kind,
+			Signed:   signed,
+			Bits:     width,
+			Offset:   offset,
+			Overflow: overflow,
+		}
+		if argc == 4 {
+			if op.Value, err = parseInt(args[i+3]); err != nil {
+				return nil, err
+			}
+		}
+		ops = append(ops, op)
+		i += argc
+	}
+
+	results := h.store.BitField(key, ops)
+	reply := make([]interface{}, len(results))
+	for i, r := range results {
+		if r.Failed {
+			reply[i] = nil
+		} else {
+			reply[i] = r.Value
+		}
+	}
+	return reply, nil
+}
+
+// parseInt parses a signed 64-bit integer argument
+func parseInt(arg []byte) (int64, error) {
+	n, err := strconv.ParseInt(string(arg), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("ERR value is not an integer or out of range")
+	}
+	return n, nil
+}
+
+// parseBitUnit parses the BYTE|BIT range unit of BITCOUNT and BITPOS and
+// reports whether ranges are in bits
+func parseBitUnit(arg []byte) (bool, error) {
+	switch strings.ToUpper(string(arg)) {
+	case "BYTE":
+		return false, nil
+	case "BIT":
+		return true, nil
+	default:
+		return false, fmt.Errorf("ERR syntax error")
+	}
+}
+
+// parseBitOffset parses a bit offset. With hashAllowed, an offset of the
+// form #N means N times width, as used by BITFIELD.
+func parseBitOffset(arg []byte, hashAllowed bool, width uint) (uint64, error) {
+	errOffset := fmt.Errorf("ERR bit offset is not an integer or out of range")
+
+	s := string(arg)
+
+	multiplier := uint64(1)
+	if hashAllowed && strings.HasPrefix(s, "#") {
+		s, multiplier = s[1:], uint64(width)
+	}
+
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil || n > maxBitOffset {
+		return 0, errOffset
+	}
+	n *= multiplier
+	if n+uint64(width) > maxBitOffset+1 {
+		return 0, errOffset
+	}
+	return n, nil
+}
+
+// parseBitFieldType parses a BITFIELD encoding such as i8 or u16. Signed
+// fields may be up to 64 bits wide and unsigned ones up to 63.
+func parseBitFieldType(arg []byte) (bool, uint, error) {
+	errType := fmt.Errorf("ERR Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is.")
+
+	s := string(arg)
+	if len(s) < 2 {
+		return false, 0, errType
+	}
+
+	signed := false
+	switch s[0] {
+	case 'i', 'I':
+		signed = true
+	case 'u', 'U':
+	default:
+		return false, 0, errType
+	}
+
+	width, err := strconv.Atoi(s[1:])
+	if err != nil || width < 1 || (signed && width > 64) || (!signed && width > 63) {
+		return false, 0, errType
+	}
+	return signed, uint(width), nil
+}
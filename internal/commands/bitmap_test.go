@@ -0,0 +1,111 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/Shaso41/Backend-SystemFocus/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler_SetBitAndGetBit(t *testing.T) {
+	s := store.New()
+	defer s.Close()
+	h := NewHandler(s)
+
+	result, err := h.Execute([]interface{}{"SETBIT", "dau", "100", "1"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), result)
+
+	result, err = h.Execute([]interface{}{"GETBIT", "dau", "100"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), result)
+
+	_, err = h.Execute([]interface{}{"SETBIT", "dau", "1", "2"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "bit is not an integer")
+
+	_, err = h.Execute([]interface{}{"SETBIT", "dau", "4294967296", "1"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "bit offset")
+}
+
+func TestHandler_BitCountAndBitPos(t *testing.T) {
+	s := store.New()
+	defer s.Close()
+	h := NewHandler(s)
+
+	h.Execute([]interface{}{"SET", "key", "foobar"})
+
+	result, err := h.Execute([]interface{}{"BITCOUNT", "key"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(26), result)
+
+	result, err = h.Execute([]interface{}{"BITCOUNT", "key", "5", "30", "bit"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(17), result)
+
+	_, err = h.Execute([]interface{}{"BITCOUNT", "key", "1"})
+	assert.Error(t, err)
+
+	result, err = h.Execute([]interface{}{"BITPOS", "key", "1", "2", "-1", "BYTE"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(17), result)
+
+	_, err = h.Execute([]interface{}{"BITPOS", "key", "2"})
+	assert.Error(t, err)
+}
+
+func TestHandler_BitOp(t *testing.T) {
+	s := store.New()
+	defer s.Close()
+	h := NewHandler(s)
+
+	h.Execute([]interface{}{"SETBIT", "day1", "3", "1"})
+	h.Execute([]interface{}{"SETBIT", "day2", "3", "1"})
+	h.Execute([]interface{}{"SETBIT", "day2", "9", "1"})
+
+	result, err := h.Execute([]interface{}{"BITOP", "and", "both", "day1", "day2"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), result)
+
+	result, _ = h.Execute([]interface{}{"BITCOUNT", "both"})
+	assert.Equal(t, int64(1), result)
+
+	_, err = h.Execute([]interface{}{"BITOP", "NOT", "dest", "day1", "day2"})
+	assert.Error(t, err)
+
+	_, err = h.Execute([]interface{}{"BITOP", "NAND", "dest", "day1"})
+	assert.Error(t, err)
+}
+
+func TestHandler_BitField(t *testing.T) {
+	s := store.New()
+	defer s.Close()
+	h := NewHandler(s)
+
+	result, err := h.Execute([]interface{}{
+		"BITFIELD", "bf",
+		"SET", "u8", "#1", "200",
+		"GET", "u8", "8",
+		"OVERFLOW", "FAIL",
+		"INCRBY", "u8", "#1", "100",
+		"OVERFLOW", "SAT",
+		"INCRBY", "i8", "0", "-200",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{int64(0), int64(200), nil, int64(-128)}, result)
+
+	result, err = h.Execute([]interface{}{"BITFIELD_RO", "bf", "GET", "u8", "#1"})
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{int64(200)}, result)
+
+	_, err = h.Execute([]interface{}{"BITFIELD_RO", "bf", "SET", "u8", "0", "1"})
+	assert.Error(t, err)
+
+	_, err = h.Execute([]interface{}{"BITFIELD", "bf", "GET", "u64", "0"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Invalid bitfield type")
+
+	_, err = h.Execute([]interface{}{"BITFIELD", "bf", "OVERFLOW", "NOPE"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,418 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clientTypes are the client types CLIENT LIST and CLIENT KILL filter by.
+// Every client of this server is a normal one.
+var clientTypes = map[string]bool{
+	"normal":  true,
+	"master":  true,
+	"replica": true,
+	"slave":   true,
+	"pubsub":  true,
+}
+
+// ErrMaxClients is returned by Register when maxclients clients are
+// already connected
+var ErrMaxClients = errors.New("ERR max number of clients reached")
+
+// Register adds a connected client's session to the registry CLIENT LIST
+// reports, with the connection CLIENT KILL closes. Unregister removes it
+// when the connection ends. Once maxclients clients are registered, further
+// ones are refused with ErrMaxClients.
+func (h *Handler) Register(sess *Session, conn io.Closer) error {
+	sess.conn = conn
+	sess.killed = make(chan struct{})
+	sess.publish()
+
+	h.clientsMu.Lock()
+	defer h.clientsMu.Unlock()
+
+	if int64(len(h.clients)) >= h.maxClients.Get() {
+		h.rejectedConnections.Add(1)
+		return ErrMaxClients
+	}
+	h.clients[sess.ID] = sess
+	h.totalConnections.Add(1)
+	return nil
+}
+
+// Unregister removes a session from the client registry
+func (h *Handler) Unregister(sess *Session) {
+	h.stopMonitor(sess)
+
+	h.clientsMu.Lock()
+	defer h.clientsMu.Unlock()
+
+	delete(h.clients, sess.ID)
+}
+
+// ConnectedClients returns the number of registered clients
+func (h *Handler) ConnectedClients() int {
+	h.clientsMu.RLock()
+	defer h.clientsMu.RUnlock()
+
+	return len(h.clients)
+}
+
+// sessions returns the registered sessions ordered by ID
+func (h *Handler) sessions() []*Session {
+	h.clientsMu.RLock()
+	sessions := make([]*Session, 0, len(h.clients))
+	for _, sess := range h.clients {
+		sessions = append(sessions, sess)
+	}
+	h.clientsMu.RUnlock()
+
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].ID < sessions[j].ID })
+	return sessions
+}
+
+// kill closes the session's connection. A client killing itself gets its
+// reply first.
+func (h *Handler) kill(caller, target *Session) {
+	if target == caller {
+		target.closeAfterReply = true
+		return
+	}
+	target.killOnce.Do(func() {
+		close(target.killed)
+		target.conn.Close()
+	})
+}
+
+// describe formats a client as a line of CLIENT LIST
+func (sess *Session) describe(now time.Time) string {
+	state := sess.snapshot()
+
+	flags := ""
+	if state.monitor {
+		flags += "O"
+	}
+	if state.noEvict {
+		flags += "e"
+	}
+	if flags == "" {
+		flags = "N"
+	}
+
+	cmd := "NULL"
+	if name := sess.cmd.Load(); name != nil {
+		cmd = *name
+	}
+	idle := now.Sub(time.Unix(0, sess.lastInteraction.Load()))
+	obuf := sess.outputBuffer.Load()
+
+	return fmt.Sprintf("id=%d addr=%s laddr=%s name=%s age=%d idle=%d flags=%s db=0 sub=0 psub=0 multi=-1 "+
+		"qbuf=%d qbuf-free=%d obl=%d oll=0 omem=%d cmd=%s user=%s resp=%d",
+		sess.ID, sess.Addr, sess.LocalAddr, state.name,
+		int64(now.Sub(sess.created).Seconds()), int64(idle.Seconds()), flags,
+		sess.queryBuffer.Load(), sess.queryBufferFree.Load(), obuf, obuf, cmd, state.user, state.protocol)
+}
+
+// handleClient handles CLIENT command
+// CLIENT LIST|INFO|KILL|SETNAME|GETNAME|ID|PAUSE|UNPAUSE|NO-EVICT [arg ...]
+func (h *Handler) handleClient(sess *Session, args [][]byte) (interface{}, error) {
+	sub := strings.ToLower(string(args[1]))
+	arityErr := fmt.Errorf("ERR wrong number of arguments for 'client|%s' command", sub)
+
+	switch sub {
+	case "id":
+		return sess.ID, nil
+
+	case "getname":
+		if sess.Name == "" {
+			return nil, nil
+		}
+		return BulkString(sess.Name), nil
+
+	case "setname":
+		name := string(args[2])
+		if !validClientName(name) {
+			return nil, fmt.Errorf("ERR Client names cannot contain spaces, newlines or special characters.")
+		}
+		sess.Name = name
+		sess.publish()
+		return SimpleString("OK"), nil
+
+	case "info":
+		return BulkString(sess.describe(time.Now()) + "\n"), nil
+
+	case "list":
+		return h.clientList(args)
+
+	case "kill":
+		return h.clientKill(sess, args)
+
+	case "pause":
+		if len(args) > 4 {
+			return nil, arityErr
+		}
+		ms, err := strconv.ParseInt(string(args[2]), 10, 64)
+		if err != nil || ms < 0 {
+			return nil, fmt.Errorf("ERR timeout is not an integer or out of range")
+		}
+		all := true
+		if len(args) == 4 {
+			switch strings.ToUpper(string(args[3])) {
+			case "ALL":
+			case "WRITE":
+				all = false
+			default:
+				return nil, fmt.Errorf("ERR syntax error")
+			}
+		}
+		h.pauseClients(time.Duration(ms)*time.Millisecond, all)
+		return SimpleString("OK"), nil
+
+	case "unpause":
+		h.unpauseClients(-1)
+		return SimpleString("OK"), nil
+
+	case "no-evict":
+		switch strings.ToUpper(string(args[2])) {
+		case "ON":
+			sess.noEvict = true
+		case "OFF":
+			sess.noEvict = false
+		default:
+			return nil, fmt.Errorf("ERR syntax error")
+		}
+		sess.publish()
+		return SimpleString("OK"), nil
+
+	default:
+		return nil, fmt.Errorf("ERR unknown subcommand '%s'. Try CLIENT HELP.", args[1])
+	}
+}
+
+// clientList handles CLIENT LIST [TYPE type] [ID id [id ...]]
+func (h *Handler) clientList(args [][]byte) (interface{}, error) {
+	var ids map[int64]bool
+	for i := 2; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i])) {
+		case "TYPE":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("ERR syntax error")
+			}
+			t := strings.ToLower(string(args[i+1]))
+			if !clientTypes[t] {
+				return nil, fmt.Errorf("ERR Unknown client type '%s'", args[i+1])
+			}
+			if t != "normal" {
+				return BulkString(""), nil
+			}
+			i++
+		case "ID":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("ERR syntax error")
+			}
+			ids = make(map[int64]bool)
+			for i++; i < len(args); i++ {
+				id, err := strconv.ParseInt(string(args[i]), 10, 64)
+				if err != nil || id <= 0 {
+					return nil, fmt.Errorf("ERR Invalid client ID")
+				}
+				ids[id] = true
+			}
+		default:
+			return nil, fmt.Errorf("ERR syntax error")
+		}
+	}
+
+	now := time.Now()
+	var b strings.Builder
+	for _, sess := range h.sessions() {
+		if ids != nil && !ids[sess.ID] {
+			continue
+		}
+		b.WriteString(sess.describe(now))
+		b.WriteByte('\n')
+	}
+	return BulkString(b.String()), nil
+}
+
+// clientKill handles CLIENT KILL addr and
+// CLIENT KILL [ID id] [ADDR addr] [LADDR laddr] [USER user] [TYPE type] [SKIPME yes|no]
+func (h *Handler) clientKill(sess *Session, args [][]byte) (interface{}, error) {
+	// The old form names a single client by address
+	if len(args) == 3 {
+		for _, target := range h.sessions() {
+			if target.Addr == string(args[2]) {
+				h.kill(sess, target)
+				return SimpleString("OK"), nil
+			}
+		}
+		return nil, fmt.Errorf("ERR No such client")
+	}
+
+	if len(args)%2 != 0 {
+		return nil, fmt.Errorf("ERR syntax error")
+	}
+	var (
+		id          int64
+		addr, laddr string
+		user        *string
+		skipMe      = true
+		typeMatches = true
+	)
+	for i := 2; i < len(args); i += 2 {
+		value := string(args[i+1])
+		switch strings.ToUpper(string(args[i])) {
+		case "ID":
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("ERR client-id should be greater than 0")
+			}
+			id = n
+		case "ADDR":
+			addr = value
+		case "LADDR":
+			laddr = value
+		case "USER":
+			user = &value
+		case "TYPE":
+			t := strings.ToLower(value)
+			if !clientTypes[t] {
+				return nil, fmt.Errorf("ERR Unknown client type '%s'", value)
+			}
+			typeMatches = t == "normal"
+		case "SKIPME":
+			switch strings.ToLower(value) {
+			case "yes":
+				skipMe = true
+			case "no":
+				skipMe = false
+			default:
+				return nil, fmt.Errorf("ERR syntax error")
+			}
+		default:
+			return nil, fmt.Errorf("ERR syntax error")
+		}
+	}
+
+	killed := int64(0)
+	if !typeMatches {
+		return killed, nil
+	}
+	for _, target := range h.sessions() {
+		switch {
+		case id != 0 && target.ID != id,
+			addr != "" && target.Addr != addr,
+			laddr != "" && target.LocalAddr != laddr,
+			user != nil && target.snapshot().user != *user,
+			skipMe && target == sess:
+			continue
+		}
+		h.kill(sess, target)
+		killed++
+	}
+	return killed, nil
+}
+
+// pauseClients suspends commands from all clients, or only write commands,
+// for d. A new pause replaces the mode and end of one in progress.
+func (h *Handler) pauseClients(d time.Duration, all bool) {
+	h.pauseMu.Lock()
+	defer h.pauseMu.Unlock()
+
+	if h.pauseEnd == nil {
+		h.pauseEnd = make(chan struct{})
+	} else {
+		h.pauseTimer.Stop()
+	}
+	h.pauseAll = all
+	h.pauseGen++
+	gen := h.pauseGen
+	h.pauseTimer = time.AfterFunc(d, func() { h.unpauseClients(gen) })
+	h.paused.Store(true)
+}
+
+// unpauseClients ends the pause started as generation gen, or any pause
+// for a negative gen, releasing the waiting clients
+func (h *Handler) unpauseClients(gen int) {
+	h.pauseMu.Lock()
+	defer h.pauseMu.Unlock()
+
+	if h.pauseEnd == nil || (gen >= 0 && gen != h.pauseGen) {
+		return
+	}
+	h.pauseTimer.Stop()
+	close(h.pauseEnd)
+	h.pauseEnd = nil
+	h.paused.Store(false)
+}
+
+// waitUnpaused blocks while clients are paused for the command or
+// subcommand, or until the session is killed
+func (h *Handler) waitUnpaused(sess *Session, spec *commandSpec) {
+	end := h.pausedFor(spec)
+	if end == nil {
+		return
+	}
+	select {
+	case <-end:
+	case <-sess.killed:
+	}
+}
+
+// pausedFor returns the channel closed when the current pause ends if it
+// holds up the command or subcommand, or nil. CLIENT commands are never
+// paused, so that CLIENT UNPAUSE can end a pause of all commands.
+func (h *Handler) pausedFor(spec *commandSpec) <-chan struct{} {
+	if spec.name == "client" || spec.parent != nil && spec.parent.name == "client" {
+		return nil
+	}
+
+	h.pauseMu.Lock()
+	end, all := h.pauseEnd, h.pauseAll
+	h.pauseMu.Unlock()
+
+	if end == nil || !(all || spec.flags&flagWrite != 0) {
+		return nil
+	}
+	return end
+}
+
+// PausedUntil returns nil if the command in args may run now, or else a
+// channel closed once clients are unpaused or sess is killed. Callers
+// that must not block in Exec, such as the executor shared by every
+// client, set the command aside until then.
+func (h *Handler) PausedUntil(sess *Session, args [][]byte) <-chan struct{} {
+	if !h.paused.Load() || len(args) == 0 {
+		return nil
+	}
+	var nameBuf [maxCommandName]byte
+	spec, known := commandSpecs[string(upper(nameBuf[:0], args[0]))]
+	if !known {
+		return nil
+	}
+	end := h.pausedFor(spec.resolve(args))
+	if end == nil {
+		return nil
+	}
+
+	// Killed clients run the command like Exec does, rather than waiting
+	select {
+	case <-sess.killed:
+		return nil
+	default:
+	}
+	resume := make(chan struct{})
+	go func() {
+		select {
+		case <-end:
+		case <-sess.killed:
+		}
+		close(resume)
+	}()
+	return resume
+}
@@ -0,0 +1,229 @@
+package commands
+
+import (
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Shaso41/Backend-SystemFocus/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeConn records whether CLIENT KILL closed it
+type fakeConn struct {
+	closed atomic.Bool
+}
+
+func (c *fakeConn) Close() error {
+	c.closed.Store(true)
+	return nil
+}
+
+func connect(h *Handler, addr string) (*Session, *fakeConn) {
+	sess := h.NewSession()
+	sess.Addr = addr
+	sess.LocalAddr = "127.0.0.1:6379"
+	conn := &fakeConn{}
+	if err := h.Register(sess, conn); err != nil {
+		panic(err)
+	}
+	return sess, conn
+}
+
+func TestHandler_ClientNameAndID(t *testing.T) {
+	s := store.New()
+	defer s.Close()
+	h := NewHandler(s)
+	sess, _ := connect(h, "127.0.0.1:5000")
+
+	result, err := h.Exec(sess, argv("CLIENT", "ID"))
+	assert.NoError(t, err)
+	assert.Equal(t, sess.ID, result)
+
+	result, err = h.Exec(sess, argv("CLIENT", "GETNAME"))
+	assert.NoError(t, err)
+	assert.Nil(t, result)
+
+	_, err = h.Exec(sess, argv("CLIENT", "SETNAME", "bad name"))
+	assert.EqualError(t, err, "ERR Client names cannot contain spaces, newlines or special characters.")
+
+	_, err = h.Exec(sess, argv("CLIENT", "SETNAME", "worker-1"))
+	assert.NoError(t, err)
+	result, err = h.Exec(sess, argv("CLIENT", "GETNAME"))
+	assert.NoError(t, err)
+	assert.Equal(t, BulkString("worker-1"), result)
+
+	result, err = h.Exec(sess, argv("CLIENT", "INFO"))
+	assert.NoError(t, err)
+	info := string(result.(BulkString))
+	assert.True(t, strings.HasPrefix(info, "id="))
+	assert.Contains(t, info, " addr=127.0.0.1:5000 laddr=127.0.0.1:6379 name=worker-1 ")
+	assert.Contains(t, info, " flags=N db=0 ")
+	assert.Contains(t, info, " cmd=client|info user=default resp=2")
+	assert.True(t, strings.HasSuffix(info, "\n"))
+
+	_, err = h.Exec(sess, argv("CLIENT", "NO-EVICT", "on"))
+	assert.NoError(t, err)
+	result, _ = h.Exec(sess, argv("CLIENT", "INFO"))
+	assert.Contains(t, string(result.(BulkString)), " flags=e ")
+}
+
+func TestHandler_ClientList(t *testing.T) {
+	s := store.New()
+	defer s.Close()
+	h := NewHandler(s)
+	a, _ := connect(h, "127.0.0.1:5001")
+	b, _ := connect(h, "127.0.0.1:5002")
+
+	_, err := h.Exec(b, argv("GET", "k"))
+	assert.NoError(t, err)
+
+	result, err := h.Exec(a, argv("CLIENT", "LIST"))
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSuffix(string(result.(BulkString)), "\n"), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], "addr=127.0.0.1:5001")
+	assert.Contains(t, lines[0], "cmd=client|list")
+	assert.Contains(t, lines[1], "addr=127.0.0.1:5002")
+	assert.Contains(t, lines[1], "cmd=get")
+
+	result, err = h.Exec(a, argv("CLIENT", "LIST", "ID", "999", itoa(b.ID)))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(result.(BulkString)), "\n"))
+
+	result, err = h.Exec(a, argv("CLIENT", "LIST", "TYPE", "pubsub"))
+	assert.NoError(t, err)
+	assert.Equal(t, BulkString(""), result)
+
+	_, err = h.Exec(a, argv("CLIENT", "LIST", "TYPE", "bogus"))
+	assert.EqualError(t, err, "ERR Unknown client type 'bogus'")
+
+	h.Unregister(b)
+	result, _ = h.Exec(a, argv("CLIENT", "LIST"))
+	assert.Equal(t, 1, strings.Count(string(result.(BulkString)), "\n"))
+}
+
+func TestHandler_ClientKill(t *testing.T) {
+	s := store.New()
+	defer s.Close()
+	h := NewHandler(s)
+	_, err := h.Execute([]interface{}{"ACL", "SETUSER", "bob", "on", "nopass", "allcommands", "allkeys"})
+	require.NoError(t, err)
+
+	admin, adminConn := connect(h, "127.0.0.1:6000")
+	a, aConn := connect(h, "127.0.0.1:6001")
+	b, bConn := connect(h, "127.0.0.1:6002")
+	_, err = h.Exec(b, argv("AUTH", "bob", "x"))
+	require.NoError(t, err)
+
+	result, err := h.Exec(admin, argv("CLIENT", "KILL", "USER", "bob"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), result)
+	assert.True(t, bConn.closed.Load())
+	assert.False(t, aConn.closed.Load())
+
+	result, err = h.Exec(admin, argv("CLIENT", "KILL", "127.0.0.1:6001"))
+	assert.NoError(t, err)
+	assert.Equal(t, SimpleString("OK"), result)
+	assert.True(t, aConn.closed.Load())
+
+	_, err = h.Exec(admin, argv("CLIENT", "KILL", "127.0.0.1:9999"))
+	assert.EqualError(t, err, "ERR No such client")
+
+	// SKIPME defaults to yes
+	result, err = h.Exec(admin, argv("CLIENT", "KILL", "ID", itoa(admin.ID)))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), result)
+
+	// Killing itself, a client gets its reply before the connection closes
+	result, err = h.Exec(admin, argv("CLIENT", "KILL", "ID", itoa(admin.ID), "SKIPME", "no"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), result)
+	assert.True(t, admin.CloseAfterReply())
+	assert.False(t, adminConn.closed.Load())
+
+	result, err = h.Exec(a, argv("CLIENT", "KILL", "TYPE", "replica"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), result)
+
+	_, err = h.Exec(a, argv("CLIENT", "KILL", "ID", "0"))
+	assert.EqualError(t, err, "ERR client-id should be greater than 0")
+	_, err = h.Exec(a, argv("CLIENT", "KILL", "NOPE", "1"))
+	assert.EqualError(t, err, "ERR syntax error")
+}
+
+func TestHandler_ClientPause(t *testing.T) {
+	s := store.New()
+	defer s.Close()
+	h := NewHandler(s)
+	admin, _ := connect(h, "127.0.0.1:7000")
+	writer, _ := connect(h, "127.0.0.1:7001")
+
+	_, err := h.Exec(admin, argv("CLIENT", "PAUSE", "10000", "WRITE"))
+	require.NoError(t, err)
+
+	// Reads go on while writes wait
+	_, err = h.Exec(writer, argv("GET", "k"))
+	assert.NoError(t, err)
+
+	done := make(chan struct{})
+	go func() {
+		h.Exec(writer, argv("SET", "k", "v"))
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("SET ran while writes were paused")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	_, err = h.Exec(admin, argv("CLIENT", "UNPAUSE"))
+	require.NoError(t, err)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("SET still blocked after CLIENT UNPAUSE")
+	}
+
+	// Pauses end by themselves
+	_, err = h.Exec(admin, argv("CLIENT", "PAUSE", "50"))
+	require.NoError(t, err)
+	start := time.Now()
+	_, err = h.Exec(writer, argv("GET", "k"))
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+
+	_, err = h.Exec(admin, argv("CLIENT", "PAUSE", "abc"))
+	assert.EqualError(t, err, "ERR timeout is not an integer or out of range")
+	_, err = h.Exec(admin, argv("CLIENT", "PAUSE", "10", "READ"))
+	assert.EqualError(t, err, "ERR syntax error")
+}
+
+func itoa(n int64) string {
+	return strconv.FormatInt(n, 10)
+}
+
+func TestHandler_MaxClients(t *testing.T) {
+	s := store.New()
+	defer s.Close()
+	h := NewHandler(s)
+	_, err := h.Execute([]interface{}{"CONFIG", "SET", "maxclients", "2"})
+	require.NoError(t, err)
+
+	a, _ := connect(h, "127.0.0.1:8001")
+	connect(h, "127.0.0.1:8002")
+	assert.ErrorIs(t, h.Register(h.NewSession(), &fakeConn{}), ErrMaxClients)
+
+	h.Unregister(a)
+	assert.NoError(t, h.Register(h.NewSession(), &fakeConn{}))
+
+	result, err := h.Execute([]interface{}{"INFO"})
+	require.NoError(t, err)
+	info := string(result.(BulkString))
+	assert.Contains(t, info, "connected_clients:2\r\nmaxclients:2\r\n")
+	assert.Contains(t, info, "total_connections_received:3\r\n")
+	assert.Contains(t, info, "rejected_connections:1\r\n")
+}
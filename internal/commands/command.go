@@ -0,0 +1,235 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Shaso41/Backend-SystemFocus/internal/glob"
+)
+
+// handleCommand handles COMMAND command
+// COMMAND [COUNT | INFO [name ...] | DOCS [name ...] | GETKEYS command [arg ...] |
+// LIST [FILTERBY MODULE name | ACLCAT category | PATTERN pattern]]
+func (h *Handler) handleCommand(args [][]byte) (interface{}, error) {
+	if len(args) == 1 {
+		return commandInfos(nil), nil
+	}
+
+	switch strings.ToLower(string(args[1])) {
+	case "count":
+		return int64(len(sortedCommands())), nil
+
+	case "info":
+		return commandInfos(args[2:]), nil
+
+	case "docs":
+		reply := Map{}
+		if len(args) == 2 {
+			for _, spec := range sortedCommands() {
+				reply = append(reply, MapEntry{BulkString(spec.name), spec.docMap()})
+			}
+			return reply, nil
+		}
+		// Unknown commands are left out
+		for _, name := range args[2:] {
+			if spec := findCommand(name); spec != nil {
+				reply = append(reply, MapEntry{BulkString(spec.name), spec.docMap()})
+			}
+		}
+		return reply, nil
+
+	case "list":
+		return commandList(args[2:])
+
+	case "getkeys":
+		return commandGetKeys(args[2:])
+
+	default:
+		return nil, fmt.Errorf("ERR unknown subcommand '%s'. Try COMMAND HELP.", args[1])
+	}
+}
+
+// handleHelp handles the HELP subcommand of container commands, listing
+// the subcommands with their summaries
+// <container> HELP
+func (h *Handler) handleHelp(args [][]byte) (interface{}, error) {
+	spec := findCommand(args[0])
+	name := strings.ToUpper(spec.name)
+	reply := []interface{}{SimpleString(name + " <subcommand> [<arg> [value] [opt] ...]. Subcommands are:")}
+	for _, sub := range spec.subcommandNames() {
+		reply = append(reply,
+			SimpleString(strings.ToUpper(sub)),
+			SimpleString("    "+spec.subcommands[sub].docs.summary))
+	}
+	return reply, nil
+}
+
+// commandInfos describes the named commands, with nil for unknown ones, or
+// every command when none are named
+func commandInfos(names [][]byte) []interface{} {
+	if len(names) == 0 {
+		specs := sortedCommands()
+		reply := make([]interface{}, len(specs))
+		for i, spec := range specs {
+			reply[i] = spec.info()
+		}
+		return reply
+	}
+
+	reply := make([]interface{}, len(names))
+	for i, name := range names {
+		if spec := findCommand(name); spec != nil {
+			reply[i] = spec.info()
+		}
+	}
+	return reply
+}
+
+// commandList returns the names of all commands and subcommands, or of
+// those matching a filter
+func commandList(args [][]byte) (interface{}, error) {
+	if len(args) == 0 {
+		return listCommands(func(*commandSpec) bool { return true }), nil
+	}
+	if len(args) != 3 || !strings.EqualFold(string(args[0]), "FILTERBY") {
+		return nil, fmt.Errorf("ERR syntax error")
+	}
+
+	value := string(args[2])
+	var match func(spec *commandSpec) bool
+	switch strings.ToUpper(string(args[1])) {
+	case "MODULE":
+		// Modules are not supported, so none has commands
+		return []string{}, nil
+	case "ACLCAT":
+		match = func(spec *commandSpec) bool {
+			for _, category := range spec.categories {
+				if strings.EqualFold(category, value) {
+					return true
+				}
+			}
+			return false
+		}
+	case "PATTERN":
+		match = func(spec *commandSpec) bool {
+			return glob.MatchFold(value, spec.name)
+		}
+	default:
+		return nil, fmt.Errorf("ERR syntax error")
+	}
+
+	return listCommands(match), nil
+}
+
+// listCommands returns the names of the commands and subcommands match
+// accepts, ordered by name
+func listCommands(match func(spec *commandSpec) bool) []string {
+	names := []string{}
+	for _, spec := range sortedCommands() {
+		if match(spec) {
+			names = append(names, spec.name)
+		}
+		for _, sub := range spec.subcommandNames() {
+			if match(spec.subcommands[sub]) {
+				names = append(names, spec.subcommands[sub].name)
+			}
+		}
+	}
+	return names
+}
+
+// commandGetKeys returns the key arguments of a command line
+func commandGetKeys(args [][]byte) (interface{}, error) {
+	var nameBuf [maxCommandName]byte
+	spec, ok := commandSpecs[string(upper(nameBuf[:0], args[0]))]
+	if !ok {
+		return nil, fmt.Errorf("ERR Invalid command specified")
+	}
+	target := spec.resolve(args)
+	if target.check(args) != nil {
+		return nil, fmt.Errorf("ERR Invalid number of arguments specified for command")
+	}
+
+	keys := target.keys(nil, args)
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("ERR The command has no key arguments")
+	}
+	reply := make([]interface{}, len(keys))
+	for i, key := range keys {
+		reply[i] = BulkString(clone(key))
+	}
+	return reply, nil
+}
+
+// findCommand returns the spec of a command, or of a subcommand named like
+// "config|get", or nil if there is none
+func findCommand(name []byte) *commandSpec {
+	parent, sub, isSub := strings.Cut(string(name), "|")
+	spec, ok := commandSpecs[strings.ToUpper(parent)]
+	if !ok || !isSub {
+		return spec
+	}
+	return spec.subcommands[strings.ToLower(sub)]
+}
+
+// sortedCommands returns the specs of all commands, without their
+// subcommands or aliases, ordered by name
+func sortedCommands() []*commandSpec {
+	specs := make([]*commandSpec, 0, len(commandSpecs))
+	for _, name := range commandNames {
+		if spec, ok := commandSpecs[strings.ToUpper(name)]; ok && spec.aliasOf == "" {
+			specs = append(specs, spec)
+		}
+	}
+	return specs
+}
+
+// info describes the command as COMMAND INFO does: name, arity, flags, key
+// positions, ACL categories, tips, key specifications and subcommands
+func (spec *commandSpec) info() []interface{} {
+	flags := Set{}
+	for _, name := range spec.flags.names() {
+		flags = append(flags, SimpleString(name))
+	}
+	categories := Set{}
+	for _, category := range spec.categories {
+		categories = append(categories, SimpleString("@"+category))
+	}
+	subs := []interface{}{}
+	for _, sub := range spec.subcommandNames() {
+		subs = append(subs, spec.subcommands[sub].info())
+	}
+
+	return []interface{}{
+		BulkString(spec.name),
+		int64(spec.arity),
+		flags,
+		int64(spec.firstKey),
+		int64(spec.lastKey),
+		int64(spec.keyStep),
+		categories,
+		[]interface{}{},
+		[]interface{}{},
+		subs,
+	}
+}
+
+// docMap documents the command as COMMAND DOCS does
+func (spec *commandSpec) docMap() Map {
+	docs := Map{{BulkString("summary"), BulkString(spec.docs.summary)}}
+	if spec.docs.since != "" {
+		docs = append(docs, MapEntry{BulkString("since"), BulkString(spec.docs.since)})
+	}
+	docs = append(docs, MapEntry{BulkString("group"), BulkString(spec.docs.group)})
+	if spec.docs.complexity != "" {
+		docs = append(docs, MapEntry{BulkString("complexity"), BulkString(spec.docs.complexity)})
+	}
+	if spec.subcommands != nil {
+		subs := Map{}
+		for _, sub := range spec.subcommandNames() {
+			subs = append(subs, MapEntry{BulkString(spec.subcommands[sub].name), spec.subcommands[sub].docMap()})
+		}
+		docs = append(docs, MapEntry{BulkString("subcommands"), subs})
+	}
+	return docs
+}
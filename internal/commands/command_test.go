@@ -0,0 +1,240 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Shaso41/Backend-SystemFocus/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommandTable(t *testing.T) {
+	for name, spec := range commandSpecs {
+		assert.Equal(t, strings.ToUpper(spec.name), name)
+		assert.NotNil(t, spec.run, name)
+		assert.NotZero(t, spec.arity, name)
+		assert.NotEmpty(t, spec.docs.summary, name)
+		assert.NotEmpty(t, spec.docs.group, name)
+		assert.Equal(t, spec.name, commandNames[spec.id])
+		if spec.firstKey > 0 {
+			assert.Positive(t, spec.keyStep, name)
+		}
+		if spec.aliasOf != "" {
+			assert.Contains(t, commandSpecs, strings.ToUpper(spec.aliasOf), name)
+		}
+		if spec.subcommands != nil {
+			// Unknown subcommand errors point at HELP
+			assert.Contains(t, spec.subcommands, "help", name)
+		}
+		for sub, subSpec := range spec.subcommands {
+			assert.Equal(t, spec.name+"|"+sub, subSpec.name)
+			assert.Same(t, spec, subSpec.parent)
+			// Only HELP runs apart from its container
+			assert.Equal(t, sub == "help", subSpec.run != nil, subSpec.name)
+			assert.NotEmpty(t, subSpec.categories, subSpec.name)
+			assert.NotEmpty(t, subSpec.docs.summary, subSpec.name)
+			assert.Equal(t, subSpec.name, commandNames[subSpec.id])
+			// The arity counts the subcommand
+			assert.True(t, subSpec.arity >= 2 || subSpec.arity <= -2, subSpec.name)
+		}
+	}
+}
+
+func TestHandler_Arity(t *testing.T) {
+	s := store.New()
+	defer s.Close()
+	h := NewHandler(s)
+
+	for _, tc := range []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"GET"}, "ERR wrong number of arguments for 'get' command"},
+		{[]string{"get", "k", "extra"}, "ERR wrong number of arguments for 'get' command"},
+		{[]string{"SET", "k"}, "ERR wrong number of arguments for 'set' command"},
+		{[]string{"BITOP", "AND", "dest"}, "ERR wrong number of arguments for 'bitop' command"},
+		{[]string{"MONITOR", "now"}, "ERR wrong number of arguments for 'monitor' command"},
+		{[]string{"CLIENT"}, "ERR wrong number of arguments for 'client' command"},
+		{[]string{"client", "setname"}, "ERR wrong number of arguments for 'client|setname' command"},
+		{[]string{"CONFIG", "SET", "maxclients"}, "ERR wrong number of arguments for 'config|set' command"},
+		{[]string{"LATENCY", "HISTORY"}, "ERR wrong number of arguments for 'latency|history' command"},
+		{[]string{"acl", "nope"}, "ERR unknown subcommand 'nope'. Try ACL HELP."},
+		{[]string{"COMMAND", "nope"}, "ERR unknown subcommand 'nope'. Try COMMAND HELP."},
+	} {
+		_, err := h.Exec(&Session{Protocol: 2}, argv(tc.args...))
+		assert.EqualError(t, err, tc.err, tc.args)
+	}
+
+	// Arity errors are rejected calls, reported before authentication
+	stats := info(t, h, "commandstats")
+	assert.Contains(t, stats, "cmdstat_get:calls=0,usec=0,usec_per_call=0.00,rejected_calls=2,failed_calls=0\r\n")
+	assert.Contains(t, stats, "cmdstat_client|setname:calls=0,usec=0,usec_per_call=0.00,rejected_calls=1,")
+	assert.Contains(t, stats, "cmdstat_acl:calls=0,usec=0,usec_per_call=0.00,rejected_calls=1,")
+}
+
+func TestHandler_Command(t *testing.T) {
+	s := store.New()
+	defer s.Close()
+	h := NewHandler(s)
+
+	result, err := h.Execute([]interface{}{"COMMAND", "COUNT"})
+	require.NoError(t, err)
+	count := result.(int64)
+	// DELETE is an alias of DEL, and not counted
+	assert.Equal(t, int64(len(commandSpecs)-1), count)
+
+	result, err = h.Execute([]interface{}{"COMMAND"})
+	require.NoError(t, err)
+	assert.Len(t, result, int(count))
+
+	// Aliases can still be asked for by name
+	result, err = h.Execute([]interface{}{"COMMAND", "INFO", "delete"})
+	require.NoError(t, err)
+	assert.Equal(t, BulkString("delete"), result.([]interface{})[0].([]interface{})[0])
+
+	result, err = h.Execute([]interface{}{"COMMAND", "INFO", "get", "nope", "BITOP"})
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{
+		[]interface{}{
+			BulkString("get"), int64(2), Set{SimpleString("readonly"), SimpleString("fast")},
+			int64(1), int64(1), int64(1),
+			Set{SimpleString("@read"), SimpleString("@string"), SimpleString("@fast")},
+			[]interface{}{}, []interface{}{}, []interface{}{},
+		},
+		nil,
+		[]interface{}{
+			BulkString("bitop"), int64(-4), Set{SimpleString("write"), SimpleString("denyoom")},
+			int64(2), int64(-1), int64(1),
+			Set{SimpleString("@write"), SimpleString("@bitmap"), SimpleString("@slow")},
+			[]interface{}{}, []interface{}{}, []interface{}{},
+		},
+	}, result)
+
+	// Container commands list their subcommands, which can be asked for
+	result, err = h.Execute([]interface{}{"COMMAND", "INFO", "slowlog", "config|get"})
+	require.NoError(t, err)
+	slowlog := result.([]interface{})[0].([]interface{})
+	assert.Equal(t, BulkString("slowlog"), slowlog[0])
+	assert.Equal(t, int64(-2), slowlog[1])
+	subs := slowlog[9].([]interface{})
+	require.Len(t, subs, 4)
+	assert.Equal(t, BulkString("slowlog|get"), subs[0].([]interface{})[0])
+	configGet := result.([]interface{})[1].([]interface{})
+	assert.Equal(t, BulkString("config|get"), configGet[0])
+	assert.Equal(t, int64(-3), configGet[1])
+	assert.Contains(t, configGet[2], SimpleString("admin"))
+}
+
+func TestHandler_CommandDocs(t *testing.T) {
+	s := store.New()
+	defer s.Close()
+	h := NewHandler(s)
+
+	result, err := h.Execute([]interface{}{"COMMAND", "DOCS", "get", "nope"})
+	require.NoError(t, err)
+	assert.Equal(t, Map{{BulkString("get"), Map{
+		{BulkString("summary"), BulkString("Returns the string value of a key.")},
+		{BulkString("since"), BulkString("1.0.0")},
+		{BulkString("group"), BulkString("string")},
+		{BulkString("complexity"), BulkString("O(1)")},
+	}}}, result)
+
+	result, err = h.Execute([]interface{}{"COMMAND", "DOCS", "latency"})
+	require.NoError(t, err)
+	docs := result.(Map)[0].Value.(Map)
+	subs := docs[len(docs)-1]
+	assert.Equal(t, BulkString("subcommands"), subs.Key)
+	assert.Equal(t, BulkString("latency|help"), subs.Value.(Map)[0].Key)
+
+	result, err = h.Execute([]interface{}{"COMMAND", "DOCS"})
+	require.NoError(t, err)
+	assert.Len(t, result, len(commandSpecs)-1)
+}
+
+func TestHandler_CommandList(t *testing.T) {
+	s := store.New()
+	defer s.Close()
+	h := NewHandler(s)
+
+	result, err := h.Execute([]interface{}{"COMMAND", "LIST"})
+	require.NoError(t, err)
+	assert.Len(t, result, len(commandNames)-1)
+	assert.Contains(t, result, "client|list")
+	assert.Contains(t, result, "del")
+	assert.NotContains(t, result, "delete")
+
+	result, err = h.Execute([]interface{}{"COMMAND", "LIST", "FILTERBY", "PATTERN", "BIT*"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bitcount", "bitfield", "bitfield_ro", "bitop", "bitpos"}, result)
+
+	result, err = h.Execute([]interface{}{"COMMAND", "LIST", "FILTERBY", "ACLCAT", "admin"})
+	require.NoError(t, err)
+	assert.Contains(t, result, "config|set")
+	assert.Contains(t, result, "monitor")
+	assert.NotContains(t, result, "get")
+
+	result, err = h.Execute([]interface{}{"COMMAND", "LIST", "FILTERBY", "MODULE", "json"})
+	require.NoError(t, err)
+	assert.Empty(t, result)
+
+	_, err = h.Execute([]interface{}{"COMMAND", "LIST", "FILTERBY", "NAME", "get"})
+	assert.EqualError(t, err, "ERR syntax error")
+	_, err = h.Execute([]interface{}{"COMMAND", "LIST", "PATTERN"})
+	assert.EqualError(t, err, "ERR syntax error")
+}
+
+func TestHandler_CommandGetKeys(t *testing.T) {
+	s := store.New()
+	defer s.Close()
+	h := NewHandler(s)
+
+	result, err := h.Execute([]interface{}{"COMMAND", "GETKEYS", "SET", "k", "v", "EX", "10"})
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{BulkString("k")}, result)
+
+	result, err = h.Execute([]interface{}{"COMMAND", "GETKEYS", "bitop", "AND", "dest", "a", "b"})
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{BulkString("dest"), BulkString("a"), BulkString("b")}, result)
+
+	_, err = h.Execute([]interface{}{"COMMAND", "GETKEYS", "PING"})
+	assert.EqualError(t, err, "ERR The command has no key arguments")
+	_, err = h.Execute([]interface{}{"COMMAND", "GETKEYS", "NOPE", "k"})
+	assert.EqualError(t, err, "ERR Invalid command specified")
+	_, err = h.Execute([]interface{}{"COMMAND", "GETKEYS", "GET"})
+	assert.EqualError(t, err, "ERR Invalid number of arguments specified for command")
+	_, err = h.Execute([]interface{}{"COMMAND", "GETKEYS"})
+	assert.EqualError(t, err, "ERR wrong number of arguments for 'command|getkeys' command")
+}
+
+func TestHandler_Help(t *testing.T) {
+	s := store.New()
+	defer s.Close()
+	h := NewHandler(s)
+
+	result, err := h.Execute([]interface{}{"slowlog", "help"})
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{
+		SimpleString("SLOWLOG <subcommand> [<arg> [value] [opt] ...]. Subcommands are:"),
+		SimpleString("GET"),
+		SimpleString("    Returns the slow log's entries."),
+		SimpleString("HELP"),
+		SimpleString("    Returns helpful text about the different subcommands."),
+		SimpleString("LEN"),
+		SimpleString("    Returns the number of entries in the slow log."),
+		SimpleString("RESET"),
+		SimpleString("    Clears all entries from the slow log."),
+	}, result)
+
+	// Every container has one, as unknown subcommand errors suggest
+	for _, name := range []string{"ACL", "CLIENT", "COMMAND", "CONFIG", "LATENCY"} {
+		result, err = h.Execute([]interface{}{name, "HELP"})
+		require.NoError(t, err, name)
+		assert.Equal(t, SimpleString(name+" <subcommand> [<arg> [value] [opt] ...]. Subcommands are:"),
+			result.([]interface{})[0])
+	}
+
+	_, err = h.Execute([]interface{}{"CONFIG", "HELP", "extra"})
+	assert.EqualError(t, err, "ERR wrong number of arguments for 'config|help' command")
+	assert.Contains(t, info(t, h, "commandstats"), "cmdstat_slowlog|help:calls=1,")
+}
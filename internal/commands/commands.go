@@ -1,115 +1,285 @@
 package commands
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/Shaso41/Backend-SystemFocus/internal/acl"
+	"github.com/Shaso41/Backend-SystemFocus/internal/config"
+	"github.com/Shaso41/Backend-SystemFocus/internal/glob"
 	"github.com/Shaso41/Backend-SystemFocus/internal/store"
 )
 
+// redisVersion is the Redis version this server reports compatibility with
+const redisVersion = "7.0.0-clone"
+
+// maxCommandName is the longest command name upper-cased without allocating
+const maxCommandName = 32
+
 // SimpleString represents a RESP simple string response
 type SimpleString string
 
-// BulkString represents a RESP bulk string response
-type BulkString string
+// BulkString represents a binary-safe RESP bulk string response
+type BulkString []byte
 
 // Handler processes commands and returns responses
 type Handler struct {
-	store *store.Store
+	started      time.Time
+	runID        string
+	store        *store.Store
+	acl          *acl.ACL
+	config       *config.Config
+	nextClientID atomic.Int64
+
+	// Counters reported by INFO stats and cleared by CONFIG RESETSTAT
+	commandsProcessed atomic.Int64
+	errorReplies      atomic.Int64
+
+	resetMu    sync.Mutex
+	resetHooks []func()
+
+	clientsMu           sync.RWMutex
+	clients             map[int64]*Session
+	maxClients          *config.Value[int64]
+	totalConnections    atomic.Int64
+	rejectedConnections atomic.Int64
+
+	// CLIENT PAUSE state; paused is checked by every command
+	paused     atomic.Bool
+	pauseMu    sync.Mutex
+	pauseAll   bool
+	pauseEnd   chan struct{}
+	pauseTimer *time.Timer
+	pauseGen   int
+
+	// Per-command statistics indexed by command id
+	commandStats    []commandStats
+	latencyTracking *config.Value[bool]
+	latencyInfo     *config.Value[[]float64]
+	latency         *latencyMonitor
+	slowlog         *slowLog
+
+	// Clients that ran MONITOR; commands only look at monitorCount unless
+	// someone is monitoring
+	monitorCount atomic.Int32
+	monitorsMu   sync.RWMutex
+	monitors     map[int64]*Session
+
+	lazyFree lazyFreeParams
 }
 
 // NewHandler creates a new command handler
 func NewHandler(s *store.Store) *Handler {
-	return &Handler{store: s}
+	h := &Handler{
+		started:  time.Now(),
+		runID:    newRunID(),
+		store:    s,
+		acl:      acl.New(aclCommands()),
+		config:   config.New(),
+		clients:  make(map[int64]*Session),
+		monitors: make(map[int64]*Session),
+	}
+	h.maxClients = h.config.Int(config.Spec{Name: "maxclients", Mutable: true,
+		Doc: "Most clients connected at once; further connections are refused"}, 10000, 1, math.MaxInt32)
+	h.commandStats = make([]commandStats, len(commandNames))
+	h.latencyTracking = h.config.Bool(config.Spec{Name: "latency-tracking", Mutable: true,
+		Doc: "Track per-command latency histograms"}, true)
+	h.latencyInfo = registerPercentiles(h.config)
+	h.latency = newLatencyMonitor(h.config)
+	s.OnExpireCycle(func(elapsed time.Duration) {
+		h.latency.add("expire-cycle", elapsed)
+	})
+	h.slowlog = newSlowLog(h.config)
+	h.lazyFree = h.registerLazyFree()
+	return h
 }
 
-// Execute processes a command and returns a response
+// newRunID returns a random identifier of this run of the server, 40 hex
+// characters like Redis' run_id
+func newRunID() string {
+	id := make([]byte, 20)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// ACL returns the users and permissions commands are checked against
+func (h *Handler) ACL() *acl.ACL {
+	return h.acl
+}
+
+// Config returns the configuration parameters CONFIG GET and SET work on.
+// Parameters are registered by the components they configure.
+func (h *Handler) Config() *config.Config {
+	return h.config
+}
+
+// OnResetStats registers fn to clear statistics kept outside the handler
+// when CONFIG RESETSTAT runs
+func (h *Handler) OnResetStats(fn func()) {
+	h.resetMu.Lock()
+	defer h.resetMu.Unlock()
+
+	h.resetHooks = append(h.resetHooks, fn)
+}
+
+// ResetStats clears the handler's statistics and those registered with
+// OnResetStats
+func (h *Handler) ResetStats() {
+	h.commandsProcessed.Store(0)
+	h.errorReplies.Store(0)
+	h.totalConnections.Store(0)
+	h.rejectedConnections.Store(0)
+	h.store.ResetStats()
+	h.resetCommandStats()
+
+	h.resetMu.Lock()
+	defer h.resetMu.Unlock()
+
+	for _, fn := range h.resetHooks {
+		fn()
+	}
+}
+
+// Execute processes a command given as strings or byte slices, outside of
+// any client connection, as the default user, and returns a response
 func (h *Handler) Execute(args []interface{}) (interface{}, error) {
+	argv := make([][]byte, len(args))
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case []byte:
+			argv[i] = v
+		case string:
+			argv[i] = []byte(v)
+		default:
+			return nil, fmt.Errorf("ERR invalid argument type")
+		}
+	}
+	return h.Exec(&Session{Protocol: 2, User: acl.DefaultUser}, argv)
+}
+
+// Exec processes a command on behalf of the client session sess and
+// returns a response. The arguments may be reused by the caller once Exec
+// returns, so anything kept, such as stored values, is copied.
+func (h *Handler) Exec(sess *Session, args [][]byte) (interface{}, error) {
+	result, err := h.exec(sess, args)
+	h.commandsProcessed.Add(1)
+	if err != nil {
+		h.errorReplies.Add(1)
+	}
+	return result, err
+}
+
+// exec looks up, authorizes and runs a command
+func (h *Handler) exec(sess *Session, args [][]byte) (interface{}, error) {
 	if len(args) == 0 {
 		return nil, fmt.Errorf("ERR empty command")
 	}
 
-	// Convert command to uppercase
-	cmd, ok := args[0].(string)
-	if !ok {
-		return nil, fmt.Errorf("ERR invalid command type")
-	}
-	cmd = strings.ToUpper(cmd)
-
-	// Route to appropriate handler
-	switch cmd {
-	case "PING":
-		return h.handlePing(args)
-	case "SET":
-		return h.handleSet(args)
-	case "GET":
-		return h.handleGet(args)
-	case "DELETE", "DEL":
-		return h.handleDelete(args)
-	case "EXISTS":
-		return h.handleExists(args)
-	case "KEYS":
-		return h.handleKeys(args)
-	case "EXPIRE":
-		return h.handleExpire(args)
-	case "TTL":
-		return h.handleTTL(args)
-	case "INFO":
-		return h.handleInfo(args)
-	default:
-		return nil, fmt.Errorf("ERR unknown command '%s'", cmd)
+	// Convert command to uppercase, on the stack for known command lengths
+	var nameBuf [maxCommandName]byte
+	cmd := upper(nameBuf[:0], args[0])
+
+	spec, known := commandSpecs[string(cmd)]
+	if !known {
+		return nil, fmt.Errorf("ERR unknown command '%s'", string(cmd))
+	}
+
+	target := spec.resolve(args)
+	sess.cmd.Store(&commandNames[target.id])
+	stats := &h.commandStats[target.id]
+
+	if err := target.check(args); err != nil {
+		stats.rejected.Add(1)
+		return nil, err
+	}
+	if err := h.authorize(sess, spec, args); err != nil {
+		stats.rejected.Add(1)
+		return nil, err
+	}
+	if h.paused.Load() {
+		h.waitUnpaused(sess, target)
+	}
+
+	if h.monitorCount.Load() > 0 {
+		h.feedMonitors(sess, target, args)
+	}
+
+	run := spec.run
+	if target.run != nil {
+		run = target.run
+	}
+	start := monotonic()
+	result, err := run(h, sess, args)
+	duration := monotonic() - start
+
+	h.record(stats, duration, err != nil)
+	h.slowlog.log(sess, args, duration)
+	if h.latency.threshold.Get() > 0 {
+		h.latency.command(target, duration)
+	}
+	return result, err
+}
+
+// upper appends the ASCII upper-case form of b to dst
+func upper(dst, b []byte) []byte {
+	for _, c := range b {
+		if 'a' <= c && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		dst = append(dst, c)
+	}
+	return dst
+}
+
+// lower appends the ASCII lower-case form of b to dst
+func lower(dst, b []byte) []byte {
+	for _, c := range b {
+		if 'A' <= c && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		dst = append(dst, c)
 	}
+	return dst
+}
+
+// clone returns a copy of an argument that outlives the command
+func clone(b []byte) []byte {
+	return append([]byte(nil), b...)
 }
 
 // handlePing handles PING command
-func (h *Handler) handlePing(args []interface{}) (interface{}, error) {
+func (h *Handler) handlePing(args [][]byte) (interface{}, error) {
 	if len(args) == 1 {
 		return SimpleString("PONG"), nil
 	}
 	if len(args) == 2 {
-		msg, ok := args[1].(string)
-		if !ok {
-			return nil, fmt.Errorf("ERR invalid argument")
-		}
-		return BulkString(msg), nil
+		return BulkString(clone(args[1])), nil
 	}
 	return nil, fmt.Errorf("ERR wrong number of arguments for 'ping' command")
 }
 
 // handleSet handles SET command
 // SET key value [EX seconds]
-func (h *Handler) handleSet(args []interface{}) (interface{}, error) {
-	if len(args) < 3 {
-		return nil, fmt.Errorf("ERR wrong number of arguments for 'set' command")
-	}
-
-	key, ok := args[1].(string)
-	if !ok {
-		return nil, fmt.Errorf("ERR invalid key")
-	}
-
-	value, ok := args[2].(string)
-	if !ok {
-		return nil, fmt.Errorf("ERR invalid value")
-	}
+func (h *Handler) handleSet(args [][]byte) (interface{}, error) {
+	key := string(args[1])
+	value := clone(args[2])
 
 	var expiration time.Duration
 
 	// Parse optional EX parameter
 	if len(args) >= 5 {
-		exFlag, ok := args[3].(string)
-		if !ok || strings.ToUpper(exFlag) != "EX" {
+		if !strings.EqualFold(string(args[3]), "EX") {
 			return nil, fmt.Errorf("ERR syntax error")
 		}
 
-		seconds, ok := args[4].(string)
-		if !ok {
-			return nil, fmt.Errorf("ERR invalid expire time")
-		}
-
-		sec, err := strconv.Atoi(seconds)
+		sec, err := strconv.Atoi(string(args[4]))
 		if err != nil {
 			return nil, fmt.Errorf("ERR invalid expire time")
 		}
@@ -122,15 +292,8 @@ func (h *Handler) handleSet(args []interface{}) (interface{}, error) {
 }
 
 // handleGet handles GET command
-func (h *Handler) handleGet(args []interface{}) (interface{}, error) {
-	if len(args) != 2 {
-		return nil, fmt.Errorf("ERR wrong number of arguments for 'get' command")
-	}
-
-	key, ok := args[1].(string)
-	if !ok {
-		return nil, fmt.Errorf("ERR invalid key")
-	}
+func (h *Handler) handleGet(args [][]byte) (interface{}, error) {
+	key := string(args[1])
 
 	value, exists := h.store.Get(key)
 	if !exists {
@@ -141,14 +304,10 @@ func (h *Handler) handleGet(args []interface{}) (interface{}, error) {
 }
 
 // handleDelete handles DELETE/DEL command
-func (h *Handler) handleDelete(args []interface{}) (interface{}, error) {
-	if len(args) != 2 {
-		return nil, fmt.Errorf("ERR wrong number of arguments for 'del' command")
-	}
-
-	key, ok := args[1].(string)
-	if !ok {
-		return nil, fmt.Errorf("ERR invalid key")
+func (h *Handler) handleDelete(args [][]byte) (interface{}, error) {
+	key := string(args[1])
+	if h.lazyFree.userDel.Get() {
+		return int64(h.store.Unlink(key)), nil
 	}
 
 	deleted := h.store.Delete(key)
@@ -159,15 +318,8 @@ func (h *Handler) handleDelete(args []interface{}) (interface{}, error) {
 }
 
 // handleExists handles EXISTS command
-func (h *Handler) handleExists(args []interface{}) (interface{}, error) {
-	if len(args) != 2 {
-		return nil, fmt.Errorf("ERR wrong number of arguments for 'exists' command")
-	}
-
-	key, ok := args[1].(string)
-	if !ok {
-		return nil, fmt.Errorf("ERR invalid key")
-	}
+func (h *Handler) handleExists(args [][]byte) (interface{}, error) {
+	key := string(args[1])
 
 	if h.store.Exists(key) {
 		return int64(1), nil
@@ -176,37 +328,60 @@ func (h *Handler) handleExists(args []interface{}) (interface{}, error) {
 }
 
 // handleKeys handles KEYS command
-func (h *Handler) handleKeys(args []interface{}) (interface{}, error) {
-	if len(args) != 2 {
-		return nil, fmt.Errorf("ERR wrong number of arguments for 'keys' command")
-	}
-
-	pattern, ok := args[1].(string)
-	if !ok {
-		return nil, fmt.Errorf("ERR invalid pattern")
-	}
-
-	keys := h.store.Keys(pattern)
+func (h *Handler) handleKeys(args [][]byte) (interface{}, error) {
+	keys := h.store.Keys(string(args[1]))
 	return keys, nil
 }
 
-// handleExpire handles EXPIRE command
-func (h *Handler) handleExpire(args []interface{}) (interface{}, error) {
-	if len(args) != 3 {
-		return nil, fmt.Errorf("ERR wrong number of arguments for 'expire' command")
+// handleScan handles SCAN command. MATCH filters the keys a call collected,
+// so calls may return fewer than COUNT keys, or none, before the cursor
+// gets back to 0.
+// SCAN cursor [MATCH pattern] [COUNT count]
+func (h *Handler) handleScan(args [][]byte) (interface{}, error) {
+	cursor, err := strconv.ParseUint(string(args[1]), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("ERR invalid cursor")
 	}
 
-	key, ok := args[1].(string)
-	if !ok {
-		return nil, fmt.Errorf("ERR invalid key")
+	pattern, count := "", 10
+	for i := 2; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return nil, fmt.Errorf("ERR syntax error")
+		}
+		switch strings.ToUpper(string(args[i])) {
+		case "MATCH":
+			pattern = string(args[i+1])
+		case "COUNT":
+			count, err = strconv.Atoi(string(args[i+1]))
+			if err != nil {
+				return nil, fmt.Errorf("ERR value is not an integer or out of range")
+			}
+			if count < 1 {
+				return nil, fmt.Errorf("ERR syntax error")
+			}
+		default:
+			return nil, fmt.Errorf("ERR syntax error")
+		}
 	}
 
-	seconds, ok := args[2].(string)
-	if !ok {
-		return nil, fmt.Errorf("ERR invalid expire time")
+	next, keys := h.store.Scan(cursor, count)
+	if pattern != "" && pattern != "*" {
+		matched := keys[:0]
+		for _, key := range keys {
+			if glob.Match(pattern, key) {
+				matched = append(matched, key)
+			}
+		}
+		keys = matched
 	}
+	return []interface{}{BulkString(strconv.FormatUint(next, 10)), keys}, nil
+}
 
-	sec, err := strconv.Atoi(seconds)
+// handleExpire handles EXPIRE command
+func (h *Handler) handleExpire(args [][]byte) (interface{}, error) {
+	key := string(args[1])
+
+	sec, err := strconv.Atoi(string(args[2]))
 	if err != nil {
 		return nil, fmt.Errorf("ERR invalid expire time")
 	}
@@ -219,29 +394,9 @@ func (h *Handler) handleExpire(args []interface{}) (interface{}, error) {
 }
 
 // handleTTL handles TTL command
-func (h *Handler) handleTTL(args []interface{}) (interface{}, error) {
-	if len(args) != 2 {
-		return nil, fmt.Errorf("ERR wrong number of arguments for 'ttl' command")
-	}
-
-	key, ok := args[1].(string)
-	if !ok {
-		return nil, fmt.Errorf("ERR invalid key")
-	}
+func (h *Handler) handleTTL(args [][]byte) (interface{}, error) {
+	key := string(args[1])
 
 	ttl := h.store.TTL(key)
 	return ttl, nil
 }
-
-// handleInfo handles INFO command
-func (h *Handler) handleInfo(args []interface{}) (interface{}, error) {
-	info := fmt.Sprintf("# Server\r\n"+
-		"redis_version:7.0.0-clone\r\n"+
-		"redis_mode:standalone\r\n"+
-		"os:Custom\r\n"+
-		"# Keyspace\r\n"+
-		"db0:keys=%d\r\n",
-		h.store.Count())
-
-	return BulkString(info), nil
-}
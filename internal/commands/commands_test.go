@@ -1,6 +1,10 @@
 package commands
 
 import (
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/Shaso41/Backend-SystemFocus/internal/store"
@@ -98,6 +102,50 @@ func TestHandler_Keys(t *testing.T) {
 	assert.Equal(t, 2, len(keys))
 }
 
+func TestHandler_Scan(t *testing.T) {
+	s := store.New()
+	defer s.Close()
+	h := NewHandler(s)
+
+	for i := 0; i < 100; i++ {
+		h.Execute([]interface{}{"SET", "user:" + strconv.Itoa(i), "v"})
+		h.Execute([]interface{}{"SET", "session:" + strconv.Itoa(i), "v"})
+	}
+
+	// A full scan with MATCH returns every matching key
+	seen := make(map[string]bool)
+	cursor := "0"
+	for {
+		result, err := h.Execute([]interface{}{"SCAN", cursor, "MATCH", "user:*", "COUNT", "20"})
+		assert.NoError(t, err)
+		reply := result.([]interface{})
+		cursor = string(reply[0].(BulkString))
+		for _, key := range reply[1].([]string) {
+			assert.True(t, strings.HasPrefix(key, "user:"), key)
+			seen[key] = true
+		}
+		if cursor == "0" {
+			break
+		}
+	}
+	assert.Len(t, seen, 100)
+
+	for _, tc := range []struct {
+		args []interface{}
+		err  string
+	}{
+		{[]interface{}{"SCAN", "x"}, "ERR invalid cursor"},
+		{[]interface{}{"SCAN", "-1"}, "ERR invalid cursor"},
+		{[]interface{}{"SCAN", "0", "COUNT", "0"}, "ERR syntax error"},
+		{[]interface{}{"SCAN", "0", "COUNT", "x"}, "ERR value is not an integer or out of range"},
+		{[]interface{}{"SCAN", "0", "MATCH"}, "ERR syntax error"},
+		{[]interface{}{"SCAN", "0", "TYPE", "string"}, "ERR syntax error"},
+	} {
+		_, err := h.Execute(tc.args)
+		assert.EqualError(t, err, tc.err, tc.args)
+	}
+}
+
 func TestHandler_SetWithExpiration(t *testing.T) {
 	s := store.New()
 	defer s.Close()
@@ -157,6 +205,64 @@ func TestHandler_Info(t *testing.T) {
 	assert.Contains(t, string(info), "redis_version")
 }
 
+func TestHandler_InfoSections(t *testing.T) {
+	s := store.New()
+	defer s.Close()
+	h := NewHandler(s)
+
+	h.Execute([]interface{}{"SET", "k", "v"})
+	h.Execute([]interface{}{"SET", "temp", "v", "EX", "100"})
+	h.Execute([]interface{}{"GET", "k"})
+	h.Execute([]interface{}{"GET", "missing"})
+
+	defaults := info(t, h)
+	for _, header := range []string{"# Server", "# Clients", "# Memory", "# Persistence", "# Stats", "# Replication", "# CPU", "# Keyspace"} {
+		assert.Contains(t, defaults, header+"\r\n")
+	}
+	assert.NotContains(t, defaults, "# Commandstats")
+
+	server := info(t, h, "server")
+	assert.True(t, strings.HasPrefix(server, "# Server\r\n"))
+	assert.NotContains(t, server, "# Clients")
+	assert.Contains(t, server, "process_id:"+strconv.Itoa(os.Getpid())+"\r\n")
+	assert.Contains(t, server, "arch_bits:"+strconv.Itoa(strconv.IntSize)+"\r\n")
+	assert.Regexp(t, `os:\S+.* `+runtime.GOARCH+`\r\n`, server)
+	assert.Regexp(t, `run_id:[0-9a-f]{40}\r\n`, server)
+	assert.Contains(t, server, "uptime_in_seconds:0\r\n")
+
+	stats := info(t, h, "stats")
+	assert.Contains(t, stats, "keyspace_hits:1\r\n")
+	assert.Contains(t, stats, "keyspace_misses:1\r\n")
+	assert.Contains(t, stats, "expired_keys:0\r\n")
+
+	assert.Regexp(t, `used_memory:[1-9]\d*\r\nused_memory_human:[\d.]+[BKMG]\r\n`, info(t, h, "memory"))
+	persistence := info(t, h, "persistence")
+	assert.Contains(t, persistence, "rdb_changes_since_last_save:0\r\n")
+	assert.Contains(t, persistence, "rdb_last_save_time:0\r\n")
+	assert.Regexp(t, `used_cpu_sys:[\d.]+\r\nused_cpu_user:[\d.]+\r\n`, info(t, h, "cpu"))
+	assert.Contains(t, info(t, h, "keyspace"), "db0:keys=2,expires=1,avg_ttl=0\r\n")
+	assert.Equal(t, "# Replication\r\nrole:master\r\nconnected_slaves:0\r\nmaster_repl_offset:0\r\n\r\n# Keyspace\r\ndb0:keys=2,expires=1,avg_ttl=0\r\n",
+		info(t, h, "keyspace", "REPLICATION"))
+
+	everything := info(t, h, "everything")
+	assert.Contains(t, everything, "# Commandstats")
+	assert.Contains(t, everything, "# Persistence")
+
+	// Unknown sections are left out, and an empty keyspace has no lines
+	h.Execute([]interface{}{"DEL", "k"})
+	h.Execute([]interface{}{"DEL", "temp"})
+	assert.Equal(t, "# Keyspace\r\n", info(t, h, "keyspace", "nope"))
+	assert.Equal(t, "", info(t, h, "nope"))
+}
+
+func TestHumanBytes(t *testing.T) {
+	assert.Equal(t, "0B", humanBytes(0))
+	assert.Equal(t, "1023B", humanBytes(1023))
+	assert.Equal(t, "1.00K", humanBytes(1024))
+	assert.Equal(t, "1.50M", humanBytes(1536*1024))
+	assert.Equal(t, "2.00G", humanBytes(2<<30))
+}
+
 func TestHandler_UnknownCommand(t *testing.T) {
 	s := store.New()
 	defer s.Close()
@@ -166,3 +272,92 @@ func TestHandler_UnknownCommand(t *testing.T) {
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "unknown command")
 }
+
+func TestHandler_Hello(t *testing.T) {
+	s := store.New()
+	defer s.Close()
+	h := NewHandler(s)
+	sess := h.NewSession()
+
+	result, err := h.Exec(sess, argv("HELLO", "3", "SETNAME", "worker-1"))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, sess.Protocol)
+	assert.Equal(t, "worker-1", sess.Name)
+
+	reply, ok := result.(Map)
+	assert.True(t, ok)
+	assert.Equal(t, MapEntry{BulkString("proto"), int64(3)}, reply[2])
+	assert.Equal(t, MapEntry{BulkString("id"), sess.ID}, reply[3])
+
+	// HELLO without arguments keeps the current protocol
+	_, err = h.Exec(sess, argv("HELLO"))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, sess.Protocol)
+
+	_, err = h.Exec(sess, argv("HELLO", "4"))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "NOPROTO")
+	assert.Equal(t, 3, sess.Protocol)
+
+	_, err = h.Exec(sess, argv("HELLO", "2", "SETNAME", "bad name"))
+	assert.Error(t, err)
+
+	_, err = h.Exec(sess, argv("HELLO", "2", "AUTH", "alice", "secret"))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "WRONGPASS")
+}
+
+func TestHandler_BinarySafeValues(t *testing.T) {
+	s := store.New()
+	defer s.Close()
+	h := NewHandler(s)
+
+	value := []byte("a\r\nb\x00c")
+	result, err := h.Execute([]interface{}{[]byte("SET"), []byte("k\x00"), value})
+	assert.NoError(t, err)
+	assert.Equal(t, SimpleString("OK"), result)
+
+	result, err = h.Execute([]interface{}{[]byte("GET"), []byte("k\x00")})
+	assert.NoError(t, err)
+	assert.Equal(t, BulkString(value), result)
+}
+
+// argv builds a command argument vector as the parser delivers it
+func argv(args ...string) [][]byte {
+	v := make([][]byte, len(args))
+	for i, arg := range args {
+		v[i] = []byte(arg)
+	}
+	return v
+}
+
+func BenchmarkHandler_Get(b *testing.B) {
+	s := store.New()
+	defer s.Close()
+	h := NewHandler(s)
+	sess := h.NewSession()
+
+	h.Exec(sess, argv("SET", "user:100", "0123456789abcdef"))
+	args := argv("GET", "user:100")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h.Exec(sess, args)
+	}
+}
+
+func BenchmarkHandler_Set(b *testing.B) {
+	s := store.New()
+	defer s.Close()
+	h := NewHandler(s)
+	sess := h.NewSession()
+
+	args := argv("SET", "user:100", "0123456789abcdef")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h.Exec(sess, args)
+	}
+}
@@ -0,0 +1,50 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+)
+
+// handleConfig handles CONFIG command
+// CONFIG GET pattern [pattern ...] | SET name value [name value ...] |
+// REWRITE | RESETSTAT
+func (h *Handler) handleConfig(args [][]byte) (interface{}, error) {
+	sub := strings.ToLower(string(args[1]))
+
+	switch sub {
+	case "get":
+		patterns := make([]string, len(args)-2)
+		for i, arg := range args[2:] {
+			patterns[i] = string(arg)
+		}
+		pairs := h.config.Get(patterns...)
+		reply := make(Map, len(pairs))
+		for i, pair := range pairs {
+			reply[i] = MapEntry{BulkString(pair[0]), BulkString(pair[1])}
+		}
+		return reply, nil
+
+	case "set":
+		pairs := make([]string, len(args)-2)
+		for i, arg := range args[2:] {
+			pairs[i] = string(arg)
+		}
+		if err := h.config.SetRuntime(pairs...); err != nil {
+			return nil, fmt.Errorf("ERR %v", err)
+		}
+		return SimpleString("OK"), nil
+
+	case "rewrite":
+		if err := h.config.Rewrite(); err != nil {
+			return nil, fmt.Errorf("ERR %v", err)
+		}
+		return SimpleString("OK"), nil
+
+	case "resetstat":
+		h.ResetStats()
+		return SimpleString("OK"), nil
+
+	default:
+		return nil, fmt.Errorf("ERR unknown subcommand '%s'. Try CONFIG HELP.", args[1])
+	}
+}
@@ -0,0 +1,68 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/Shaso41/Backend-SystemFocus/internal/config"
+	"github.com/Shaso41/Backend-SystemFocus/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler_Config(t *testing.T) {
+	s := store.New()
+	defer s.Close()
+	h := NewHandler(s)
+	maxconns := h.Config().Int(config.Spec{Name: "maxconns", Mutable: true}, 10000, 1, 100000)
+	h.Config().Int(config.Spec{Name: "maxmemory"}, 0, 0, 1<<40)
+
+	result, err := h.Execute([]interface{}{"CONFIG", "GET", "maxconns", "maxmem*"})
+	assert.NoError(t, err)
+	assert.Equal(t, Map{
+		{BulkString("maxconns"), BulkString("10000")},
+		{BulkString("maxmemory"), BulkString("0")},
+	}, result)
+
+	result, err = h.Execute([]interface{}{"CONFIG", "SET", "maxconns", "50"})
+	assert.NoError(t, err)
+	assert.Equal(t, SimpleString("OK"), result)
+	assert.Equal(t, int64(50), maxconns.Get())
+
+	_, err = h.Execute([]interface{}{"CONFIG", "SET", "maxmemory", "1"})
+	assert.EqualError(t, err, "ERR CONFIG SET failed (possibly related to argument 'maxmemory') - can't set immutable config")
+
+	_, err = h.Execute([]interface{}{"CONFIG", "SET", "maxconns"})
+	assert.EqualError(t, err, "ERR wrong number of arguments for 'config|set' command")
+
+	_, err = h.Execute([]interface{}{"CONFIG", "REWRITE"})
+	assert.EqualError(t, err, "ERR The server is running without a config file")
+
+	_, err = h.Execute([]interface{}{"CONFIG", "BOGUS"})
+	assert.EqualError(t, err, "ERR unknown subcommand 'BOGUS'. Try CONFIG HELP.")
+}
+
+func TestHandler_ConfigResetStat(t *testing.T) {
+	s := store.New()
+	defer s.Close()
+	h := NewHandler(s)
+
+	reset := false
+	h.OnResetStats(func() { reset = true })
+
+	h.Execute([]interface{}{"GET", "k"})
+	h.Execute([]interface{}{"NOPE"})
+	result, err := h.Execute([]interface{}{"INFO"})
+	assert.NoError(t, err)
+	assert.Contains(t, string(result.(BulkString)), "total_commands_processed:2\r\n")
+	assert.Contains(t, string(result.(BulkString)), "total_error_replies:1\r\n")
+
+	result, err = h.Execute([]interface{}{"CONFIG", "RESETSTAT"})
+	assert.NoError(t, err)
+	assert.Equal(t, SimpleString("OK"), result)
+	assert.True(t, reset)
+
+	// RESETSTAT itself is counted once it completes
+	result, err = h.Execute([]interface{}{"INFO"})
+	assert.NoError(t, err)
+	assert.Contains(t, string(result.(BulkString)), "total_commands_processed:1\r\n")
+	assert.Contains(t, string(result.(BulkString)), "total_error_replies:0\r\n")
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package commands
+
+import "time"
+
+// cpuTimes returns the user and system CPU time used by the process, which
+// is only known on Unix systems
+func cpuTimes() (user, sys time.Duration) {
+	return 0, 0
+}
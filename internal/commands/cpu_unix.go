@@ -0,0 +1,17 @@
+//go:build unix
+
+package commands
+
+import (
+	"syscall"
+	"time"
+)
+
+// cpuTimes returns the user and system CPU time used by the process
+func cpuTimes() (user, sys time.Duration) {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return 0, 0
+	}
+	return time.Duration(usage.Utime.Nano()), time.Duration(usage.Stime.Nano())
+}
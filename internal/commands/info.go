@@ -0,0 +1,240 @@
+package commands
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// infoSection is a section of the INFO reply
+type infoSection struct {
+	name string
+
+	// Whether INFO without arguments includes the section
+	byDefault bool
+
+	write func(h *Handler, b *strings.Builder, stats *Stats)
+}
+
+// infoSections lists the sections of INFO in the order they are reported
+var infoSections = []infoSection{
+	{"server", true, (*Handler).infoServer},
+	{"clients", true, (*Handler).infoClients},
+	{"memory", true, (*Handler).infoMemory},
+	{"persistence", true, (*Handler).infoPersistence},
+	{"stats", true, (*Handler).infoStats},
+	{"replication", true, (*Handler).infoReplication},
+	{"cpu", true, (*Handler).infoCPU},
+	{"commandstats", false, (*Handler).infoCommandStats},
+	{"latencystats", false, (*Handler).infoLatencyStats},
+	{"keyspace", true, (*Handler).infoKeyspace},
+}
+
+// handleInfo handles INFO command
+// INFO [section ...]
+func (h *Handler) handleInfo(args [][]byte) (interface{}, error) {
+	selected := make(map[string]bool)
+	all, defaults := false, len(args) == 1
+	for _, arg := range args[1:] {
+		switch name := strings.ToLower(string(arg)); name {
+		case "all", "everything":
+			all = true
+		case "default":
+			defaults = true
+		default:
+			selected[name] = true
+		}
+	}
+
+	var b strings.Builder
+	var stats *Stats
+	for _, section := range infoSections {
+		if !all && !selected[section.name] && !(defaults && section.byDefault) {
+			continue
+		}
+		if stats == nil {
+			s := h.Stats()
+			stats = &s
+		}
+		if b.Len() > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString("# " + infoTitle(section.name) + "\r\n")
+		section.write(h, &b, stats)
+	}
+	return BulkString(b.String()), nil
+}
+
+// infoTitle returns the header of a section, such as "CPU" or "Stats"
+func infoTitle(name string) string {
+	if name == "cpu" {
+		return "CPU"
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+func (h *Handler) infoServer(b *strings.Builder, stats *Stats) {
+	b.WriteString("redis_version:" + redisVersion + "\r\n")
+	b.WriteString("redis_mode:standalone\r\n")
+	b.WriteString("os:" + osName() + "\r\n")
+	fmt.Fprintf(b, "arch_bits:%d\r\n", strconv.IntSize)
+	b.WriteString("go_version:" + runtime.Version() + "\r\n")
+	fmt.Fprintf(b, "process_id:%d\r\n", os.Getpid())
+	b.WriteString("run_id:" + h.runID + "\r\n")
+	if port, ok := h.tcpPort(); ok {
+		b.WriteString("tcp_port:" + port + "\r\n")
+	}
+	fmt.Fprintf(b, "server_time_usec:%d\r\n", time.Now().UnixMicro())
+	fmt.Fprintf(b, "uptime_in_seconds:%d\r\n", int64(stats.Uptime.Seconds()))
+	fmt.Fprintf(b, "uptime_in_days:%d\r\n", int64(stats.Uptime.Hours()/24))
+	if hz, ok := h.config.Lookup("hz"); ok {
+		b.WriteString("hz:" + hz + "\r\n")
+	}
+	if executable, err := os.Executable(); err == nil {
+		b.WriteString("executable:" + executable + "\r\n")
+	}
+	b.WriteString("config_file:" + h.config.File() + "\r\n")
+}
+
+// osName describes the operating system as Redis does: its name, kernel
+// release where known, and architecture
+var osName = sync.OnceValue(func() string {
+	name := strings.ToUpper(runtime.GOOS[:1]) + runtime.GOOS[1:]
+	if release, err := os.ReadFile("/proc/sys/kernel/osrelease"); err == nil {
+		name += " " + strings.TrimSpace(string(release))
+	}
+	return name + " " + runtime.GOARCH
+})
+
+// tcpPort returns the port of the first TCP address the server listens on
+func (h *Handler) tcpPort() (string, bool) {
+	addrs, _ := h.config.Lookup("addr")
+	for _, addr := range strings.Fields(addrs) {
+		if _, port, err := net.SplitHostPort(addr); err == nil {
+			return port, true
+		}
+	}
+	return "", false
+}
+
+func (h *Handler) infoClients(b *strings.Builder, stats *Stats) {
+	fmt.Fprintf(b, "connected_clients:%d\r\n", stats.ConnectedClients)
+	fmt.Fprintf(b, "maxclients:%d\r\n", h.maxClients.Get())
+	b.WriteString("blocked_clients:0\r\n")
+}
+
+// infoMemory reports the memory used by the Go runtime. The server has no
+// memory limit and so never evicts keys.
+func (h *Handler) infoMemory(b *strings.Builder, stats *Stats) {
+	mem := h.MemoryStats()
+	fmt.Fprintf(b, "used_memory:%d\r\n", mem.Used)
+	b.WriteString("used_memory_human:" + humanBytes(mem.Used) + "\r\n")
+	fmt.Fprintf(b, "used_memory_rss:%d\r\n", mem.System)
+	b.WriteString("used_memory_rss_human:" + humanBytes(mem.System) + "\r\n")
+	b.WriteString("maxmemory:0\r\n")
+	b.WriteString("maxmemory_human:0B\r\n")
+	b.WriteString("maxmemory_policy:noeviction\r\n")
+	b.WriteString("mem_allocator:go\r\n")
+	fmt.Fprintf(b, "lazyfree_pending_objects:%d\r\n", stats.LazyfreePendingObjects)
+}
+
+// humanBytes formats a size in bytes as Redis does, such as "1.50M"
+func humanBytes(n uint64) string {
+	const units = "KMGTP"
+	if n < 1024 {
+		return strconv.FormatUint(n, 10) + "B"
+	}
+	size, unit := float64(n)/1024, 0
+	for size >= 1024 && unit < len(units)-1 {
+		size /= 1024
+		unit++
+	}
+	return strconv.FormatFloat(size, 'f', 2, 64) + units[unit:unit+1]
+}
+
+// infoPersistence reports that nothing is saved: the server keeps its data
+// in memory only. It never saved, so the last save time is 0, and since no
+// save is ever due, no changes are counted as waiting for one.
+func (h *Handler) infoPersistence(b *strings.Builder, stats *Stats) {
+	b.WriteString("loading:0\r\n")
+	b.WriteString("async_loading:0\r\n")
+	b.WriteString("rdb_changes_since_last_save:0\r\n")
+	b.WriteString("rdb_bgsave_in_progress:0\r\n")
+	b.WriteString("rdb_last_save_time:0\r\n")
+	b.WriteString("aof_enabled:0\r\n")
+	b.WriteString("aof_rewrite_in_progress:0\r\n")
+}
+
+func (h *Handler) infoStats(b *strings.Builder, stats *Stats) {
+	fmt.Fprintf(b, "total_connections_received:%d\r\n", stats.TotalConnections)
+	fmt.Fprintf(b, "total_commands_processed:%d\r\n", stats.CommandsProcessed)
+	fmt.Fprintf(b, "rejected_connections:%d\r\n", stats.RejectedConnections)
+	fmt.Fprintf(b, "expired_keys:%d\r\n", stats.ExpiredKeys)
+	b.WriteString("evicted_keys:0\r\n")
+	fmt.Fprintf(b, "keyspace_hits:%d\r\n", stats.KeyspaceHits)
+	fmt.Fprintf(b, "keyspace_misses:%d\r\n", stats.KeyspaceMisses)
+	b.WriteString("pubsub_channels:0\r\n")
+	b.WriteString("pubsub_patterns:0\r\n")
+	fmt.Fprintf(b, "total_error_replies:%d\r\n", stats.ErrorReplies)
+	fmt.Fprintf(b, "lazyfreed_objects:%d\r\n", stats.LazyfreedObjects)
+}
+
+// infoReplication reports a master without replicas, the only role the
+// server supports
+func (h *Handler) infoReplication(b *strings.Builder, stats *Stats) {
+	b.WriteString("role:master\r\n")
+	b.WriteString("connected_slaves:0\r\n")
+	b.WriteString("master_repl_offset:0\r\n")
+}
+
+func (h *Handler) infoCPU(b *strings.Builder, stats *Stats) {
+	user, sys := cpuTimes()
+	fmt.Fprintf(b, "used_cpu_sys:%.6f\r\n", sys.Seconds())
+	fmt.Fprintf(b, "used_cpu_user:%.6f\r\n", user.Seconds())
+}
+
+// infoCommandStats reports the calls of every command called so far
+func (h *Handler) infoCommandStats(b *strings.Builder, stats *Stats) {
+	for _, cs := range h.CommandStats() {
+		usec := cs.Duration.Microseconds()
+		perCall := 0.0
+		if cs.Calls > 0 {
+			perCall = float64(usec) / float64(cs.Calls)
+		}
+		fmt.Fprintf(b, "cmdstat_%s:calls=%d,usec=%d,usec_per_call=%.2f,rejected_calls=%d,failed_calls=%d\r\n",
+			cs.Name, cs.Calls, usec, perCall, cs.Rejected, cs.Failed)
+	}
+}
+
+// infoLatencyStats reports latency percentiles, in microseconds, of every
+// command called while latency-tracking was on
+func (h *Handler) infoLatencyStats(b *strings.Builder, stats *Stats) {
+	percentiles := h.latencyInfo.Get()
+	for _, cs := range h.CommandStats() {
+		if cs.Latencies == nil || cs.Latencies.Count() == 0 {
+			continue
+		}
+		b.WriteString("latency_percentiles_usec_" + cs.Name + ":")
+		for i, p := range percentiles {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			fmt.Fprintf(b, "p%s=%.3f", strconv.FormatFloat(p, 'f', -1, 64), float64(cs.Latencies.Percentile(p))/1000)
+		}
+		b.WriteString("\r\n")
+	}
+}
+
+// infoKeyspace reports the keys of the only database, which like Redis is
+// left out while empty
+func (h *Handler) infoKeyspace(b *strings.Builder, stats *Stats) {
+	if stats.Keys == 0 {
+		return
+	}
+	fmt.Fprintf(b, "db0:keys=%d,expires=%d,avg_ttl=0\r\n", stats.Keys, stats.ExpiringKeys)
+}
@@ -0,0 +1,238 @@
+package commands
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shaso41/Backend-SystemFocus/internal/config"
+)
+
+// latencySamples is the number of samples LATENCY HISTORY keeps per event
+const latencySamples = 160
+
+// latencySample is the highest latency of an event within one second
+type latencySample struct {
+	time    int64 // Unix time in seconds
+	latency int64 // milliseconds
+}
+
+// latencyEvent keeps the recent samples of one kind of event in a ring
+type latencyEvent struct {
+	samples [latencySamples]latencySample
+	next    int
+	count   int
+	max     int64
+}
+
+// latest returns the most recent sample
+func (e *latencyEvent) latest() *latencySample {
+	return &e.samples[(e.next+latencySamples-1)%latencySamples]
+}
+
+// latencyMonitor records events, such as slow commands and expiry cycles,
+// that took at least latency-monitor-threshold milliseconds
+type latencyMonitor struct {
+	threshold *config.Value[int64]
+
+	mu     sync.Mutex
+	events map[string]*latencyEvent
+}
+
+// newLatencyMonitor creates a latency monitor configured by
+// latency-monitor-threshold
+func newLatencyMonitor(c *config.Config) *latencyMonitor {
+	return &latencyMonitor{
+		threshold: c.Int(config.Spec{Name: "latency-monitor-threshold", Mutable: true,
+			Doc: "Record events taking at least this many milliseconds for LATENCY LATEST and HISTORY; 0 disables"},
+			0, 0, math.MaxInt64),
+		events: make(map[string]*latencyEvent),
+	}
+}
+
+// command records a command or subcommand that ran for duration as a
+// "command" or, for fast commands, a "fast-command" event
+func (m *latencyMonitor) command(spec *commandSpec, duration time.Duration) {
+	event := "command"
+	if spec.flags&flagFast != 0 {
+		event = "fast-command"
+	}
+	m.add(event, duration)
+}
+
+// add records an event that took duration, if that reaches the threshold
+func (m *latencyMonitor) add(event string, duration time.Duration) {
+	threshold := m.threshold.Get()
+	if threshold == 0 || duration < time.Duration(threshold)*time.Millisecond {
+		return
+	}
+	ms := duration.Milliseconds()
+	now := time.Now().Unix()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.events[event]
+	if !ok {
+		e = &latencyEvent{}
+		m.events[event] = e
+	}
+	e.max = max(e.max, ms)
+
+	// Events within the same second share a sample holding the highest
+	if e.count > 0 && e.latest().time == now {
+		e.latest().latency = max(e.latest().latency, ms)
+		return
+	}
+	e.samples[e.next] = latencySample{time: now, latency: ms}
+	e.next = (e.next + 1) % latencySamples
+	e.count = min(e.count+1, latencySamples)
+}
+
+// registerPercentiles registers latency-tracking-info-percentiles, the
+// percentiles INFO latencystats reports
+func registerPercentiles(c *config.Config) *config.Value[[]float64] {
+	return config.Custom(c, config.Spec{Name: "latency-tracking-info-percentiles", Mutable: true, MultiWord: true,
+		Doc: "Percentiles of command latencies reported by INFO latencystats"},
+		[]float64{50, 99, 99.9}, func(s string) ([]float64, error) {
+			words := strings.Fields(s)
+			percentiles := make([]float64, len(words))
+			for i, word := range words {
+				p, err := strconv.ParseFloat(word, 64)
+				if err != nil || p < 0 || p > 100 {
+					return nil, fmt.Errorf("latency-tracking-info-percentiles values must be between 0 and 100")
+				}
+				percentiles[i] = p
+			}
+			return percentiles, nil
+		}, func(percentiles []float64) string {
+			words := make([]string, len(percentiles))
+			for i, p := range percentiles {
+				words[i] = strconv.FormatFloat(p, 'f', -1, 64)
+			}
+			return strings.Join(words, " ")
+		})
+}
+
+// handleLatency handles LATENCY command
+// LATENCY HISTOGRAM [command ...] | LATEST | HISTORY event | RESET [event ...]
+func (h *Handler) handleLatency(args [][]byte) (interface{}, error) {
+	sub := strings.ToLower(string(args[1]))
+	m := h.latency
+
+	switch sub {
+	case "histogram":
+		return h.latencyHistograms(args[2:]), nil
+
+	case "latest":
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		names := make([]string, 0, len(m.events))
+		for name := range m.events {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		reply := make([]interface{}, len(names))
+		for i, name := range names {
+			e := m.events[name]
+			latest := e.latest()
+			reply[i] = []interface{}{BulkString(name), latest.time, latest.latency, e.max}
+		}
+		return reply, nil
+
+	case "history":
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		e, ok := m.events[strings.ToLower(string(args[2]))]
+		if !ok {
+			return []interface{}{}, nil
+		}
+		reply := make([]interface{}, e.count)
+		for i := range reply {
+			sample := e.samples[(e.next-e.count+i+latencySamples)%latencySamples]
+			reply[i] = []interface{}{sample.time, sample.latency}
+		}
+		return reply, nil
+
+	case "reset":
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		if len(args) == 2 {
+			n := len(m.events)
+			clear(m.events)
+			return int64(n), nil
+		}
+		var n int64
+		for _, arg := range args[2:] {
+			name := strings.ToLower(string(arg))
+			if _, ok := m.events[name]; ok {
+				delete(m.events, name)
+				n++
+			}
+		}
+		return n, nil
+
+	default:
+		return nil, fmt.Errorf("ERR unknown subcommand '%s'. Try LATENCY HELP.", args[1])
+	}
+}
+
+// latencyHistograms reports the latency histograms of the named commands,
+// including the subcommands of container commands, or of every command
+// called so far. Buckets are powers of two microseconds holding the
+// number of calls that took less.
+func (h *Handler) latencyHistograms(names [][]byte) Map {
+	var ids []int
+	if len(names) == 0 {
+		for id := range commandNames {
+			ids = append(ids, id)
+		}
+	}
+	for _, name := range names {
+		spec, ok := commandSpecs[strings.ToUpper(string(name))]
+		if !ok {
+			continue
+		}
+		ids = append(ids, spec.id)
+		for _, sub := range spec.subcommands {
+			ids = append(ids, sub.id)
+		}
+	}
+	sort.Ints(ids)
+
+	reply := Map{}
+	for i, id := range ids {
+		if i > 0 && ids[i-1] == id {
+			continue
+		}
+		latencies := h.commandStats[id].latencies.Load()
+		if latencies == nil {
+			continue
+		}
+		snapshot := latencies.Snapshot()
+		if snapshot.Count() == 0 {
+			continue
+		}
+
+		buckets := Map{}
+		var previous int64
+		snapshot.Log2(1000, func(bound, below int64) {
+			if below > previous {
+				buckets = append(buckets, MapEntry{bound / 1000, below})
+			}
+			previous = below
+		})
+		reply = append(reply, MapEntry{BulkString(commandNames[id]), Map{
+			{BulkString("calls"), snapshot.Count()},
+			{BulkString("histogram_usec"), buckets},
+		}})
+	}
+	return reply
+}
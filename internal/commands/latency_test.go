@@ -0,0 +1,184 @@
+package commands
+
+import (
+	"math/bits"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Shaso41/Backend-SystemFocus/internal/acl"
+	"github.com/Shaso41/Backend-SystemFocus/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func info(t *testing.T, h *Handler, sections ...string) string {
+	args := []interface{}{"INFO"}
+	for _, section := range sections {
+		args = append(args, section)
+	}
+	result, err := h.Execute(args)
+	require.NoError(t, err)
+	return string(result.(BulkString))
+}
+
+func TestHandler_CommandStats(t *testing.T) {
+	s := store.New()
+	defer s.Close()
+	h := NewHandler(s)
+
+	h.Execute([]interface{}{"SET", "k", "v"})
+	h.Execute([]interface{}{"GET", "k"})
+	h.Execute([]interface{}{"GET", "k", "extra"})
+	h.Execute([]interface{}{"GETBIT", "k", "x"})
+	h.Execute([]interface{}{"CLIENT", "ID"})
+	h.Exec(&Session{Protocol: 2}, argv("GET", "k"))
+	h.ACL().SetUser("reader", "on", "nopass", "+get", "~*")
+	h.Exec(&Session{Protocol: 2, User: "reader"}, argv("SET", "k", "v"))
+
+	stats := info(t, h, "commandstats")
+	assert.True(t, strings.HasPrefix(stats, "# Commandstats\r\n"), stats)
+	assert.Regexp(t, `cmdstat_get:calls=1,usec=\d+,usec_per_call=\d+\.\d\d,rejected_calls=2,failed_calls=0\r\n`, stats)
+	assert.Regexp(t, `cmdstat_getbit:calls=1,usec=\d+,usec_per_call=\d+\.\d\d,rejected_calls=0,failed_calls=1\r\n`, stats)
+	assert.Regexp(t, `cmdstat_set:calls=1,usec=\d+,usec_per_call=\d+\.\d\d,rejected_calls=1,failed_calls=0\r\n`, stats)
+	assert.Contains(t, stats, "cmdstat_client|id:calls=1,")
+	assert.NotContains(t, stats, "cmdstat_del")
+	assert.NotContains(t, stats, "# Server")
+
+	latency := info(t, h, "latencystats")
+	assert.Regexp(t, `latency_percentiles_usec_get:p50=\d+\.\d{3},p99=\d+\.\d{3},p99\.9=\d+\.\d{3}\r\n`, latency)
+
+	_, err := h.Execute([]interface{}{"CONFIG", "SET", "latency-tracking-info-percentiles", "90 100"})
+	require.NoError(t, err)
+	assert.Regexp(t, `latency_percentiles_usec_get:p90=\d+\.\d{3},p100=\d+\.\d{3}\r\n`, info(t, h, "latencystats"))
+	_, err = h.Execute([]interface{}{"CONFIG", "SET", "latency-tracking-info-percentiles", "101"})
+	assert.Error(t, err)
+
+	// The default sections leave the per-command ones out, all has them
+	assert.NotContains(t, info(t, h), "# Commandstats")
+	everything := info(t, h, "all")
+	assert.Contains(t, everything, "# Server\r\n")
+	assert.Contains(t, everything, "\r\n\r\n# Commandstats\r\n")
+	assert.Contains(t, everything, "\r\n\r\n# Latencystats\r\n")
+
+	h.Execute([]interface{}{"CONFIG", "RESETSTAT"})
+	stats = info(t, h, "commandstats")
+	assert.NotContains(t, stats, "cmdstat_get")
+	assert.Contains(t, stats, "cmdstat_config|resetstat:calls=1,")
+}
+
+func TestHandler_LatencyHistogram(t *testing.T) {
+	s := store.New()
+	defer s.Close()
+	h := NewHandler(s)
+
+	for i := 0; i < 5; i++ {
+		h.Execute([]interface{}{"GET", "k"})
+	}
+	h.Execute([]interface{}{"CONFIG", "GET", "timeout"})
+
+	result, err := h.Execute([]interface{}{"LATENCY", "HISTOGRAM", "get", "config", "nosuchcommand"})
+	require.NoError(t, err)
+	histograms := result.(Map)
+	require.Len(t, histograms, 2)
+	assert.Equal(t, BulkString("config|get"), histograms[0].Key)
+	assert.Equal(t, BulkString("get"), histograms[1].Key)
+
+	get := histograms[1].Value.(Map)
+	assert.Equal(t, MapEntry{BulkString("calls"), int64(5)}, get[0])
+	assert.Equal(t, BulkString("histogram_usec"), get[1].Key)
+	buckets := get[1].Value.(Map)
+	require.NotEmpty(t, buckets)
+	previous := int64(0)
+	for _, bucket := range buckets {
+		bound := bucket.Key.(int64)
+		assert.Equal(t, 1, bits.OnesCount64(uint64(bound)), "%d microseconds is a power of two", bound)
+		assert.Greater(t, bucket.Value.(int64), previous, "counts are cumulative and only rise")
+		previous = bucket.Value.(int64)
+	}
+	assert.Equal(t, int64(5), previous)
+
+	// Without arguments every command called so far is reported
+	result, err = h.Execute([]interface{}{"LATENCY", "HISTOGRAM"})
+	require.NoError(t, err)
+	assert.Len(t, result.(Map), 3)
+
+	h.Execute([]interface{}{"CONFIG", "SET", "latency-tracking", "no"})
+	h.Execute([]interface{}{"CONFIG", "RESETSTAT"})
+	h.Execute([]interface{}{"GET", "k"})
+	result, err = h.Execute([]interface{}{"LATENCY", "HISTOGRAM"})
+	require.NoError(t, err)
+	assert.Empty(t, result)
+	assert.NotContains(t, info(t, h, "latencystats"), "latency_percentiles_usec_get")
+}
+
+func TestHandler_LatencyEvents(t *testing.T) {
+	s := store.New()
+	defer s.Close()
+	h := NewHandler(s)
+
+	// Nothing is recorded until a threshold is set
+	h.latency.add("command", time.Second)
+	result, err := h.Execute([]interface{}{"LATENCY", "LATEST"})
+	require.NoError(t, err)
+	assert.Empty(t, result)
+
+	h.Execute([]interface{}{"CONFIG", "SET", "latency-monitor-threshold", "10"})
+	h.latency.add("command", 5*time.Millisecond)
+	h.latency.command(commandSpecs["GET"], 20*time.Millisecond)
+	h.latency.command(commandSpecs["KEYS"], 30*time.Millisecond)
+	h.latency.command(commandSpecs["KEYS"], 15*time.Millisecond)
+
+	result, err = h.Execute([]interface{}{"LATENCY", "LATEST"})
+	require.NoError(t, err)
+	latest := result.([]interface{})
+	require.Len(t, latest, 2)
+	command := latest[0].([]interface{})
+	assert.Equal(t, BulkString("command"), command[0])
+	assert.InDelta(t, time.Now().Unix(), command[1], 2)
+	assert.Equal(t, int64(30), command[2], "events within a second keep the highest")
+	assert.Equal(t, int64(30), command[3])
+	assert.Equal(t, BulkString("fast-command"), latest[1].([]interface{})[0])
+
+	result, err = h.Execute([]interface{}{"LATENCY", "HISTORY", "command"})
+	require.NoError(t, err)
+	history := result.([]interface{})
+	require.Len(t, history, 1)
+	assert.Equal(t, int64(30), history[0].([]interface{})[1])
+
+	result, err = h.Execute([]interface{}{"LATENCY", "HISTORY", "expire-cycle"})
+	require.NoError(t, err)
+	assert.Empty(t, result)
+
+	result, err = h.Execute([]interface{}{"LATENCY", "RESET", "command", "nosuchevent"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), result)
+	result, err = h.Execute([]interface{}{"LATENCY", "RESET"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), result)
+
+	_, err = h.Execute([]interface{}{"LATENCY", "HISTORY"})
+	assert.EqualError(t, err, "ERR wrong number of arguments for 'latency|history' command")
+	_, err = h.Execute([]interface{}{"LATENCY", "DOCTOR"})
+	assert.EqualError(t, err, "ERR unknown subcommand 'DOCTOR'. Try LATENCY HELP.")
+}
+
+func TestHandler_LatencyHistoryRing(t *testing.T) {
+	m := &latencyEvent{}
+	for i := 0; i < latencySamples+5; i++ {
+		m.samples[m.next] = latencySample{time: int64(i), latency: 1}
+		m.next = (m.next + 1) % latencySamples
+		m.count = min(m.count+1, latencySamples)
+	}
+
+	s := store.New()
+	defer s.Close()
+	h := NewHandler(s)
+	h.latency.events["command"] = m
+	result, err := h.Exec(&Session{Protocol: 2, User: acl.DefaultUser}, argv("LATENCY", "HISTORY", "command"))
+	require.NoError(t, err)
+	history := result.([]interface{})
+	require.Len(t, history, latencySamples)
+	assert.Equal(t, int64(5), history[0].([]interface{})[0])
+	assert.Equal(t, int64(latencySamples+4), history[latencySamples-1].([]interface{})[0])
+}
@@ -0,0 +1,65 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Shaso41/Backend-SystemFocus/internal/config"
+)
+
+// lazyFreeParams selects which deletions release values in the background
+type lazyFreeParams struct {
+	userDel   *config.Value[bool]
+	userFlush *config.Value[bool]
+}
+
+// registerLazyFree registers the lazyfree-lazy-* parameters.
+// lazyfree-lazy-expire is applied to the store as it changes.
+func (h *Handler) registerLazyFree() lazyFreeParams {
+	expire := h.config.Bool(config.Spec{Name: "lazyfree-lazy-expire", Mutable: true,
+		Doc: "Release the values of expired keys in the background"}, false)
+	expire.OnChange(func(lazy bool) error {
+		h.store.SetLazyExpire(lazy)
+		return nil
+	})
+
+	return lazyFreeParams{
+		userDel: h.config.Bool(config.Spec{Name: "lazyfree-lazy-user-del", Mutable: true,
+			Doc: "Make DEL release values in the background like UNLINK"}, false),
+		userFlush: h.config.Bool(config.Spec{Name: "lazyfree-lazy-user-flush", Mutable: true,
+			Doc: "Make FLUSHDB and FLUSHALL without SYNC or ASYNC flush in the background"}, false),
+	}
+}
+
+// handleUnlink handles UNLINK command
+// UNLINK key [key ...]
+func (h *Handler) handleUnlink(args [][]byte) (interface{}, error) {
+	keys := make([]string, len(args)-1)
+	for i, arg := range args[1:] {
+		keys[i] = string(arg)
+	}
+	return int64(h.store.Unlink(keys...)), nil
+}
+
+// handleFlush handles FLUSHDB and FLUSHALL commands, which are the same
+// with a single database
+// FLUSHDB|FLUSHALL [ASYNC | SYNC]
+func (h *Handler) handleFlush(args [][]byte) (interface{}, error) {
+	async := h.lazyFree.userFlush.Get()
+	if len(args) > 2 {
+		return nil, fmt.Errorf("ERR syntax error")
+	}
+	if len(args) == 2 {
+		switch strings.ToUpper(string(args[1])) {
+		case "ASYNC":
+			async = true
+		case "SYNC":
+			async = false
+		default:
+			return nil, fmt.Errorf("ERR syntax error")
+		}
+	}
+
+	h.store.Flush(async)
+	return SimpleString("OK"), nil
+}
@@ -0,0 +1,77 @@
+package commands
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Shaso41/Backend-SystemFocus/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler_Unlink(t *testing.T) {
+	s := store.New()
+	defer s.Close()
+	h := NewHandler(s)
+
+	h.Execute([]interface{}{"SET", "a", "1"})
+	h.Execute([]interface{}{"SET", "b", "2"})
+
+	result, err := h.Execute([]interface{}{"UNLINK", "a", "b", "missing"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), result)
+	result, _ = h.Execute([]interface{}{"EXISTS", "a"})
+	assert.Equal(t, int64(0), result)
+
+	// The released values show up in INFO once the worker is done
+	assert.Eventually(t, func() bool { return s.LazyFreed() == 2 }, time.Second, 5*time.Millisecond)
+	assert.Contains(t, info(t, h, "memory"), "lazyfree_pending_objects:0\r\n")
+	assert.Contains(t, info(t, h, "stats"), "lazyfreed_objects:2\r\n")
+
+	// With lazyfree-lazy-user-del, DEL unlinks too
+	_, err = h.Execute([]interface{}{"CONFIG", "SET", "lazyfree-lazy-user-del", "yes"})
+	assert.NoError(t, err)
+	h.Execute([]interface{}{"SET", "a", "1"})
+	result, err = h.Execute([]interface{}{"DEL", "a"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), result)
+	assert.Eventually(t, func() bool { return s.LazyFreed() == 3 }, time.Second, 5*time.Millisecond)
+}
+
+func TestHandler_Flush(t *testing.T) {
+	s := store.New()
+	defer s.Close()
+	h := NewHandler(s)
+
+	fill := func() {
+		for _, key := range []string{"a", "b", "c"} {
+			h.Execute([]interface{}{"SET", key, "v"})
+		}
+	}
+
+	fill()
+	result, err := h.Execute([]interface{}{"FLUSHALL", "SYNC"})
+	assert.NoError(t, err)
+	assert.Equal(t, SimpleString("OK"), result)
+	assert.Equal(t, 0, s.Count())
+	assert.Equal(t, int64(0), s.LazyFreed())
+
+	fill()
+	result, err = h.Execute([]interface{}{"FLUSHDB", "async"})
+	assert.NoError(t, err)
+	assert.Equal(t, SimpleString("OK"), result)
+	assert.Equal(t, 0, s.Count())
+	assert.Eventually(t, func() bool { return s.LazyFreed() == 3 }, time.Second, 5*time.Millisecond)
+
+	// lazyfree-lazy-user-flush picks the mode when none is given
+	_, err = h.Execute([]interface{}{"CONFIG", "SET", "lazyfree-lazy-user-flush", "yes"})
+	assert.NoError(t, err)
+	fill()
+	_, err = h.Execute([]interface{}{"FLUSHDB"})
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool { return s.LazyFreed() == 6 }, time.Second, 5*time.Millisecond)
+
+	_, err = h.Execute([]interface{}{"FLUSHALL", "LATER"})
+	assert.EqualError(t, err, "ERR syntax error")
+	_, err = h.Execute([]interface{}{"FLUSHALL", "ASYNC", "SYNC"})
+	assert.EqualError(t, err, "ERR syntax error")
+}
@@ -0,0 +1,139 @@
+package commands
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Shaso41/Backend-SystemFocus/internal/protocol"
+)
+
+// Monitor queues the commands run by every client for a client that ran
+// MONITOR. The client's connection sends them from its own goroutine,
+// taking them from the queue whenever Ready fires.
+type Monitor struct {
+	limit atomic.Int64
+
+	mu       sync.Mutex
+	lines    []string
+	pending  int64
+	overflow bool
+	ready    chan struct{}
+}
+
+// Monitor returns the queue of monitored commands once the session ran
+// MONITOR, or nil
+func (sess *Session) Monitor() *Monitor {
+	return sess.monitor
+}
+
+// SetLimit bounds the bytes queued and not taken yet. A client falling
+// further behind is disconnected. Zero means no limit.
+func (m *Monitor) SetLimit(n int64) {
+	m.limit.Store(n)
+}
+
+// Ready fires when lines are queued
+func (m *Monitor) Ready() <-chan struct{} {
+	return m.ready
+}
+
+// Take appends the queued lines to dst and empties the queue
+func (m *Monitor) Take(dst []string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	dst = append(dst, m.lines...)
+	clear(m.lines)
+	m.lines, m.pending = m.lines[:0], 0
+	return dst
+}
+
+// push queues a line, reporting false if that takes the queue over its
+// limit
+func (m *Monitor) push(line string) bool {
+	m.mu.Lock()
+	if m.overflow {
+		m.mu.Unlock()
+		return true
+	}
+	m.lines = append(m.lines, line)
+	m.pending += int64(len(line))
+	if limit := m.limit.Load(); limit > 0 && m.pending > limit {
+		m.overflow = true
+		m.lines = nil
+		m.mu.Unlock()
+		return false
+	}
+	m.mu.Unlock()
+
+	select {
+	case m.ready <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// handleMonitor handles MONITOR command
+func (h *Handler) handleMonitor(sess *Session, args [][]byte) (interface{}, error) {
+	if sess.conn == nil {
+		return nil, fmt.Errorf("ERR MONITOR is only available to connected clients")
+	}
+
+	if sess.monitor == nil {
+		sess.monitor = &Monitor{ready: make(chan struct{}, 1)}
+		sess.publish()
+
+		h.monitorsMu.Lock()
+		h.monitors[sess.ID] = sess
+		h.monitorsMu.Unlock()
+		h.monitorCount.Add(1)
+	}
+	return SimpleString("OK"), nil
+}
+
+// stopMonitor stops streaming commands to the session
+func (h *Handler) stopMonitor(sess *Session) {
+	if sess.monitor == nil {
+		return
+	}
+	h.monitorsMu.Lock()
+	delete(h.monitors, sess.ID)
+	h.monitorsMu.Unlock()
+	h.monitorCount.Add(-1)
+}
+
+// feedMonitors queues a command about to run for every monitoring client,
+// as a line with the time, database, client address and quoted arguments.
+// Administrative commands are left out and credentials redacted.
+func (h *Handler) feedMonitors(sess *Session, spec *commandSpec, args [][]byte) {
+	if spec.flags&flagAdmin != 0 {
+		return
+	}
+
+	now := time.Now()
+	line := fmt.Appendf(nil, "%d.%06d [0 %s]", now.Unix(), now.Nanosecond()/1000, sess.Addr)
+	for i, arg := range args {
+		line = append(line, ' ')
+		if secretArg(args, i) {
+			line = append(line, `"(redacted)"`...)
+		} else {
+			line = protocol.AppendQuoted(line, arg)
+		}
+	}
+	text := string(line)
+
+	var behind []*Session
+	h.monitorsMu.RLock()
+	for _, monitor := range h.monitors {
+		if !monitor.monitor.push(text) {
+			behind = append(behind, monitor)
+		}
+	}
+	h.monitorsMu.RUnlock()
+
+	for _, monitor := range behind {
+		h.kill(sess, monitor)
+	}
+}
@@ -0,0 +1,70 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/Shaso41/Backend-SystemFocus/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_Monitor(t *testing.T) {
+	s := store.New()
+	defer s.Close()
+	h := NewHandler(s)
+	monitorSess, _ := connect(h, "127.0.0.1:5000")
+	sess, _ := connect(h, "127.0.0.1:5001")
+
+	_, err := h.Execute([]interface{}{"MONITOR"})
+	assert.EqualError(t, err, "ERR MONITOR is only available to connected clients")
+
+	result, err := h.Exec(monitorSess, argv("MONITOR"))
+	require.NoError(t, err)
+	assert.Equal(t, SimpleString("OK"), result)
+	monitor := monitorSess.Monitor()
+	require.NotNil(t, monitor)
+	assert.Contains(t, monitorSess.describe(monitorSess.created), " flags=O ")
+
+	h.Exec(sess, argv("SET", "greeting", "hello \"world\"\n"))
+	h.Exec(sess, argv("AUTH", "default", "secret"))
+	h.Exec(sess, argv("CONFIG", "GET", "timeout"))
+	h.Exec(sess, argv("CLIENT", "GETNAME"))
+	h.Exec(sess, argv("NOSUCHCOMMAND"))
+
+	select {
+	case <-monitor.Ready():
+	default:
+		t.Fatal("monitor not notified")
+	}
+	lines := monitor.Take(nil)
+	require.Len(t, lines, 3, "administrative and unknown commands are left out")
+	assert.Regexp(t, `^\d+\.\d{6} \[0 127\.0\.0\.1:5001\] "SET" "greeting" "hello \\"world\\"\\n"$`, lines[0])
+	assert.Regexp(t, `\] "AUTH" "\(redacted\)" "\(redacted\)"$`, lines[1])
+	assert.Regexp(t, `\] "CLIENT" "GETNAME"$`, lines[2])
+	assert.Empty(t, monitor.Take(nil))
+
+	h.Unregister(monitorSess)
+	assert.Equal(t, int32(0), h.monitorCount.Load())
+	h.Exec(sess, argv("GET", "greeting"))
+	assert.Empty(t, monitor.Take(nil))
+}
+
+func TestHandler_MonitorLimit(t *testing.T) {
+	s := store.New()
+	defer s.Close()
+	h := NewHandler(s)
+	monitorSess, conn := connect(h, "127.0.0.1:5000")
+	sess, _ := connect(h, "127.0.0.1:5001")
+
+	h.Exec(monitorSess, argv("MONITOR"))
+	monitorSess.Monitor().SetLimit(100)
+
+	h.Exec(sess, argv("GET", "k"))
+	assert.False(t, conn.closed.Load())
+
+	// A client that does not take its lines falls behind and is dropped
+	h.Exec(sess, argv("GET", "k"))
+	h.Exec(sess, argv("GET", "k"))
+	assert.True(t, conn.closed.Load())
+	assert.Empty(t, monitorSess.Monitor().Take(nil))
+}
@@ -0,0 +1,127 @@
+package commands
+
+import "github.com/Shaso41/Backend-SystemFocus/internal/protocol"
+
+// MapEntry is a single key/value pair of a Map reply
+type MapEntry struct {
+	Key   interface{}
+	Value interface{}
+}
+
+// Map represents a RESP3 map reply. Entries keep their order; RESP2
+// clients receive a flat array of alternating keys and values.
+type Map []MapEntry
+
+// Set represents a RESP3 set reply, sent as an array to RESP2 clients
+type Set []interface{}
+
+// Push represents a RESP3 out-of-band push frame, sent as an array to
+// RESP2 clients
+type Push []interface{}
+
+// Double represents a RESP3 double reply, sent as a bulk string to RESP2
+// clients
+type Double float64
+
+// Boolean represents a RESP3 boolean reply, sent as the integer 1 or 0 to
+// RESP2 clients
+type Boolean bool
+
+// BigNumber represents a RESP3 big number reply holding decimal digits,
+// sent as a bulk string to RESP2 clients
+type BigNumber string
+
+// Verbatim represents a RESP3 verbatim string reply with a three letter
+// format such as "txt", sent as a plain bulk string to RESP2 clients
+type Verbatim struct {
+	Format string
+	Text   string
+}
+
+// Attribute decorates a reply with auxiliary RESP3 attributes. RESP2
+// clients receive only Value.
+type Attribute struct {
+	Attributes Map
+	Value      interface{}
+}
+
+// MarshalRESP writes s as a simple string
+func (s SimpleString) MarshalRESP(e *protocol.Encoder) error {
+	return e.WriteSimpleString(string(s))
+}
+
+// MarshalRESP writes b as a bulk string
+func (b BulkString) MarshalRESP(e *protocol.Encoder) error {
+	return e.WriteBulk(b)
+}
+
+// MarshalRESP writes m as a map, or a flat array in RESP2
+func (m Map) MarshalRESP(e *protocol.Encoder) error {
+	if err := e.WriteMapHeader(len(m)); err != nil {
+		return err
+	}
+	return m.writeEntries(e)
+}
+
+// writeEntries writes the keys and values of m in order
+func (m Map) writeEntries(e *protocol.Encoder) error {
+	for _, entry := range m {
+		if err := e.WriteValue(entry.Key); err != nil {
+			return err
+		}
+		if err := e.WriteValue(entry.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarshalRESP writes s as a set, or an array in RESP2
+func (s Set) MarshalRESP(e *protocol.Encoder) error {
+	if err := e.WriteSetHeader(len(s)); err != nil {
+		return err
+	}
+	return e.WriteValues(s)
+}
+
+// MarshalRESP writes p as a push frame, or an array in RESP2
+func (p Push) MarshalRESP(e *protocol.Encoder) error {
+	if err := e.WritePushHeader(len(p)); err != nil {
+		return err
+	}
+	return e.WriteValues(p)
+}
+
+// MarshalRESP writes d as a double
+func (d Double) MarshalRESP(e *protocol.Encoder) error {
+	return e.WriteDouble(float64(d))
+}
+
+// MarshalRESP writes b as a boolean
+func (b Boolean) MarshalRESP(e *protocol.Encoder) error {
+	return e.WriteBoolean(bool(b))
+}
+
+// MarshalRESP writes n as a big number
+func (n BigNumber) MarshalRESP(e *protocol.Encoder) error {
+	return e.WriteBigNumber(string(n))
+}
+
+// MarshalRESP writes v as a verbatim string
+func (v Verbatim) MarshalRESP(e *protocol.Encoder) error {
+	return e.WriteVerbatim(v.Format, v.Text)
+}
+
+// MarshalRESP writes the attributes followed by the value. RESP2 has no
+// attributes, so only the value is written.
+func (a Attribute) MarshalRESP(e *protocol.Encoder) error {
+	if e.Protocol() >= 3 {
+		if err := e.WriteAttributeHeader(len(a.Attributes)); err != nil {
+			return err
+		}
+		if err := a.Attributes.writeEntries(e); err != nil {
+			return err
+		}
+	}
+	return e.WriteValue(a.Value)
+}
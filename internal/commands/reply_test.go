@@ -0,0 +1,43 @@
+package commands
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Shaso41/Backend-SystemFocus/internal/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReply_MapInBothProtocols(t *testing.T) {
+	reply := Map{
+		{BulkString("proto"), int64(3)},
+		{BulkString("flags"), Set{BulkString("a")}},
+	}
+
+	buf := &bytes.Buffer{}
+	enc := protocol.NewEncoder(buf)
+	assert.NoError(t, enc.WriteValue(reply))
+	assert.Equal(t, "*4\r\n$5\r\nproto\r\n:3\r\n$5\r\nflags\r\n*1\r\n$1\r\na\r\n", buf.String())
+
+	buf.Reset()
+	enc.SetProtocol(3)
+	assert.NoError(t, enc.WriteValue(reply))
+	assert.Equal(t, "%2\r\n$5\r\nproto\r\n:3\r\n$5\r\nflags\r\n~1\r\n$1\r\na\r\n", buf.String())
+}
+
+func TestReply_AttributeSkippedInRESP2(t *testing.T) {
+	reply := Attribute{
+		Attributes: Map{{BulkString("ttl"), int64(10)}},
+		Value:      BulkString("v"),
+	}
+
+	buf := &bytes.Buffer{}
+	enc := protocol.NewEncoder(buf)
+	assert.NoError(t, enc.WriteValue(reply))
+	assert.Equal(t, "$1\r\nv\r\n", buf.String())
+
+	buf.Reset()
+	enc.SetProtocol(3)
+	assert.NoError(t, enc.WriteValue(reply))
+	assert.Equal(t, "|1\r\n$3\r\nttl\r\n:10\r\n$1\r\nv\r\n", buf.String())
+}
@@ -0,0 +1,191 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Shaso41/Backend-SystemFocus/internal/acl"
+)
+
+// Session holds the per-connection state that commands can read and
+// change, such as the negotiated protocol version and the authenticated
+// user. User is empty until the client authenticates.
+//
+// The fields belong to the connection's goroutine. Other clients see them
+// through CLIENT LIST as last published, and see the connection's activity
+// as recorded by Track.
+type Session struct {
+	ID        int64
+	Protocol  int
+	Name      string
+	Addr      string
+	LocalAddr string
+	User      string
+
+	created         time.Time
+	noEvict         bool
+	closeAfterReply bool
+	monitor         *Monitor
+
+	mu    sync.Mutex
+	state clientState
+
+	// Updated on every command, so kept apart from state
+	cmd             atomic.Pointer[string]
+	lastInteraction atomic.Int64
+	queryBuffer     atomic.Int64
+	queryBufferFree atomic.Int64
+	outputBuffer    atomic.Int64
+
+	// Set while the session is registered as a connected client
+	conn     io.Closer
+	killed   chan struct{}
+	killOnce sync.Once
+}
+
+// clientState is the copy of a session's identity other clients read
+type clientState struct {
+	name, user string
+	protocol   int
+	noEvict    bool
+	monitor    bool
+}
+
+// NewSession creates the state for a new client connection with a unique
+// ID, speaking RESP2 until it negotiates otherwise with HELLO. The client
+// starts out authenticated as the default user when that user needs no
+// password.
+func (h *Handler) NewSession() *Session {
+	sess := &Session{
+		ID:       h.nextClientID.Add(1),
+		Protocol: 2,
+		created:  time.Now(),
+	}
+	if h.acl.NoPass(acl.DefaultUser) {
+		sess.User = acl.DefaultUser
+	}
+	sess.lastInteraction.Store(sess.created.UnixNano())
+	sess.publish()
+	return sess
+}
+
+// Track records the connection's activity before a command runs: the time
+// and, in bytes, the input received but not parsed yet, the room left in
+// the input buffer and the replies not sent yet
+func (sess *Session) Track(now time.Time, queryBuffer, queryBufferFree, outputBuffer int) {
+	sess.lastInteraction.Store(now.UnixNano())
+	sess.queryBuffer.Store(int64(queryBuffer))
+	sess.queryBufferFree.Store(int64(queryBufferFree))
+	sess.outputBuffer.Store(int64(outputBuffer))
+}
+
+// CloseAfterReply reports whether the connection should be closed once
+// the reply to the current command is sent, as after CLIENT KILL of the
+// client itself
+func (sess *Session) CloseAfterReply() bool {
+	return sess.closeAfterReply
+}
+
+// publish makes changes to the session's name, user, protocol or flags
+// visible to other clients
+func (sess *Session) publish() {
+	sess.mu.Lock()
+	sess.state = clientState{
+		name:     sess.Name,
+		user:     sess.User,
+		protocol: sess.Protocol,
+		noEvict:  sess.noEvict,
+		monitor:  sess.monitor != nil,
+	}
+	sess.mu.Unlock()
+}
+
+// snapshot returns the identity last published by the session
+func (sess *Session) snapshot() clientState {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	return sess.state
+}
+
+// clientInfo describes the session's client for the ACL log
+func (sess *Session) clientInfo() string {
+	return fmt.Sprintf("id=%d addr=%s name=%s user=%s", sess.ID, sess.Addr, sess.Name, sess.User)
+}
+
+// handleHello handles HELLO command
+// HELLO [protover [AUTH username password] [SETNAME clientname]]
+func (h *Handler) handleHello(sess *Session, args [][]byte) (interface{}, error) {
+	proto := sess.Protocol
+
+	if len(args) >= 2 {
+		n, err := strconv.Atoi(string(args[1]))
+		if err != nil {
+			return nil, fmt.Errorf("ERR Protocol version is not an integer or out of range")
+		}
+		if n != 2 && n != 3 {
+			return nil, fmt.Errorf("NOPROTO unsupported protocol version")
+		}
+		proto = n
+	}
+
+	name, setName := "", false
+	user, password, auth := "", "", false
+	for i := 2; i < len(args); i++ {
+		opt := string(args[i])
+		switch {
+		case strings.EqualFold(opt, "AUTH") && i+2 < len(args):
+			user, password, auth = string(args[i+1]), string(args[i+2]), true
+			i += 2
+		case strings.EqualFold(opt, "SETNAME") && i+1 < len(args):
+			name = string(args[i+1])
+			if !validClientName(name) {
+				return nil, fmt.Errorf("ERR Client names cannot contain spaces, newlines or special characters.")
+			}
+			setName = true
+			i++
+		default:
+			return nil, fmt.Errorf("ERR Syntax error in HELLO option '%s'", opt)
+		}
+	}
+
+	if auth {
+		if err := h.authenticate(sess, user, password); err != nil {
+			return nil, err
+		}
+	} else if sess.User == "" {
+		return nil, fmt.Errorf("NOAUTH HELLO must be called with the client already authenticated, otherwise the HELLO <proto> AUTH <user> <pass> option can be used to authenticate the client and select the RESP protocol version at the same time")
+	}
+
+	sess.Protocol = proto
+	if setName {
+		sess.Name = name
+	}
+	sess.publish()
+
+	return Map{
+		{BulkString("server"), BulkString("redis")},
+		{BulkString("version"), BulkString(redisVersion)},
+		{BulkString("proto"), int64(proto)},
+		{BulkString("id"), sess.ID},
+		{BulkString("mode"), BulkString("standalone")},
+		{BulkString("role"), BulkString("master")},
+		{BulkString("modules"), []string{}},
+	}, nil
+}
+
+// validClientName reports whether name may be used as a client name: only
+// printable ASCII characters without spaces
+func validClientName(name string) bool {
+	for i := 0; i < len(name); i++ {
+		if name[i] < '!' || name[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
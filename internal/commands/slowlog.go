@@ -0,0 +1,179 @@
+package commands
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shaso41/Backend-SystemFocus/internal/config"
+)
+
+// Limits on what a slow log entry keeps of a command line
+const (
+	slowLogMaxArgs   = 32
+	slowLogMaxString = 128
+)
+
+// slowLogEntry records a command that ran for longer than the threshold
+type slowLogEntry struct {
+	id       int64
+	time     int64 // Unix time in seconds
+	duration time.Duration
+	args     []string
+	addr     string
+	name     string
+}
+
+// slowLog keeps the most recent slow commands in a ring buffer
+type slowLog struct {
+	slowerThan *config.Value[int64]
+	maxLen     *config.Value[int64]
+
+	mu     sync.Mutex
+	nextID int64
+
+	// entries grows to maxLen entries; once full, next indexes the oldest,
+	// which the next entry overwrites
+	entries []slowLogEntry
+	next    int
+}
+
+// newSlowLog creates a slow log configured by slowlog-log-slower-than and
+// slowlog-max-len
+func newSlowLog(c *config.Config) *slowLog {
+	l := &slowLog{
+		slowerThan: c.Int(config.Spec{Name: "slowlog-log-slower-than", Mutable: true,
+			Doc: "Log commands running for at least this many microseconds; -1 disables the slow log"},
+			10000, -1, math.MaxInt64),
+		maxLen: c.Int(config.Spec{Name: "slowlog-max-len", Mutable: true,
+			Doc: "Most slow commands kept"}, 128, 0, math.MaxInt32),
+	}
+	l.maxLen.OnChange(func(n int64) error {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		entries := l.ordered()
+		l.entries, l.next = entries[max(0, len(entries)-int(n)):], 0
+		return nil
+	})
+	return l
+}
+
+// log records the command in args, which ran for duration, if it was slow
+func (l *slowLog) log(sess *Session, args [][]byte, duration time.Duration) {
+	threshold := l.slowerThan.Get()
+	if threshold < 0 || duration < time.Duration(threshold)*time.Microsecond {
+		return
+	}
+
+	entry := slowLogEntry{
+		time:     time.Now().Unix(),
+		duration: duration,
+		args:     slowLogArgs(args),
+		addr:     sess.Addr,
+		name:     sess.Name,
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry.id = l.nextID
+	l.nextID++
+
+	maxLen := int(l.maxLen.Get())
+	switch {
+	case len(l.entries) < maxLen:
+		l.entries = append(l.entries, entry)
+	case maxLen > 0:
+		l.entries[l.next] = entry
+		l.next = (l.next + 1) % maxLen
+	}
+}
+
+// slowLogArgs copies a command line for the slow log, truncating long
+// lines and arguments and redacting credentials
+func slowLogArgs(args [][]byte) []string {
+	n := min(len(args), slowLogMaxArgs)
+	logged := make([]string, n)
+	for i := range logged {
+		switch {
+		case i == slowLogMaxArgs-1 && len(args) > slowLogMaxArgs:
+			logged[i] = fmt.Sprintf("... (%d more arguments)", len(args)-i)
+		case secretArg(args, i):
+			logged[i] = "(redacted)"
+		case len(args[i]) > slowLogMaxString:
+			logged[i] = fmt.Sprintf("%s... (%d more bytes)", args[i][:slowLogMaxString], len(args[i])-slowLogMaxString)
+		default:
+			logged[i] = string(args[i])
+		}
+	}
+	return logged
+}
+
+// ordered returns a copy of the entries from oldest to newest
+func (l *slowLog) ordered() []slowLogEntry {
+	entries := make([]slowLogEntry, 0, len(l.entries))
+	entries = append(entries, l.entries[l.next:]...)
+	return append(entries, l.entries[:l.next]...)
+}
+
+// handleSlowLog handles SLOWLOG command
+// SLOWLOG GET [count] | LEN | RESET
+func (h *Handler) handleSlowLog(args [][]byte) (interface{}, error) {
+	sub := strings.ToLower(string(args[1]))
+	arityErr := fmt.Errorf("ERR wrong number of arguments for 'slowlog|%s' command", sub)
+	l := h.slowlog
+
+	switch sub {
+	case "get":
+		if len(args) > 3 {
+			return nil, arityErr
+		}
+		count := int64(10)
+		if len(args) == 3 {
+			n, err := strconv.ParseInt(string(args[2]), 10, 64)
+			if err != nil || n < -1 {
+				return nil, fmt.Errorf("ERR count should be greater than or equal to -1")
+			}
+			count = n
+		}
+
+		l.mu.Lock()
+		entries := l.ordered()
+		l.mu.Unlock()
+
+		if count < 0 || count > int64(len(entries)) {
+			count = int64(len(entries))
+		}
+		reply := make([]interface{}, count)
+		for i := range reply {
+			e := entries[len(entries)-1-i]
+			reply[i] = []interface{}{
+				e.id,
+				e.time,
+				e.duration.Microseconds(),
+				e.args,
+				BulkString(e.addr),
+				BulkString(e.name),
+			}
+		}
+		return reply, nil
+
+	case "len":
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		return int64(len(l.entries)), nil
+
+	case "reset":
+		l.mu.Lock()
+		l.entries, l.next = nil, 0
+		l.mu.Unlock()
+		return SimpleString("OK"), nil
+
+	default:
+		return nil, fmt.Errorf("ERR unknown subcommand '%s'. Try SLOWLOG HELP.", args[1])
+	}
+}
@@ -0,0 +1,129 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Shaso41/Backend-SystemFocus/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_SlowLog(t *testing.T) {
+	s := store.New()
+	defer s.Close()
+	h := NewHandler(s)
+
+	// The default threshold of 10ms is not reached by fast commands
+	h.Execute([]interface{}{"SET", "k", "v"})
+	result, err := h.Execute([]interface{}{"SLOWLOG", "LEN"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), result)
+
+	_, err = h.Execute([]interface{}{"CONFIG", "SET", "slowlog-log-slower-than", "0"})
+	require.NoError(t, err)
+
+	sess := h.NewSession()
+	sess.Addr, sess.Name = "127.0.0.1:5000", "worker"
+	_, err = h.Exec(sess, [][]byte{[]byte("GET"), []byte("k")})
+	require.NoError(t, err)
+
+	result, err = h.Execute([]interface{}{"SLOWLOG", "GET", "1"})
+	require.NoError(t, err)
+	entries := result.([]interface{})
+	require.Len(t, entries, 1)
+	entry := entries[0].([]interface{})
+	require.Len(t, entry, 6)
+	assert.Equal(t, int64(1), entry[0], "the CONFIG SET is entry 0")
+	assert.Greater(t, entry[1].(int64), int64(1600000000))
+	assert.GreaterOrEqual(t, entry[2].(int64), int64(0))
+	assert.Equal(t, []string{"GET", "k"}, entry[3])
+	assert.Equal(t, BulkString("127.0.0.1:5000"), entry[4])
+	assert.Equal(t, BulkString("worker"), entry[5])
+
+	// Entries come newest first, and SLOWLOG GET itself was logged
+	result, err = h.Execute([]interface{}{"SLOWLOG", "GET"})
+	require.NoError(t, err)
+	entries = result.([]interface{})
+	require.Len(t, entries, 3)
+	assert.Equal(t, []string{"SLOWLOG", "GET", "1"}, entries[0].([]interface{})[3])
+	assert.Equal(t, []string{"CONFIG", "SET", "slowlog-log-slower-than", "0"}, entries[2].([]interface{})[3])
+
+	result, err = h.Execute([]interface{}{"SLOWLOG", "RESET"})
+	require.NoError(t, err)
+	assert.Equal(t, SimpleString("OK"), result)
+	result, err = h.Execute([]interface{}{"SLOWLOG", "LEN"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), result, "only SLOWLOG RESET")
+
+	_, err = h.Execute([]interface{}{"SLOWLOG", "GET", "-2"})
+	assert.EqualError(t, err, "ERR count should be greater than or equal to -1")
+	_, err = h.Execute([]interface{}{"SLOWLOG", "LEN", "x"})
+	assert.EqualError(t, err, "ERR wrong number of arguments for 'slowlog|len' command")
+	_, err = h.Execute([]interface{}{"SLOWLOG", "BOGUS"})
+	assert.EqualError(t, err, "ERR unknown subcommand 'BOGUS'. Try SLOWLOG HELP.")
+
+	// Disabled entirely
+	h.Execute([]interface{}{"CONFIG", "SET", "slowlog-log-slower-than", "-1"})
+	h.Execute([]interface{}{"SLOWLOG", "RESET"})
+	h.Execute([]interface{}{"GET", "k"})
+	result, _ = h.Execute([]interface{}{"SLOWLOG", "LEN"})
+	assert.Equal(t, int64(0), result)
+}
+
+func TestHandler_SlowLogMaxLen(t *testing.T) {
+	s := store.New()
+	defer s.Close()
+	h := NewHandler(s)
+	h.Execute([]interface{}{"CONFIG", "SET", "slowlog-max-len", "3"})
+	h.Execute([]interface{}{"CONFIG", "SET", "slowlog-log-slower-than", "0"})
+
+	for i := 0; i < 5; i++ {
+		h.Execute([]interface{}{"GET", fmt.Sprint(i)})
+	}
+	result, err := h.Execute([]interface{}{"SLOWLOG", "GET", "-1"})
+	require.NoError(t, err)
+	entries := result.([]interface{})
+	require.Len(t, entries, 3)
+	for i, key := range []string{"4", "3", "2"} {
+		assert.Equal(t, int64(5-i), entries[i].([]interface{})[0])
+		assert.Equal(t, []string{"GET", key}, entries[i].([]interface{})[3])
+	}
+
+	// Shrinking keeps the newest entries
+	h.Execute([]interface{}{"CONFIG", "SET", "slowlog-max-len", "1"})
+	result, _ = h.Execute([]interface{}{"SLOWLOG", "GET"})
+	entries = result.([]interface{})
+	require.Len(t, entries, 1)
+	assert.Equal(t, []string{"CONFIG", "SET", "slowlog-max-len", "1"}, entries[0].([]interface{})[3])
+}
+
+func TestSlowLogArgs(t *testing.T) {
+	args := [][]byte{[]byte("DEL")}
+	for i := 0; i < 40; i++ {
+		args = append(args, []byte(fmt.Sprint(i)))
+	}
+	logged := slowLogArgs(args)
+	require.Len(t, logged, 32)
+	assert.Equal(t, "29", logged[30])
+	assert.Equal(t, "... (10 more arguments)", logged[31])
+
+	logged = slowLogArgs([][]byte{[]byte("SET"), []byte("k"), []byte(strings.Repeat("v", 200))})
+	assert.Equal(t, strings.Repeat("v", 128)+"... (72 more bytes)", logged[2])
+
+	redacted := func(args ...string) []string {
+		argv := make([][]byte, len(args))
+		for i, arg := range args {
+			argv[i] = []byte(arg)
+		}
+		return slowLogArgs(argv)
+	}
+	assert.Equal(t, []string{"AUTH", "(redacted)", "(redacted)"}, redacted("AUTH", "alice", "secret"))
+	assert.Equal(t, []string{"HELLO", "3", "AUTH", "(redacted)", "(redacted)", "SETNAME", "c"},
+		redacted("HELLO", "3", "AUTH", "alice", "secret", "SETNAME", "c"))
+	assert.Equal(t, []string{"ACL", "SETUSER", "alice", "on", "(redacted)", "~*"},
+		redacted("ACL", "SETUSER", "alice", "on", ">secret", "~*"))
+	assert.Equal(t, []string{"CONFIG", "SET", "timeout", "0", "requirepass", "(redacted)"},
+		redacted("CONFIG", "SET", "timeout", "0", "requirepass", "secret"))
+}
@@ -0,0 +1,162 @@
+package commands
+
+import (
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"github.com/Shaso41/Backend-SystemFocus/internal/histogram"
+)
+
+// clockStart anchors monotonic, which is cheaper than time.Now since it
+// does not read the wall clock
+var clockStart = time.Now()
+
+// monotonic returns the time elapsed since the process started
+func monotonic() time.Duration {
+	return time.Since(clockStart)
+}
+
+// commandStats counts the calls of one command or subcommand, reported by
+// INFO commandstats and latencystats and by LATENCY HISTOGRAM
+type commandStats struct {
+	calls    atomic.Int64
+	duration atomic.Int64 // nanoseconds
+	rejected atomic.Int64
+	failed   atomic.Int64
+
+	// Latencies in nanoseconds, allocated on the first call while
+	// latency-tracking is on
+	latencies atomic.Pointer[histogram.Histogram]
+}
+
+// record accounts for a call that ran for duration and failed if failed
+func (h *Handler) record(stats *commandStats, duration time.Duration, failed bool) {
+	stats.calls.Add(1)
+	stats.duration.Add(int64(duration))
+	if failed {
+		stats.failed.Add(1)
+	}
+
+	if !h.latencyTracking.Get() {
+		return
+	}
+	latencies := stats.latencies.Load()
+	if latencies == nil {
+		stats.latencies.CompareAndSwap(nil, histogram.New())
+		latencies = stats.latencies.Load()
+	}
+	latencies.Record(int64(duration))
+}
+
+// resetCommandStats clears the counters and latencies of every command
+func (h *Handler) resetCommandStats() {
+	for i := range h.commandStats {
+		stats := &h.commandStats[i]
+		stats.calls.Store(0)
+		stats.duration.Store(0)
+		stats.rejected.Store(0)
+		stats.failed.Store(0)
+		stats.latencies.Store(nil)
+	}
+}
+
+// Stats are the server-wide figures reported by INFO and exported as
+// metrics
+type Stats struct {
+	Uptime time.Duration
+
+	ConnectedClients    int
+	TotalConnections    int64
+	RejectedConnections int64
+	CommandsProcessed   int64
+	ErrorReplies        int64
+
+	Keys           int
+	ExpiringKeys   int
+	ExpiredKeys    int64
+	KeyspaceHits   int64
+	KeyspaceMisses int64
+
+	// Objects waiting for the lazy free worker, and released by it so far
+	LazyfreePendingObjects int64
+	LazyfreedObjects       int64
+}
+
+// Stats returns the current server-wide figures
+func (h *Handler) Stats() Stats {
+	return Stats{
+		Uptime:                 time.Since(h.started),
+		ConnectedClients:       h.ConnectedClients(),
+		TotalConnections:       h.totalConnections.Load(),
+		RejectedConnections:    h.rejectedConnections.Load(),
+		CommandsProcessed:      h.commandsProcessed.Load(),
+		ErrorReplies:           h.errorReplies.Load(),
+		Keys:                   h.store.Count(),
+		ExpiringKeys:           h.store.ExpiringCount(),
+		ExpiredKeys:            h.store.ExpiredKeys(),
+		KeyspaceHits:           h.store.KeyspaceHits(),
+		KeyspaceMisses:         h.store.KeyspaceMisses(),
+		LazyfreePendingObjects: h.store.LazyFreePending(),
+		LazyfreedObjects:       h.store.LazyFreed(),
+	}
+}
+
+// MemoryStats are the memory figures of the Go runtime, kept apart from
+// Stats since reading them stops the world
+type MemoryStats struct {
+	// Bytes of heap in use, the closest the server has to Redis'
+	// used_memory
+	Used uint64
+
+	// Bytes obtained from the operating system and not yet returned, the
+	// closest to Redis' used_memory_rss
+	System uint64
+}
+
+// MemoryStats returns the current memory figures
+func (h *Handler) MemoryStats() MemoryStats {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	return MemoryStats{
+		Used:   mem.HeapAlloc,
+		System: mem.Sys - mem.HeapReleased,
+	}
+}
+
+// CommandStats are the figures of one command or subcommand, such as
+// "client|list"
+type CommandStats struct {
+	Name     string
+	Calls    int64
+	Duration time.Duration
+	Rejected int64
+	Failed   int64
+
+	// Latencies in nanoseconds, or nil while latency-tracking was off
+	Latencies *histogram.Snapshot
+}
+
+// CommandStats returns the figures of every command called or rejected
+// so far, ordered by name
+func (h *Handler) CommandStats() []CommandStats {
+	var all []CommandStats
+	for id, name := range commandNames {
+		stats := &h.commandStats[id]
+		cs := CommandStats{
+			Name:     name,
+			Calls:    stats.calls.Load(),
+			Duration: time.Duration(stats.duration.Load()),
+			Rejected: stats.rejected.Load(),
+			Failed:   stats.failed.Load(),
+		}
+		if cs.Calls == 0 && cs.Rejected == 0 && cs.Failed == 0 {
+			continue
+		}
+		if latencies := stats.latencies.Load(); latencies != nil {
+			cs.Latencies = latencies.Snapshot()
+		}
+		all = append(all, cs)
+	}
+	return all
+}
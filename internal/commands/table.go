@@ -0,0 +1,421 @@
+package commands
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// commandFlags are the properties of a command reported by COMMAND INFO
+type commandFlags uint16
+
+const (
+	flagWrite commandFlags = 1 << iota
+	flagReadonly
+	flagDenyOOM
+	flagAdmin
+	flagPubsub
+	flagNoScript
+	flagLoading
+	flagStale
+	flagFast
+	flagNoAuth
+)
+
+// flagNames are the names of the flags in bit order
+var flagNames = []string{"write", "readonly", "denyoom", "admin", "pubsub", "noscript", "loading", "stale", "fast", "no_auth"}
+
+// names returns the names of the flags that are set
+func (f commandFlags) names() []string {
+	names := []string{}
+	for i, name := range flagNames {
+		if f&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// commandFunc runs a command for a session
+type commandFunc func(h *Handler, sess *Session, args [][]byte) (interface{}, error)
+
+// withArgs adapts a handler that does not need the session
+func withArgs(fn func(h *Handler, args [][]byte) (interface{}, error)) commandFunc {
+	return func(h *Handler, _ *Session, args [][]byte) (interface{}, error) {
+		return fn(h, args)
+	}
+}
+
+// commandDocs document a command for COMMAND DOCS
+type commandDocs struct {
+	summary    string
+	since      string
+	group      string
+	complexity string
+}
+
+// commandSpec describes a command or a subcommand of a container command
+type commandSpec struct {
+	name string
+
+	// arity is the number of arguments, counting the command name and
+	// any subcommand; a negative arity is a minimum of -arity arguments
+	arity int
+
+	flags      commandFlags
+	categories []string
+
+	// Key arguments are those from firstKey to lastKey, every keyStep.
+	// A negative lastKey counts from the end; firstKey 0 means no keys.
+	firstKey, lastKey, keyStep int
+
+	docs commandDocs
+
+	// run handles the command; the subcommands of a container command are
+	// run by their container unless they have their own
+	run commandFunc
+
+	// subcommands maps the lower-case subcommands of a container command,
+	// such as ACL, to their specs; each is permitted separately
+	subcommands map[string]*commandSpec
+	parent      *commandSpec
+
+	// aliasOf names the command an alias such as DELETE runs the same as.
+	// Aliases are left out of COMMAND's listings and COMMAND COUNT.
+	aliasOf string
+
+	// id numbers the command in commandNames
+	id int
+}
+
+// commandSpecs maps upper-case command names to their specs
+var commandSpecs map[string]*commandSpec
+
+// commandNames holds the names of all commands and subcommands, such as
+// "client|list", indexed by their ids
+var commandNames []string
+
+func init() {
+	commandSpecs = make(map[string]*commandSpec)
+	for _, spec := range commandTable() {
+		commandSpecs[strings.ToUpper(spec.name)] = spec
+	}
+
+	names := make([]string, 0, len(commandSpecs))
+	for _, spec := range commandSpecs {
+		names = append(names, spec.name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		spec := commandSpecs[strings.ToUpper(name)]
+		spec.id = len(commandNames)
+		commandNames = append(commandNames, spec.name)
+
+		for _, sub := range spec.subcommandNames() {
+			subSpec := spec.subcommands[sub]
+			subSpec.name = spec.name + "|" + sub
+			subSpec.parent = spec
+			subSpec.docs.group = spec.docs.group
+			subSpec.id = len(commandNames)
+			commandNames = append(commandNames, subSpec.name)
+		}
+	}
+}
+
+// commandTable lists every command the server supports
+func commandTable() []*commandSpec {
+	const (
+		admin = flagAdmin | flagNoScript | flagLoading | flagStale
+		info  = flagNoScript | flagLoading | flagStale
+	)
+	adminCategories := []string{"admin", "slow", "dangerous"}
+
+	return []*commandSpec{
+		{name: "ping", arity: -1, flags: flagFast, categories: []string{"fast", "connection"},
+			run:  withArgs((*Handler).handlePing),
+			docs: commandDocs{"Returns the server's liveliness response.", "1.0.0", "connection", "O(1)"}},
+		{name: "hello", arity: -1, flags: info | flagFast | flagNoAuth, categories: []string{"fast", "connection"},
+			run:  (*Handler).handleHello,
+			docs: commandDocs{"Handshakes with the Redis server.", "6.0.0", "connection", "O(1)"}},
+		{name: "auth", arity: -2, flags: info | flagFast | flagNoAuth, categories: []string{"fast", "connection"},
+			run: (*Handler).handleAuth,
+			docs: commandDocs{"Authenticates the connection.", "1.0.0", "connection",
+				"O(N) where N is the number of passwords defined for the user"}},
+
+		{name: "set", arity: -3, flags: flagWrite | flagDenyOOM, categories: []string{"write", "string", "slow"},
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			run: withArgs((*Handler).handleSet),
+			docs: commandDocs{"Sets the string value of a key, ignoring its type. The key is created if it doesn't exist.",
+				"1.0.0", "string", "O(1)"}},
+		{name: "get", arity: 2, flags: flagReadonly | flagFast, categories: []string{"read", "string", "fast"},
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			run:  withArgs((*Handler).handleGet),
+			docs: commandDocs{"Returns the string value of a key.", "1.0.0", "string", "O(1)"}},
+
+		{name: "del", arity: 2, flags: flagWrite, categories: []string{"keyspace", "write", "slow"},
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			run:  withArgs((*Handler).handleDelete),
+			docs: commandDocs{"Deletes a key.", "1.0.0", "generic", "O(1)"}},
+		{name: "delete", arity: 2, flags: flagWrite, categories: []string{"keyspace", "write", "slow"},
+			firstKey: 1, lastKey: 1, keyStep: 1, aliasOf: "del",
+			run:  withArgs((*Handler).handleDelete),
+			docs: commandDocs{"Deletes a key. An alias of DEL.", "", "generic", "O(1)"}},
+		{name: "unlink", arity: -2, flags: flagWrite | flagFast, categories: []string{"keyspace", "write", "fast"},
+			firstKey: 1, lastKey: -1, keyStep: 1,
+			run: withArgs((*Handler).handleUnlink),
+			docs: commandDocs{"Asynchronously deletes one or more keys.", "4.0.0", "generic",
+				"O(1) for each key removed regardless of its size. The memory of the values is then reclaimed in the background."}},
+		{name: "flushdb", arity: -1, flags: flagWrite, categories: []string{"keyspace", "write", "slow", "dangerous"},
+			run: withArgs((*Handler).handleFlush),
+			docs: commandDocs{"Removes all keys from the current database.", "1.0.0", "server",
+				"O(N) where N is the total number of keys, or O(1) when flushing asynchronously"}},
+		{name: "flushall", arity: -1, flags: flagWrite, categories: []string{"keyspace", "write", "slow", "dangerous"},
+			run: withArgs((*Handler).handleFlush),
+			docs: commandDocs{"Removes all keys from all databases.", "1.0.0", "server",
+				"O(N) where N is the total number of keys, or O(1) when flushing asynchronously"}},
+		{name: "exists", arity: 2, flags: flagReadonly | flagFast, categories: []string{"keyspace", "read", "fast"},
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			run:  withArgs((*Handler).handleExists),
+			docs: commandDocs{"Determines whether a key exists.", "1.0.0", "generic", "O(1)"}},
+		{name: "keys", arity: 2, flags: flagReadonly, categories: []string{"keyspace", "read", "slow", "dangerous"},
+			run: withArgs((*Handler).handleKeys),
+			docs: commandDocs{"Returns all key names that match a pattern.", "1.0.0", "generic",
+				"O(N) with N being the number of keys in the database"}},
+		{name: "scan", arity: -2, flags: flagReadonly, categories: []string{"keyspace", "read", "slow"},
+			run: withArgs((*Handler).handleScan),
+			docs: commandDocs{"Iterates over the key names in the database.", "2.8.0", "generic",
+				"O(1) for every call. O(N) for a complete iteration, including enough command calls for the cursor to return back to 0. N is the number of elements inside the collection."}},
+		{name: "expire", arity: 3, flags: flagWrite | flagFast, categories: []string{"keyspace", "write", "fast"},
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			run:  withArgs((*Handler).handleExpire),
+			docs: commandDocs{"Sets the expiration time of a key in seconds.", "1.0.0", "generic", "O(1)"}},
+		{name: "ttl", arity: 2, flags: flagReadonly | flagFast, categories: []string{"keyspace", "read", "fast"},
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			run:  withArgs((*Handler).handleTTL),
+			docs: commandDocs{"Returns the expiration time in seconds of a key.", "1.0.0", "generic", "O(1)"}},
+
+		{name: "setbit", arity: 4, flags: flagWrite | flagDenyOOM, categories: []string{"write", "bitmap", "slow"},
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			run: withArgs((*Handler).handleSetBit),
+			docs: commandDocs{"Sets or clears the bit at offset of the string value. Creates the key if it doesn't exist.",
+				"2.2.0", "bitmap", "O(1)"}},
+		{name: "getbit", arity: 3, flags: flagReadonly | flagFast, categories: []string{"read", "bitmap", "fast"},
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			run:  withArgs((*Handler).handleGetBit),
+			docs: commandDocs{"Returns a bit value by offset.", "2.2.0", "bitmap", "O(1)"}},
+		{name: "bitcount", arity: -2, flags: flagReadonly, categories: []string{"read", "bitmap", "slow"},
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			run:  withArgs((*Handler).handleBitCount),
+			docs: commandDocs{"Counts the number of set bits (population counting) in a string.", "2.6.0", "bitmap", "O(N)"}},
+		{name: "bitpos", arity: -3, flags: flagReadonly, categories: []string{"read", "bitmap", "slow"},
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			run:  withArgs((*Handler).handleBitPos),
+			docs: commandDocs{"Finds the first set (1) or clear (0) bit in a string.", "2.8.7", "bitmap", "O(N)"}},
+		{name: "bitop", arity: -4, flags: flagWrite | flagDenyOOM, categories: []string{"write", "bitmap", "slow"},
+			firstKey: 2, lastKey: -1, keyStep: 1,
+			run: withArgs((*Handler).handleBitOp),
+			docs: commandDocs{"Performs bitwise operations on multiple strings, and stores the result.",
+				"2.6.0", "bitmap", "O(N)"}},
+		{name: "bitfield", arity: -2, flags: flagWrite | flagDenyOOM, categories: []string{"write", "bitmap", "slow"},
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			run: withArgs((*Handler).handleBitField),
+			docs: commandDocs{"Performs arbitrary bitfield integer operations on strings.",
+				"3.2.0", "bitmap", "O(1) for each subcommand specified"}},
+		{name: "bitfield_ro", arity: -2, flags: flagReadonly | flagFast, categories: []string{"read", "bitmap", "fast"},
+			firstKey: 1, lastKey: 1, keyStep: 1,
+			run: withArgs((*Handler).handleBitFieldRO),
+			docs: commandDocs{"Performs arbitrary read-only bitfield integer operations on strings.",
+				"6.0.0", "bitmap", "O(1) for each subcommand specified"}},
+
+		{name: "info", arity: -1, flags: flagLoading | flagStale, categories: []string{"slow", "dangerous"},
+			run:  withArgs((*Handler).handleInfo),
+			docs: commandDocs{"Returns information and statistics about the server.", "1.0.0", "server", "O(1)"}},
+		{name: "monitor", arity: 1, flags: admin, categories: adminCategories,
+			run:  (*Handler).handleMonitor,
+			docs: commandDocs{"Listens for all requests received by the server in real-time.", "1.0.0", "server", ""}},
+
+		{name: "command", arity: -1, flags: flagLoading | flagStale, categories: []string{"slow", "connection"},
+			run: withArgs((*Handler).handleCommand),
+			docs: commandDocs{"Returns detailed information about all commands.", "2.8.13", "server",
+				"O(N) where N is the total number of Redis commands"},
+			subcommands: map[string]*commandSpec{
+				"count": {arity: 2, flags: flagLoading | flagStale, categories: []string{"slow", "connection"},
+					docs: commandDocs{"Returns a count of commands.", "2.8.13", "", "O(1)"}},
+				"docs": {arity: -2, flags: flagLoading | flagStale, categories: []string{"slow", "connection"},
+					docs: commandDocs{"Returns documentary information about one, multiple or all commands.", "7.0.0", "",
+						"O(N) where N is the number of commands to look up"}},
+				"getkeys": {arity: -3, flags: flagLoading | flagStale, categories: []string{"slow", "connection"},
+					docs: commandDocs{"Extracts the key names from an arbitrary command.", "2.8.13", "",
+						"O(N) where N is the number of arguments to the command"}},
+				"info": {arity: -2, flags: flagLoading | flagStale, categories: []string{"slow", "connection"},
+					docs: commandDocs{"Returns information about one, multiple or all commands.", "2.8.13", "",
+						"O(N) where N is the number of commands to look up"}},
+				"help": helpSubcommand("5.0.0", "slow", "connection"),
+				"list": {arity: -2, flags: flagLoading | flagStale, categories: []string{"slow", "connection"},
+					docs: commandDocs{"Returns a list of command names.", "7.0.0", "",
+						"O(N) where N is the total number of Redis commands"}},
+			}},
+
+		{name: "acl", arity: -2, run: (*Handler).handleACL,
+			docs: commandDocs{"A container for Access List Control commands.", "6.0.0", "server", "Depends on subcommand."},
+			subcommands: map[string]*commandSpec{
+				"cat": {arity: -2, flags: info, categories: []string{"slow"},
+					docs: commandDocs{"Lists the ACL categories, or the commands inside a category.", "6.0.0", "",
+						"O(1) since the categories and commands are a fixed set."}},
+				"deluser": {arity: -3, flags: admin, categories: adminCategories,
+					docs: commandDocs{"Deletes ACL users, and terminates their connections.", "6.0.0", "",
+						"O(1) amortized time considering the typical user."}},
+				"getuser": {arity: 3, flags: admin, categories: adminCategories,
+					docs: commandDocs{"Lists the ACL rules of a user.", "6.0.0", "",
+						"O(N). Where N is the number of password, command and pattern rules that the user has."}},
+				"help": helpSubcommand("6.0.0", "slow"),
+				"list": {arity: 2, flags: admin, categories: adminCategories,
+					docs: commandDocs{"Dumps the effective rules in ACL file format.", "6.0.0", "",
+						"O(N). Where N is the number of configured users."}},
+				"load": {arity: 2, flags: admin, categories: adminCategories,
+					docs: commandDocs{"Reloads the rules from the configured ACL file.", "6.0.0", "",
+						"O(N). Where N is the number of configured users."}},
+				"log": {arity: -2, flags: admin, categories: adminCategories,
+					docs: commandDocs{"Lists recent security events generated due to ACL rules.", "6.0.0", "",
+						"O(N) with N being the number of entries shown."}},
+				"save": {arity: 2, flags: admin, categories: adminCategories,
+					docs: commandDocs{"Saves the effective ACL rules in the configured ACL file.", "6.0.0", "",
+						"O(N). Where N is the number of configured users."}},
+				"setuser": {arity: -3, flags: admin, categories: adminCategories,
+					docs: commandDocs{"Creates and modifies an ACL user and its rules.", "6.0.0", "",
+						"O(N). Where N is the number of rules provided."}},
+				"whoami": {arity: 2, flags: info, categories: []string{"slow"},
+					docs: commandDocs{"Returns the authenticated username of the current connection.", "6.0.0", "", "O(1)"}},
+			}},
+
+		{name: "config", arity: -2, run: withArgs((*Handler).handleConfig),
+			docs: commandDocs{"A container for server configuration commands.", "2.0.0", "server", "Depends on subcommand."},
+			subcommands: map[string]*commandSpec{
+				"get": {arity: -3, flags: admin, categories: adminCategories,
+					docs: commandDocs{"Returns the effective values of configuration parameters.", "2.0.0", "",
+						"O(N) when N is the number of configuration parameters provided"}},
+				"help": helpSubcommand("5.0.0", "slow"),
+				"resetstat": {arity: 2, flags: admin, categories: adminCategories,
+					docs: commandDocs{"Resets the server's statistics.", "2.0.0", "", "O(1)"}},
+				"rewrite": {arity: 2, flags: admin, categories: adminCategories,
+					docs: commandDocs{"Persists the effective configuration to file.", "2.8.0", "", "O(1)"}},
+				"set": {arity: -4, flags: admin, categories: adminCategories,
+					docs: commandDocs{"Sets configuration parameters in-flight.", "2.0.0", "",
+						"O(N) when N is the number of configuration parameters provided"}},
+			}},
+
+		{name: "latency", arity: -2, run: withArgs((*Handler).handleLatency),
+			docs: commandDocs{"A container for latency diagnostics commands.", "2.8.13", "server", "Depends on subcommand."},
+			subcommands: map[string]*commandSpec{
+				"histogram": {arity: -2, flags: admin, categories: adminCategories,
+					docs: commandDocs{"Returns the cumulative distribution of latencies of a subset or all commands.", "7.0.0", "",
+						"O(N) where N is the number of commands with latency information being retrieved."}},
+				"help": helpSubcommand("2.8.13", "slow"),
+				"history": {arity: 3, flags: admin, categories: adminCategories,
+					docs: commandDocs{"Returns timestamp-latency samples for an event.", "2.8.13", "", "O(1)"}},
+				"latest": {arity: 2, flags: admin, categories: adminCategories,
+					docs: commandDocs{"Returns the latest latency samples for all events.", "2.8.13", "", "O(1)"}},
+				"reset": {arity: -2, flags: admin, categories: adminCategories,
+					docs: commandDocs{"Resets the latency data for one or more events.", "2.8.13", "", "O(1)"}},
+			}},
+
+		{name: "slowlog", arity: -2, run: withArgs((*Handler).handleSlowLog),
+			docs: commandDocs{"A container for slow log commands.", "2.2.12", "server", "Depends on subcommand."},
+			subcommands: map[string]*commandSpec{
+				"get": {arity: -2, flags: admin, categories: adminCategories,
+					docs: commandDocs{"Returns the slow log's entries.", "2.2.12", "",
+						"O(N) where N is the number of entries returned"}},
+				"help": helpSubcommand("6.2.0", "slow"),
+				"len": {arity: 2, flags: admin, categories: adminCategories,
+					docs: commandDocs{"Returns the number of entries in the slow log.", "2.2.12", "", "O(1)"}},
+				"reset": {arity: 2, flags: admin, categories: adminCategories,
+					docs: commandDocs{"Clears all entries from the slow log.", "2.2.12", "",
+						"O(N) where N is the number of entries in the slowlog"}},
+			}},
+
+		{name: "client", arity: -2, run: (*Handler).handleClient,
+			docs: commandDocs{"A container for client connection commands.", "2.4.0", "connection", "Depends on subcommand."},
+			subcommands: map[string]*commandSpec{
+				"getname": {arity: 2, flags: info, categories: []string{"slow", "connection"},
+					docs: commandDocs{"Returns the name of the connection.", "2.6.9", "", "O(1)"}},
+				"help": helpSubcommand("5.0.0", "slow", "connection"),
+				"id": {arity: 2, flags: info, categories: []string{"slow", "connection"},
+					docs: commandDocs{"Returns the unique client ID of the connection.", "5.0.0", "", "O(1)"}},
+				"info": {arity: 2, flags: info, categories: []string{"slow", "connection"},
+					docs: commandDocs{"Returns information about the connection.", "6.2.0", "", "O(1)"}},
+				"kill": {arity: -3, flags: admin, categories: []string{"admin", "slow", "dangerous", "connection"},
+					docs: commandDocs{"Terminates open connections.", "2.4.0", "",
+						"O(N) where N is the number of client connections"}},
+				"list": {arity: -2, flags: admin, categories: []string{"admin", "slow", "dangerous", "connection"},
+					docs: commandDocs{"Lists open connections.", "2.4.0", "",
+						"O(N) where N is the number of client connections"}},
+				"no-evict": {arity: 3, flags: admin, categories: []string{"admin", "slow", "dangerous", "connection"},
+					docs: commandDocs{"Sets the client eviction mode of the connection.", "7.0.0", "", "O(1)"}},
+				"pause": {arity: -3, flags: admin, categories: []string{"admin", "slow", "dangerous", "connection"},
+					docs: commandDocs{"Suspends commands processing.", "3.0.0", "", "O(1)"}},
+				"setname": {arity: 3, flags: info, categories: []string{"slow", "connection"},
+					docs: commandDocs{"Sets the connection name.", "2.6.9", "", "O(1)"}},
+				"unpause": {arity: 2, flags: admin, categories: []string{"admin", "slow", "dangerous", "connection"},
+					docs: commandDocs{"Resumes processing commands from paused clients.", "6.2.0", "",
+						"O(N) Where N is the number of paused clients"}},
+			}},
+	}
+}
+
+// helpSubcommand returns the spec of the HELP subcommand of a container
+// command, which lists the container's subcommands
+func helpSubcommand(since string, categories ...string) *commandSpec {
+	return &commandSpec{arity: 2, flags: flagLoading | flagStale, categories: categories,
+		run:  withArgs((*Handler).handleHelp),
+		docs: commandDocs{"Returns helpful text about the different subcommands.", since, "", "O(1)"}}
+}
+
+// subcommandNames returns the sorted subcommands of a container command
+func (spec *commandSpec) subcommandNames() []string {
+	names := make([]string, 0, len(spec.subcommands))
+	for name := range spec.subcommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolve returns the spec of the subcommand args call when spec is a
+// container command and the subcommand exists, or else spec itself
+func (spec *commandSpec) resolve(args [][]byte) *commandSpec {
+	if spec.subcommands != nil && len(args) > 1 {
+		var buf [maxCommandName]byte
+		if sub, ok := spec.subcommands[string(lower(buf[:0], args[1]))]; ok {
+			return sub
+		}
+	}
+	return spec
+}
+
+// check validates the number of arguments of a call resolved to spec,
+// and that container commands are given a known subcommand
+func (spec *commandSpec) check(args [][]byte) error {
+	if spec.subcommands != nil && len(args) > 1 {
+		return fmt.Errorf("ERR unknown subcommand '%s'. Try %s HELP.", args[1], strings.ToUpper(spec.name))
+	}
+	if spec.arity > 0 && len(args) != spec.arity || len(args) < -spec.arity {
+		return fmt.Errorf("ERR wrong number of arguments for '%s' command", spec.name)
+	}
+	return nil
+}
+
+// keys appends the key arguments of a command to dst
+func (spec *commandSpec) keys(dst [][]byte, args [][]byte) [][]byte {
+	if spec.firstKey == 0 {
+		return dst
+	}
+	last := spec.lastKey
+	if last < 0 {
+		last += len(args)
+	}
+	for i := spec.firstKey; i <= last && i < len(args); i += spec.keyStep {
+		dst = append(dst, args[i])
+	}
+	return dst
+}
@@ -0,0 +1,202 @@
+// Package config is a registry of typed configuration parameters that can
+// be read from a redis.conf-style file, inspected and changed at runtime,
+// and written back to the file.
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Shaso41/Backend-SystemFocus/internal/glob"
+)
+
+// Spec names and documents a parameter being registered
+type Spec struct {
+	Name string
+	Doc  string
+
+	// Mutable parameters may be changed at runtime with CONFIG SET
+	Mutable bool
+
+	// MultiWord values are written as several words on a configuration
+	// file line, as lists are
+	MultiWord bool
+}
+
+// ParamInfo describes a registered parameter
+type ParamInfo struct {
+	Name    string
+	Doc     string
+	Default string
+	Mutable bool
+}
+
+// Config holds the registered parameters and the file they were loaded
+// from. Values are read lock-free through the typed handles returned at
+// registration; changes are serialized.
+type Config struct {
+	mu       sync.Mutex
+	settings map[string]setting
+	order    []string
+	file     string
+}
+
+// setting is the type-independent view of a Value
+type setting interface {
+	info() *param
+	String() string
+	set(value string) error
+	runHooks() error
+}
+
+// New creates an empty registry
+func New() *Config {
+	return &Config{settings: make(map[string]setting)}
+}
+
+// register adds a setting, panicking on duplicate names since those are
+// programming errors
+func (c *Config) register(s setting) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	name := s.info().name
+	if _, dup := c.settings[name]; dup {
+		panic(fmt.Sprintf("config: parameter %q registered twice", name))
+	}
+	c.settings[name] = s
+	c.order = append(c.order, name)
+}
+
+// Params describes all parameters in registration order
+func (c *Config) Params() []ParamInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	params := make([]ParamInfo, len(c.order))
+	for i, name := range c.order {
+		p := c.settings[name].info()
+		params[i] = ParamInfo{Name: p.name, Doc: p.doc, Default: p.def, Mutable: p.mutable}
+	}
+	return params
+}
+
+// Lookup returns the current value of a parameter as a string
+func (c *Config) Lookup(name string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s, ok := c.settings[strings.ToLower(name)]
+	if !ok {
+		return "", false
+	}
+	return s.String(), true
+}
+
+// Get returns the names and current values of the parameters matching
+// any of the glob patterns, case-insensitively, sorted by name
+func (c *Config) Get(patterns ...string) [][2]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var pairs [][2]string
+	for name, s := range c.settings {
+		for _, pattern := range patterns {
+			if glob.MatchFold(pattern, name) {
+				pairs = append(pairs, [2]string{name, s.String()})
+				break
+			}
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i][0] < pairs[j][0] })
+	return pairs
+}
+
+// Set changes a parameter whether or not it is mutable, as done while
+// starting up, and runs its change hooks. If a hook fails the old value is
+// restored.
+func (c *Config) Set(name, value string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s, ok := c.settings[strings.ToLower(name)]
+	if !ok {
+		return fmt.Errorf("unknown parameter '%s'", name)
+	}
+	if _, err := c.apply([]setting{s}, []string{value}); err != nil {
+		return fmt.Errorf("%s: %v", s.info().name, err)
+	}
+	return nil
+}
+
+// SetRuntime applies CONFIG SET name value [name value ...]: every
+// parameter must exist, be mutable and accept its value, or nothing
+// changes. Errors are worded like Redis's.
+func (c *Config) SetRuntime(pairs ...string) error {
+	if len(pairs) == 0 || len(pairs)%2 != 0 {
+		return fmt.Errorf("wrong number of arguments for 'config|set' command")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	settings := make([]setting, 0, len(pairs)/2)
+	values := make([]string, 0, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		s, ok := c.settings[strings.ToLower(pairs[i])]
+		if !ok {
+			return fmt.Errorf("Unknown option or number of arguments for CONFIG SET - '%s'", pairs[i])
+		}
+		for _, seen := range settings {
+			if seen == s {
+				return fmt.Errorf("Duplicate parameter - '%s'", pairs[i])
+			}
+		}
+		if !s.info().mutable {
+			return fmt.Errorf("CONFIG SET failed (possibly related to argument '%s') - can't set immutable config", pairs[i])
+		}
+		settings = append(settings, s)
+		values = append(values, pairs[i+1])
+	}
+
+	if failed, err := c.apply(settings, values); err != nil {
+		return fmt.Errorf("CONFIG SET failed (possibly related to argument '%s') - %v", failed.info().name, err)
+	}
+	return nil
+}
+
+// apply sets all settings to their values and runs their hooks. On any
+// error the previous values are restored and the setting that failed is
+// returned. The caller must hold c.mu.
+func (c *Config) apply(settings []setting, values []string) (setting, error) {
+	old := make([]string, len(settings))
+	for i, s := range settings {
+		old[i] = s.String()
+	}
+
+	restore := func(n int) {
+		for i := 0; i < n; i++ {
+			settings[i].set(old[i])
+		}
+	}
+
+	for i, s := range settings {
+		if err := s.set(values[i]); err != nil {
+			restore(i)
+			return s, err
+		}
+	}
+	for _, s := range settings {
+		if err := s.runHooks(); err != nil {
+			// Hooks see the old values again so their effects are undone
+			restore(len(settings))
+			for _, s := range settings {
+				s.runHooks()
+			}
+			return s, err
+		}
+	}
+	return nil, nil
+}
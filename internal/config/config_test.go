@@ -0,0 +1,200 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_TypedValues(t *testing.T) {
+	c := New()
+	timeout := c.Int(Spec{Name: "timeout", Mutable: true}, 0, 0, 3600)
+	maxmem := c.Memory(Spec{Name: "maxmemory", Mutable: true}, 0, 0, 1<<40)
+	perm := c.Octal(Spec{Name: "unixsocketperm"}, 0)
+	lazy := c.Bool(Spec{Name: "lazyfree", Mutable: true}, false)
+	policy := c.Enum(Spec{Name: "policy", Mutable: true}, "noeviction", "noeviction", "allkeys-lru")
+	addrs := c.List(Spec{Name: "addr"}, []string{":6379"})
+
+	require.NoError(t, c.Set("TIMEOUT", "30"))
+	require.NoError(t, c.Set("maxmemory", "2mb"))
+	require.NoError(t, c.Set("unixsocketperm", "770"))
+	require.NoError(t, c.Set("lazyfree", "YES"))
+	require.NoError(t, c.Set("policy", "AllKeys-LRU"))
+	require.NoError(t, c.Set("addr", ":6380  /tmp/redis.sock"))
+
+	assert.Equal(t, int64(30), timeout.Get())
+	assert.Equal(t, int64(2<<20), maxmem.Get())
+	assert.Equal(t, int64(0o770), perm.Get())
+	assert.True(t, lazy.Get())
+	assert.Equal(t, "allkeys-lru", policy.Get())
+	assert.Equal(t, []string{":6380", "/tmp/redis.sock"}, addrs.Get())
+
+	assert.EqualError(t, c.Set("timeout", "abc"), "timeout: argument couldn't be parsed into an integer")
+	assert.EqualError(t, c.Set("timeout", "4000"), "timeout: argument must be between 0 and 3600 inclusive")
+	assert.EqualError(t, c.Set("lazyfree", "maybe"), "lazyfree: argument must be 'yes' or 'no'")
+	assert.EqualError(t, c.Set("policy", "random"), "policy: argument(s) must be one of the following: noeviction, allkeys-lru")
+	assert.EqualError(t, c.Set("nope", "1"), "unknown parameter 'nope'")
+	assert.Equal(t, int64(30), timeout.Get())
+
+	assert.Panics(t, func() { c.Int(Spec{Name: "Timeout"}, 0, 0, 1) })
+}
+
+func TestParseMemory(t *testing.T) {
+	for in, want := range map[string]int64{"100": 100, "1k": 1000, "1kb": 1024, "1M": 1000000, "3GB": 3 << 30} {
+		n, err := ParseMemory(in)
+		assert.NoError(t, err, in)
+		assert.Equal(t, want, n, in)
+	}
+	for _, in := range []string{"", "kb", "-1", "1tb", "12x"} {
+		_, err := ParseMemory(in)
+		assert.Error(t, err, in)
+	}
+}
+
+func TestConfig_GetPatterns(t *testing.T) {
+	c := New()
+	c.Int(Spec{Name: "maxclients"}, 10000, 1, 1<<20)
+	c.Int(Spec{Name: "maxmemory"}, 0, 0, 1<<40)
+	c.Int(Spec{Name: "timeout"}, 0, 0, 1<<20)
+
+	assert.Equal(t, [][2]string{{"maxclients", "10000"}, {"maxmemory", "0"}}, c.Get("MAX*"))
+	assert.Equal(t, [][2]string{{"maxclients", "10000"}, {"timeout", "0"}}, c.Get("timeout", "maxc*", "timeout"))
+	assert.Empty(t, c.Get("nothing"))
+}
+
+func TestConfig_SetRuntimeIsAtomic(t *testing.T) {
+	c := New()
+	a := c.Int(Spec{Name: "a", Mutable: true}, 1, 0, 10)
+	b := c.Int(Spec{Name: "b", Mutable: true}, 2, 0, 10)
+	c.String(Spec{Name: "fixed"}, "x")
+
+	require.NoError(t, c.SetRuntime("a", "5", "B", "6"))
+	assert.Equal(t, int64(5), a.Get())
+	assert.Equal(t, int64(6), b.Get())
+
+	err := c.SetRuntime("a", "7", "b", "99")
+	assert.EqualError(t, err, "CONFIG SET failed (possibly related to argument 'b') - argument must be between 0 and 10 inclusive")
+	assert.Equal(t, int64(5), a.Get())
+
+	assert.EqualError(t, c.SetRuntime("fixed", "y"), "CONFIG SET failed (possibly related to argument 'fixed') - can't set immutable config")
+	assert.EqualError(t, c.SetRuntime("a", "1", "a", "2"), "Duplicate parameter - 'a'")
+	assert.EqualError(t, c.SetRuntime("nope", "1"), "Unknown option or number of arguments for CONFIG SET - 'nope'")
+	assert.EqualError(t, c.SetRuntime("a"), "wrong number of arguments for 'config|set' command")
+}
+
+func TestConfig_HookFailureRestores(t *testing.T) {
+	c := New()
+	a := c.Int(Spec{Name: "a", Mutable: true}, 1, 0, 10)
+	b := c.Int(Spec{Name: "b", Mutable: true}, 2, 0, 10)
+
+	var applied []int64
+	a.OnChange(func(n int64) error {
+		applied = append(applied, n)
+		return nil
+	})
+	b.OnChange(func(n int64) error {
+		if n == 9 {
+			return errors.New("nine is not allowed")
+		}
+		return nil
+	})
+
+	err := c.SetRuntime("a", "3", "b", "9")
+	assert.EqualError(t, err, "CONFIG SET failed (possibly related to argument 'b') - nine is not allowed")
+	assert.Equal(t, int64(1), a.Get())
+	assert.Equal(t, int64(2), b.Get())
+	assert.Equal(t, []int64{3, 1}, applied)
+}
+
+func TestConfig_LoadFile(t *testing.T) {
+	c := New()
+	timeout := c.Int(Spec{Name: "timeout"}, 0, 0, 3600)
+	pass := c.String(Spec{Name: "requirepass"}, "")
+	addrs := c.List(Spec{Name: "addr"}, nil)
+
+	path := filepath.Join(t.TempDir(), "redis.conf")
+	require.NoError(t, os.WriteFile(path, []byte(`# comment
+timeout 60
+
+requirepass "with space"
+addr :6380 /tmp/sock
+`), 0o600))
+
+	require.NoError(t, c.Load(path))
+	assert.Equal(t, int64(60), timeout.Get())
+	assert.Equal(t, "with space", pass.Get())
+	assert.Equal(t, []string{":6380", "/tmp/sock"}, addrs.Get())
+	assert.Equal(t, path, c.File())
+
+	for content, want := range map[string]string{
+		"bogus 1\n":         ":1: Bad directive or wrong number of arguments",
+		"\ntimeout 1 2\n":   ":2: Bad directive or wrong number of arguments",
+		"timeout\n":         ":1: Bad directive or wrong number of arguments",
+		"timeout 99999\n":   ":1: timeout: argument must be between 0 and 3600 inclusive",
+		"requirepass \"x\n": ":1: Unbalanced quotes in configuration line",
+	} {
+		bad := filepath.Join(t.TempDir(), "bad.conf")
+		require.NoError(t, os.WriteFile(bad, []byte(content), 0o600))
+		err := c.Load(bad)
+		if assert.Error(t, err, content) {
+			assert.Contains(t, err.Error(), want, content)
+		}
+	}
+}
+
+func TestConfig_Rewrite(t *testing.T) {
+	c := New()
+	c.Int(Spec{Name: "timeout", Mutable: true}, 0, 0, 3600)
+	c.String(Spec{Name: "requirepass", Mutable: true}, "")
+	c.Int(Spec{Name: "hz", Mutable: true}, 10, 1, 500)
+	c.Int(Spec{Name: "maxclients", Mutable: true}, 10000, 1, 100000)
+
+	assert.ErrorIs(t, c.Rewrite(), ErrNoFile)
+
+	path := filepath.Join(t.TempDir(), "redis.conf")
+	require.NoError(t, os.WriteFile(path, []byte("timeout 60\n"), 0o640))
+	require.NoError(t, c.Load(path))
+
+	// The file may have been edited since it was loaded
+	require.NoError(t, os.WriteFile(path, []byte(`# keep me
+timeout 60
+unknown-directive yes
+timeout 70
+hz 10
+`), 0o640))
+
+	require.NoError(t, c.SetRuntime("timeout", "120", "requirepass", "p w", "hz", "20"))
+	require.NoError(t, c.Rewrite())
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, `# keep me
+timeout 120
+unknown-directive yes
+hz 20
+# Generated by CONFIG REWRITE
+requirepass "p w"
+`, string(content))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o640), info.Mode().Perm())
+
+	// A second rewrite is stable and the file loads back
+	require.NoError(t, c.Rewrite())
+	again, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, content, again)
+
+	reloaded := New()
+	pass := reloaded.String(Spec{Name: "requirepass"}, "")
+	reloaded.Int(Spec{Name: "timeout"}, 0, 0, 3600)
+	reloaded.Int(Spec{Name: "hz"}, 10, 1, 500)
+	reloaded.Bool(Spec{Name: "unknown-directive"}, false)
+	require.NoError(t, reloaded.Load(path))
+	assert.Equal(t, "p w", pass.Get())
+}
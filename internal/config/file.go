@@ -0,0 +1,186 @@
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Shaso41/Backend-SystemFocus/internal/protocol"
+)
+
+// ErrNoFile is returned by Rewrite when no configuration file was loaded
+var ErrNoFile = errors.New("The server is running without a config file")
+
+// rewriteMarker precedes parameters appended to the file by Rewrite
+const rewriteMarker = "# Generated by CONFIG REWRITE"
+
+// Load reads parameters from a redis.conf-style file and remembers it for
+// Rewrite. Each line holds a parameter name followed by its value, with
+// the quoting rules of inline commands; blank lines and lines starting
+// with # are ignored. Parameters holding lists take several words.
+func (c *Config) Load(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		args, err := directive(scanner.Text())
+		if err != nil {
+			return fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		c.mu.Lock()
+		s, ok := c.settings[strings.ToLower(args[0])]
+		c.mu.Unlock()
+		if !ok || len(args) < 2 || (len(args) > 2 && !s.info().multiWord) {
+			return fmt.Errorf("%s:%d: Bad directive or wrong number of arguments", path, n)
+		}
+
+		if err := c.Set(args[0], strings.Join(args[1:], " ")); err != nil {
+			return fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.file = path
+	c.mu.Unlock()
+	return nil
+}
+
+// File returns the path of the loaded configuration file, if any
+func (c *Config) File() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.file
+}
+
+// Rewrite writes the current configuration back to the loaded file.
+// Comments, blank lines and unknown directives are kept; known parameters
+// are updated in place, and those changed from their defaults but absent
+// from the file are appended at the end.
+func (c *Config) Rewrite() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.file == "" {
+		return ErrNoFile
+	}
+
+	content, err := os.ReadFile(c.file)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	var lines []string
+	seen := make(map[string]bool)
+	hasMarker := false
+	if len(content) > 0 {
+		for _, line := range strings.Split(strings.TrimSuffix(string(content), "\n"), "\n") {
+			if strings.TrimSpace(line) == rewriteMarker {
+				hasMarker = true
+			}
+
+			args, err := directive(line)
+			if err != nil || len(args) == 0 {
+				lines = append(lines, line)
+				continue
+			}
+			name := strings.ToLower(args[0])
+			s, ok := c.settings[name]
+			if !ok {
+				lines = append(lines, line)
+				continue
+			}
+			if seen[name] {
+				// Only the first occurrence of a parameter is kept
+				continue
+			}
+			seen[name] = true
+			lines = append(lines, formatLine(s))
+		}
+	}
+
+	for _, name := range c.order {
+		s := c.settings[name]
+		if seen[name] || s.String() == s.info().def {
+			continue
+		}
+		if !hasMarker {
+			lines = append(lines, rewriteMarker)
+			hasMarker = true
+		}
+		lines = append(lines, formatLine(s))
+	}
+
+	return writeFile(c.file, strings.Join(lines, "\n")+"\n")
+}
+
+// directive splits a configuration line into words, returning none for
+// blank lines and comments
+func directive(line string) ([]string, error) {
+	line = strings.TrimSpace(line)
+	if line == "" || line[0] == '#' {
+		return nil, nil
+	}
+	args, err := protocol.SplitArgs(line)
+	if err != nil {
+		return nil, errors.New("Unbalanced quotes in configuration line")
+	}
+	return args, nil
+}
+
+// formatLine renders a parameter as a configuration file line
+func formatLine(s setting) string {
+	words := []string{s.String()}
+	if s.info().multiWord {
+		words = strings.Fields(s.String())
+	}
+
+	parts := []string{s.info().name}
+	for _, w := range words {
+		parts = append(parts, protocol.QuoteArg(w))
+	}
+	if len(parts) == 1 {
+		parts = append(parts, `""`)
+	}
+	return strings.Join(parts, " ")
+}
+
+// writeFile replaces path with content atomically, keeping its permissions
+func writeFile(path, content string) error {
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".config-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
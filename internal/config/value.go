@@ -0,0 +1,217 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// param holds what every parameter has regardless of its type
+type param struct {
+	name      string
+	doc       string
+	mutable   bool
+	multiWord bool
+	def       string
+	hooks     []func() error
+}
+
+func (p *param) info() *param {
+	return p
+}
+
+// Value is a typed parameter. Get is safe to call from any goroutine and
+// does not lock, so hot paths can read parameters on every use and see
+// changes immediately.
+type Value[T any] struct {
+	param
+	v      atomic.Pointer[T]
+	parse  func(string) (T, error)
+	format func(T) string
+}
+
+// Get returns the current value
+func (v *Value[T]) Get() T {
+	return *v.v.Load()
+}
+
+// String returns the current value as CONFIG GET reports it
+func (v *Value[T]) String() string {
+	return v.format(v.Get())
+}
+
+// OnChange registers fn to be called with the new value whenever the
+// parameter is set. An error rejects the change and restores the previous
+// value, for which fn is called again.
+func (v *Value[T]) OnChange(fn func(T) error) {
+	v.hooks = append(v.hooks, func() error {
+		return fn(v.Get())
+	})
+}
+
+func (v *Value[T]) set(s string) error {
+	x, err := v.parse(s)
+	if err != nil {
+		return err
+	}
+	v.v.Store(&x)
+	return nil
+}
+
+func (v *Value[T]) runHooks() error {
+	for _, hook := range v.hooks {
+		if err := hook(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Custom registers a parameter of any type, read from and shown as text
+// by parse and format. The typed helpers such as Int and Bool cover most
+// parameters.
+func Custom[T any](c *Config, spec Spec, def T, parse func(string) (T, error), format func(T) string) *Value[T] {
+	v := &Value[T]{
+		param: param{
+			name:      strings.ToLower(spec.Name),
+			doc:       spec.Doc,
+			mutable:   spec.Mutable,
+			multiWord: spec.MultiWord,
+			def:       format(def),
+		},
+		parse:  parse,
+		format: format,
+	}
+	v.v.Store(&def)
+	c.register(v)
+	return v
+}
+
+// Int registers an integer parameter limited to [min, max]
+func (c *Config) Int(spec Spec, def, min, max int64) *Value[int64] {
+	return Custom(c, spec, def, func(s string) (int64, error) {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("argument couldn't be parsed into an integer")
+		}
+		return checkRange(n, min, max)
+	}, formatInt)
+}
+
+// Memory registers a size in bytes limited to [min, max]. Values may use
+// the units of redis.conf: k, kb, m, mb, g and gb.
+func (c *Config) Memory(spec Spec, def, min, max int64) *Value[int64] {
+	return Custom(c, spec, def, func(s string) (int64, error) {
+		n, err := ParseMemory(s)
+		if err != nil {
+			return 0, err
+		}
+		return checkRange(n, min, max)
+	}, formatInt)
+}
+
+// Octal registers file permissions written in octal, such as 770
+func (c *Config) Octal(spec Spec, def int64) *Value[int64] {
+	return Custom(c, spec, def, func(s string) (int64, error) {
+		n, err := strconv.ParseInt(s, 8, 64)
+		if err != nil || n < 0 || n > 0o777 {
+			return 0, fmt.Errorf("argument must be an octal number between 0 and 777")
+		}
+		return n, nil
+	}, func(n int64) string {
+		return strconv.FormatInt(n, 8)
+	})
+}
+
+// Bool registers a yes/no parameter
+func (c *Config) Bool(spec Spec, def bool) *Value[bool] {
+	return Custom(c, spec, def, func(s string) (bool, error) {
+		switch strings.ToLower(s) {
+		case "yes":
+			return true, nil
+		case "no":
+			return false, nil
+		default:
+			return false, fmt.Errorf("argument must be 'yes' or 'no'")
+		}
+	}, func(b bool) string {
+		if b {
+			return "yes"
+		}
+		return "no"
+	})
+}
+
+// String registers a free-form string parameter
+func (c *Config) String(spec Spec, def string) *Value[string] {
+	return Custom(c, spec, def, func(s string) (string, error) {
+		return s, nil
+	}, func(s string) string {
+		return s
+	})
+}
+
+// Enum registers a string parameter restricted to values, compared
+// case-insensitively
+func (c *Config) Enum(spec Spec, def string, values ...string) *Value[string] {
+	return Custom(c, spec, def, func(s string) (string, error) {
+		for _, v := range values {
+			if strings.EqualFold(s, v) {
+				return v, nil
+			}
+		}
+		return "", fmt.Errorf("argument(s) must be one of the following: %s", strings.Join(values, ", "))
+	}, func(s string) string {
+		return s
+	})
+}
+
+// List registers a parameter holding several space-separated words, such
+// as listen addresses
+func (c *Config) List(spec Spec, def []string) *Value[[]string] {
+	spec.MultiWord = true
+	return Custom(c, spec, def, func(s string) ([]string, error) {
+		return strings.Fields(s), nil
+	}, func(words []string) string {
+		return strings.Join(words, " ")
+	})
+}
+
+// ParseMemory parses a size in bytes with an optional unit: k, m and g
+// are powers of 1000, kb, mb and gb powers of 1024
+func ParseMemory(s string) (int64, error) {
+	units := []struct {
+		suffix     string
+		multiplier int64
+	}{
+		{"kb", 1 << 10}, {"mb", 1 << 20}, {"gb", 1 << 30},
+		{"k", 1000}, {"m", 1000 * 1000}, {"g", 1000 * 1000 * 1000},
+	}
+
+	lower := strings.ToLower(s)
+	multiplier := int64(1)
+	for _, u := range units {
+		if strings.HasSuffix(lower, u.suffix) {
+			lower, multiplier = strings.TrimSuffix(lower, u.suffix), u.multiplier
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(lower, 10, 64)
+	if err != nil || n < 0 || n > (1<<63-1)/multiplier {
+		return 0, fmt.Errorf("argument must be a memory value")
+	}
+	return n * multiplier, nil
+}
+
+func checkRange(n, min, max int64) (int64, error) {
+	if n < min || n > max {
+		return 0, fmt.Errorf("argument must be between %d and %d inclusive", min, max)
+	}
+	return n, nil
+}
+
+func formatInt(n int64) string {
+	return strconv.FormatInt(n, 10)
+}
@@ -0,0 +1,348 @@
+// Package dict implements a hash table that resizes incrementally in the
+// manner of Redis' dict: a table that needs to grow or shrink gets a
+// second table of the new size, and every later write moves a bucket or
+// so across, so no single insert pays for copying the whole table the way
+// it does when a built-in map grows. Scan walks the table with reverse
+// binary cursors, which return every entry present for the whole scan at
+// least once even when the table is resized between calls.
+package dict
+
+import (
+	"hash/maphash"
+	"math/bits"
+)
+
+const (
+	// initialSize is the number of buckets of a new table, and the fewest
+	// a table shrinks to
+	initialSize = 4
+
+	// shrinkRatio is how many times more buckets than entries a table may
+	// have before it shrinks
+	shrinkRatio = 8
+
+	// emptyVisits is how many empty buckets a rehash step may skip for
+	// each bucket it was asked to move
+	emptyVisits = 10
+
+	// Buckets are allocated chunkSize at a time, when first written, so
+	// that starting a resize costs no more than the chunk index
+	chunkBits = 10
+	chunkSize = 1 << chunkBits
+)
+
+// entry is one key and its value, chained to the next in its bucket
+type entry[V any] struct {
+	key   string
+	value V
+	hash  uint64
+	next  *entry[V]
+}
+
+// table is an array of size buckets, size being zero or a power of two
+type table[V any] struct {
+	// chunks hold the buckets; a nil chunk has only empty buckets
+	chunks [][]*entry[V]
+	size   int
+	used   int
+}
+
+// newTable returns a table of size buckets, none of them allocated yet
+func newTable[V any](size int) table[V] {
+	return table[V]{chunks: make([][]*entry[V], (size+chunkSize-1)/chunkSize), size: size}
+}
+
+// mask returns the bits of a hash that select a bucket
+func (t *table[V]) mask() uint64 {
+	return uint64(t.size - 1)
+}
+
+// bucket returns the first entry of bucket i
+func (t *table[V]) bucket(i uint64) *entry[V] {
+	if chunk := t.chunks[i>>chunkBits]; chunk != nil {
+		return chunk[i&(chunkSize-1)]
+	}
+	return nil
+}
+
+// link returns where bucket i's first entry is stored, allocating its
+// chunk if needed
+func (t *table[V]) link(i uint64) **entry[V] {
+	chunk := &t.chunks[i>>chunkBits]
+	if *chunk == nil {
+		*chunk = make([]*entry[V], min(t.size, chunkSize))
+	}
+	return &(*chunk)[i&(chunkSize-1)]
+}
+
+// Dict maps strings to values of type V. Writes move buckets from the old
+// table to the new one while it resizes; Get, Scan and Range do not, so
+// any number of goroutines may read a Dict at once as long as none
+// writes. A Dict must otherwise not be used concurrently.
+type Dict[V any] struct {
+	// tables[0] holds every entry unless rehashing; entries then move to
+	// tables[1], which replaces it once the move is done
+	tables [2]table[V]
+
+	// rehashIdx is the next bucket of tables[0] to move, or -1 when not
+	// rehashing
+	rehashIdx int
+
+	seed maphash.Seed
+}
+
+// New returns an empty dict
+func New[V any]() *Dict[V] {
+	return &Dict[V]{rehashIdx: -1, seed: maphash.MakeSeed()}
+}
+
+// Len returns the number of entries
+func (d *Dict[V]) Len() int {
+	return d.tables[0].used + d.tables[1].used
+}
+
+// Buckets returns the number of buckets of the table entries are added to
+func (d *Dict[V]) Buckets() int {
+	if d.Rehashing() {
+		return d.tables[1].size
+	}
+	return d.tables[0].size
+}
+
+// Rehashing reports whether entries are being moved to a resized table
+func (d *Dict[V]) Rehashing() bool {
+	return d.rehashIdx >= 0
+}
+
+// Get returns the value stored under key
+func (d *Dict[V]) Get(key string) (V, bool) {
+	if e := d.find(key, maphash.String(d.seed, key)); e != nil {
+		return e.value, true
+	}
+	var zero V
+	return zero, false
+}
+
+// find returns the entry for key, whose hash is h, or nil
+func (d *Dict[V]) find(key string, h uint64) *entry[V] {
+	if d.Len() == 0 {
+		return nil
+	}
+	for i := range d.tables {
+		t := &d.tables[i]
+		for e := t.bucket(h & t.mask()); e != nil; e = e.next {
+			if e.hash == h && e.key == key {
+				return e
+			}
+		}
+		if !d.Rehashing() {
+			break
+		}
+	}
+	return nil
+}
+
+// Set stores value under key, and reports whether the key was added
+// rather than replaced
+func (d *Dict[V]) Set(key string, value V) bool {
+	d.Rehash(1)
+	h := maphash.String(d.seed, key)
+	if e := d.find(key, h); e != nil {
+		e.value = value
+		return false
+	}
+
+	d.expandIfNeeded()
+	t := &d.tables[0]
+	if d.Rehashing() {
+		t = &d.tables[1]
+	}
+	link := t.link(h & t.mask())
+	*link = &entry[V]{key: key, value: value, hash: h, next: *link}
+	t.used++
+	return true
+}
+
+// Delete removes key, returning the value it held
+func (d *Dict[V]) Delete(key string) (V, bool) {
+	var zero V
+	if d.Len() == 0 {
+		return zero, false
+	}
+	d.Rehash(1)
+
+	h := maphash.String(d.seed, key)
+	for i := range d.tables {
+		t := &d.tables[i]
+		if t.bucket(h&t.mask()) != nil {
+			for link := t.link(h & t.mask()); *link != nil; link = &(*link).next {
+				if e := *link; e.hash == h && e.key == key {
+					*link = e.next
+					t.used--
+					d.shrinkIfNeeded()
+					return e.value, true
+				}
+			}
+		}
+		if !d.Rehashing() {
+			break
+		}
+	}
+	return zero, false
+}
+
+// Clear removes every entry and releases the tables
+func (d *Dict[V]) Clear() {
+	d.tables = [2]table[V]{}
+	d.rehashIdx = -1
+}
+
+// Range calls fn for every entry until fn returns false. fn must not
+// modify the dict.
+func (d *Dict[V]) Range(fn func(key string, value V) bool) {
+	for i := range d.tables {
+		for _, chunk := range d.tables[i].chunks {
+			for _, e := range chunk {
+				for ; e != nil; e = e.next {
+					if !fn(e.key, e.value) {
+						return
+					}
+				}
+			}
+		}
+	}
+}
+
+// Scan calls fn for the entries of the buckets cursor selects, and returns
+// the cursor to pass next, or 0 once every bucket was visited. A scan
+// starts from cursor 0.
+//
+// The cursor counts with its bits reversed, so that it visits buckets in
+// an order that stays valid when the table doubles or halves between
+// calls: every entry present from the first call to the last is visited
+// at least once, though some may be visited twice. fn must not modify the
+// dict.
+func (d *Dict[V]) Scan(cursor uint64, fn func(key string, value V)) uint64 {
+	if d.Len() == 0 {
+		return 0
+	}
+
+	if !d.Rehashing() {
+		t := &d.tables[0]
+		scanBucket(t.bucket(cursor&t.mask()), fn)
+		return nextCursor(cursor, t.mask())
+	}
+
+	// Visit the bucket of the smaller table, then every bucket of the
+	// larger one that its entries expand to
+	small, large := &d.tables[0], &d.tables[1]
+	if small.size > large.size {
+		small, large = large, small
+	}
+	m0, m1 := small.mask(), large.mask()
+	scanBucket(small.bucket(cursor&m0), fn)
+	for {
+		scanBucket(large.bucket(cursor&m1), fn)
+		cursor = nextCursor(cursor, m1)
+		if cursor&(m0^m1) == 0 {
+			return cursor
+		}
+	}
+}
+
+// scanBucket calls fn for every entry of a bucket
+func scanBucket[V any](e *entry[V], fn func(key string, value V)) {
+	for ; e != nil; e = e.next {
+		fn(e.key, e.value)
+	}
+}
+
+// nextCursor increments the bits of cursor under mask, starting from the
+// highest
+func nextCursor(cursor, mask uint64) uint64 {
+	cursor |= ^mask
+	cursor = bits.Reverse64(cursor)
+	cursor++
+	return bits.Reverse64(cursor)
+}
+
+// Rehash moves up to n buckets to the resized table, and reports whether
+// any are left to move
+func (d *Dict[V]) Rehash(n int) bool {
+	if !d.Rehashing() {
+		return false
+	}
+
+	src, dst := &d.tables[0], &d.tables[1]
+	empty := n * emptyVisits
+	for ; n > 0 && src.used > 0; n-- {
+		for src.bucket(uint64(d.rehashIdx)) == nil {
+			d.rehashIdx++
+			if empty--; empty == 0 {
+				return true
+			}
+		}
+		link := src.link(uint64(d.rehashIdx))
+		for e := *link; e != nil; {
+			next := e.next
+			dstLink := dst.link(e.hash & dst.mask())
+			e.next = *dstLink
+			*dstLink = e
+			src.used--
+			dst.used++
+			e = next
+		}
+		*link = nil
+		d.rehashIdx++
+	}
+
+	if src.used > 0 {
+		return true
+	}
+	d.tables[0], d.tables[1] = d.tables[1], table[V]{}
+	d.rehashIdx = -1
+
+	// Entries deleted during the move may leave the new table too large
+	d.shrinkIfNeeded()
+	return d.Rehashing()
+}
+
+// expandIfNeeded starts doubling the table once it holds as many entries
+// as buckets
+func (d *Dict[V]) expandIfNeeded() {
+	if d.Rehashing() {
+		return
+	}
+	if t := &d.tables[0]; t.size == 0 || t.used >= t.size {
+		d.resize(t.used + 1)
+	}
+}
+
+// shrinkIfNeeded starts shrinking the table once it is less than
+// 1/shrinkRatio full
+func (d *Dict[V]) shrinkIfNeeded() {
+	if d.Rehashing() {
+		return
+	}
+	if t := &d.tables[0]; t.size > initialSize && t.used*shrinkRatio < t.size {
+		d.resize(t.used)
+	}
+}
+
+// resize sets up a table with room for n entries, the smallest power of
+// two from initialSize up, and starts moving entries to it. Only the
+// table's chunk index is allocated here; the buckets follow a chunk at a
+// time as entries move in. An empty dict takes the table right away.
+func (d *Dict[V]) resize(n int) {
+	size := max(initialSize, 1<<bits.Len(uint(n-1)))
+	if size == d.tables[0].size {
+		return
+	}
+	t := newTable[V](size)
+	if d.tables[0].used == 0 {
+		d.tables[0] = t
+		return
+	}
+	d.tables[1] = t
+	d.rehashIdx = 0
+}
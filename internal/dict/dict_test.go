@@ -0,0 +1,227 @@
+package dict
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Shaso41/Backend-SystemFocus/internal/histogram"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDict(t *testing.T) {
+	d := New[int]()
+	_, ok := d.Get("missing")
+	assert.False(t, ok)
+	_, ok = d.Delete("missing")
+	assert.False(t, ok)
+
+	assert.True(t, d.Set("a", 1))
+	assert.False(t, d.Set("a", 2))
+	v, ok := d.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 2, v)
+	assert.Equal(t, 1, d.Len())
+
+	v, ok = d.Delete("a")
+	assert.True(t, ok)
+	assert.Equal(t, 2, v)
+	assert.Equal(t, 0, d.Len())
+
+	d.Set("b", 1)
+	d.Clear()
+	assert.Equal(t, 0, d.Len())
+	_, ok = d.Get("b")
+	assert.False(t, ok)
+}
+
+func TestDict_Resize(t *testing.T) {
+	d := New[int]()
+	const n = 10000
+
+	// Growing moves entries a bucket or so per write, while every entry
+	// stays reachable
+	sawRehashing := false
+	for i := 0; i < n; i++ {
+		d.Set(strconv.Itoa(i), i)
+		sawRehashing = sawRehashing || d.Rehashing()
+		if i%997 == 0 {
+			for j := 0; j <= i; j++ {
+				v, ok := d.Get(strconv.Itoa(j))
+				require.True(t, ok, j)
+				require.Equal(t, j, v)
+			}
+		}
+	}
+	assert.True(t, sawRehashing)
+	assert.Equal(t, n, d.Len())
+	for d.Rehash(100) {
+	}
+	assert.Equal(t, 16384, d.Buckets())
+
+	// Deleting most entries shrinks the table
+	for i := 0; i < n-10; i++ {
+		_, ok := d.Delete(strconv.Itoa(i))
+		require.True(t, ok, i)
+	}
+	for d.Rehash(100) {
+	}
+	assert.LessOrEqual(t, d.Buckets(), 10*shrinkRatio)
+	for i := n - 10; i < n; i++ {
+		v, ok := d.Get(strconv.Itoa(i))
+		require.True(t, ok, i)
+		assert.Equal(t, i, v)
+	}
+}
+
+func TestDict_ResizeAllocatesChunks(t *testing.T) {
+	d := New[int]()
+	for i := 0; i < 4*chunkSize; i++ {
+		d.Set(strconv.Itoa(i), i)
+	}
+	for d.Rehash(100) {
+	}
+
+	// The insert that starts growing the table allocates none of the new
+	// buckets beyond those it writes to
+	for i := 0; !d.Rehashing(); i++ {
+		d.Set("new"+strconv.Itoa(i), i)
+	}
+	allocated := 0
+	for _, chunk := range d.tables[1].chunks {
+		if chunk != nil {
+			allocated++
+		}
+	}
+	assert.Len(t, d.tables[1].chunks, 8)
+	assert.Equal(t, 1, allocated)
+
+	for d.Rehash(100) {
+	}
+	for _, chunk := range d.tables[0].chunks {
+		assert.Len(t, chunk, chunkSize)
+	}
+}
+
+func TestDict_Range(t *testing.T) {
+	d := New[int]()
+	for i := 0; i < 100; i++ {
+		d.Set(strconv.Itoa(i), i)
+	}
+
+	// Entries of both tables are visited while rehashing
+	require.True(t, d.Rehashing())
+	seen := make(map[string]int)
+	d.Range(func(key string, value int) bool {
+		seen[key] = value
+		return true
+	})
+	assert.Len(t, seen, 100)
+
+	calls := 0
+	d.Range(func(string, int) bool {
+		calls++
+		return calls < 10
+	})
+	assert.Equal(t, 10, calls)
+}
+
+// scanAll scans d from cursor 0, calling between after every step, and
+// returns how many times each key was visited
+func scanAll(d *Dict[int], between func()) map[string]int {
+	seen := make(map[string]int)
+	cursor := uint64(0)
+	for {
+		cursor = d.Scan(cursor, func(key string, _ int) { seen[key]++ })
+		if cursor == 0 {
+			return seen
+		}
+		between()
+	}
+}
+
+func TestDict_Scan(t *testing.T) {
+	d := New[int]()
+	assert.Equal(t, uint64(0), d.Scan(0, func(string, int) { t.Fatal("empty dict") }))
+
+	for i := 0; i < 1000; i++ {
+		d.Set(strconv.Itoa(i), i)
+	}
+	for d.Rehash(100) {
+	}
+	seen := scanAll(d, func() {})
+	assert.Len(t, seen, 1000)
+	for key, n := range seen {
+		assert.Equal(t, 1, n, key)
+	}
+
+	// Keys present throughout are returned even though the table doubles
+	// twice during the scan
+	added := 0
+	seen = scanAll(d, func() {
+		for i := 0; i < 20 && added < 3000; i++ {
+			d.Set("new"+strconv.Itoa(added), 0)
+			added++
+		}
+	})
+	assert.Greater(t, d.Buckets(), 2048)
+	for i := 0; i < 1000; i++ {
+		assert.Positive(t, seen[strconv.Itoa(i)], i)
+	}
+
+	// And while it shrinks
+	for i := 0; i < added; i++ {
+		d.Delete("new" + strconv.Itoa(i))
+	}
+	deleted := 100
+	seen = scanAll(d, func() {
+		for i := 0; i < 20 && deleted < 1000; i++ {
+			d.Delete(strconv.Itoa(deleted))
+			deleted++
+		}
+	})
+	assert.Less(t, d.Buckets(), 1024)
+	for i := 0; i < 100; i++ {
+		assert.Positive(t, seen[strconv.Itoa(i)], i)
+	}
+}
+
+// BenchmarkGrowth adds b.N keys to an empty dict and to an empty built-in
+// map, timing every insert. Maps copy their entries when they grow, which
+// the dict spreads over later inserts, so p99.99 and the slowest inserts
+// tell them apart rather than the average. Try it with
+// -benchtime=4000000x.
+func BenchmarkGrowth(b *testing.B) {
+	measure := func(b *testing.B, set func(key string)) {
+		keys := make([]string, b.N)
+		for i := range keys {
+			keys[i] = "key:" + strconv.Itoa(i)
+		}
+		latencies := histogram.New()
+		var slowest time.Duration
+		b.ResetTimer()
+		for _, key := range keys {
+			start := time.Now()
+			set(key)
+			elapsed := time.Since(start)
+			latencies.Record(int64(elapsed))
+			slowest = max(slowest, elapsed)
+		}
+		b.StopTimer()
+
+		snapshot := latencies.Snapshot()
+		b.ReportMetric(float64(snapshot.Percentile(99)), "p99-ns")
+		b.ReportMetric(float64(snapshot.Percentile(99.99)), "p99.99-ns")
+		b.ReportMetric(float64(slowest.Nanoseconds()), "max-ns")
+	}
+
+	b.Run("dict", func(b *testing.B) {
+		d := New[int]()
+		measure(b, func(key string) { d.Set(key, 0) })
+	})
+	b.Run("map", func(b *testing.B) {
+		m := make(map[string]int)
+		measure(b, func(key string) { m[key] = 0 })
+	})
+}
@@ -0,0 +1,139 @@
+// Package glob implements the glob-style pattern matching Redis uses for
+// key patterns, ACL rules and configuration parameter names.
+package glob
+
+// Match reports whether s matches pattern. In the pattern, * matches any
+// sequence of characters including none, ? any single character, and [abc]
+// one of the listed characters; classes may contain ranges such as [a-z]
+// and are negated by a leading ^. A backslash matches the next character
+// literally.
+func Match(pattern, s string) bool {
+	return match(pattern, s, false)
+}
+
+// MatchFold is like Match but compares letters case-insensitively
+func MatchFold(pattern, s string) bool {
+	return match(pattern, s, true)
+}
+
+func match(pattern, s string, fold bool) bool {
+	// Position to resume from when a later part fails to match after a *
+	star, next := -1, 0
+
+	p, i := 0, 0
+	for i < len(s) {
+		if p < len(pattern) {
+			switch pattern[p] {
+			case '*':
+				for p < len(pattern) && pattern[p] == '*' {
+					p++
+				}
+				if p == len(pattern) {
+					return true
+				}
+				star, next = p, i
+				continue
+			case '?':
+				p++
+				i++
+				continue
+			case '[':
+				if end, ok := matchClass(pattern, p, s[i], fold); ok {
+					p = end
+					i++
+					continue
+				}
+			default:
+				c := pattern[p]
+				end := p + 1
+				if c == '\\' && p+1 < len(pattern) {
+					c, end = pattern[p+1], p+2
+				}
+				if equal(c, s[i], fold) {
+					p = end
+					i++
+					continue
+				}
+			}
+		}
+
+		// Mismatch: let the last * swallow one more character
+		if star < 0 {
+			return false
+		}
+		next++
+		p, i = star, next
+	}
+
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// matchClass matches c against the character class starting at
+// pattern[start], which is a '['. It returns the index just past the class
+// and whether c is in it. An unterminated class extends to the end of the
+// pattern.
+func matchClass(pattern string, start int, c byte, fold bool) (int, bool) {
+	p := start + 1
+	negate := p < len(pattern) && pattern[p] == '^'
+	if negate {
+		p++
+	}
+
+	found := false
+	for p < len(pattern) && pattern[p] != ']' {
+		switch {
+		case pattern[p] == '\\' && p+1 < len(pattern):
+			p++
+			if equal(pattern[p], c, fold) {
+				found = true
+			}
+		case p+2 < len(pattern) && pattern[p+1] == '-' && pattern[p+2] != ']':
+			lo, hi := pattern[p], pattern[p+2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			if inRange(c, lo, hi) || (fold && (inRange(lower(c), lo, hi) || inRange(upper(c), lo, hi))) {
+				found = true
+			}
+			p += 2
+		default:
+			if equal(pattern[p], c, fold) {
+				found = true
+			}
+		}
+		p++
+	}
+	if p < len(pattern) {
+		p++ // closing ]
+	}
+
+	return p, found != negate
+}
+
+func equal(a, b byte, fold bool) bool {
+	if fold {
+		return lower(a) == lower(b)
+	}
+	return a == b
+}
+
+func inRange(c, lo, hi byte) bool {
+	return lo <= c && c <= hi
+}
+
+func lower(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+func upper(c byte) byte {
+	if 'a' <= c && c <= 'z' {
+		return c - ('a' - 'A')
+	}
+	return c
+}
@@ -0,0 +1,46 @@
+package glob
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"*", "", true},
+		{"*", "anything", true},
+		{"cache:*", "cache:user:1", true},
+		{"cache:*", "session:1", false},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h*llo", "heeeello", true},
+		{"h*llo", "hello world", false},
+		{"*:*:*", "a:b:c", true},
+		{"*:*:*", "a:b", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-b]llo", "hbllo", true},
+		{"h[a-b]llo", "hcllo", false},
+		{`h\*llo`, "h*llo", true},
+		{`h\*llo`, "hello", false},
+		{"max*-size", "maxmemory-size", true},
+		{"", "", true},
+		{"", "a", false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Match(tt.pattern, tt.s), "%q %q", tt.pattern, tt.s)
+	}
+}
+
+func TestMatchFold(t *testing.T) {
+	assert.True(t, MatchFold("MAXMEMORY*", "maxmemory-policy"))
+	assert.True(t, MatchFold("[A-C]x", "bx"))
+	assert.False(t, Match("MAXMEMORY*", "maxmemory-policy"))
+}
@@ -0,0 +1,131 @@
+// Package histogram records latencies in a log-linear histogram in the
+// manner of HdrHistogram: every value is kept to two significant digits
+// whatever its magnitude, and recording is a single atomic add, so any
+// number of goroutines may record into a histogram at once.
+package histogram
+
+import (
+	"math/bits"
+	"sync/atomic"
+)
+
+const (
+	// Values below subCount have a bucket each. Above, every power of two
+	// is split into subCount/2 buckets.
+	subBits  = 8
+	subCount = 1 << subBits
+	subHalf  = subCount / 2
+
+	// Max is the largest value told apart; larger values count as Max
+	Max = 1<<32 - 1
+
+	numBuckets = subCount + (32-subBits)*subHalf
+)
+
+// Histogram counts recorded values, such as latencies in nanoseconds
+type Histogram struct {
+	counts [numBuckets]atomic.Int64
+}
+
+// New returns an empty histogram
+func New() *Histogram {
+	return &Histogram{}
+}
+
+// Record adds a value. Negative values count as 0.
+func (h *Histogram) Record(v int64) {
+	h.counts[index(v)].Add(1)
+}
+
+// Snapshot returns the counts recorded so far
+func (h *Histogram) Snapshot() *Snapshot {
+	s := &Snapshot{}
+	for i := range h.counts {
+		n := h.counts[i].Load()
+		s.counts[i] = n
+		s.total += n
+	}
+	return s
+}
+
+// Snapshot is a copy of a histogram's counts at one point in time
+type Snapshot struct {
+	counts [numBuckets]int64
+	total  int64
+}
+
+// Count returns the number of values recorded
+func (s *Snapshot) Count() int64 {
+	return s.total
+}
+
+// Percentile returns the value at or below which p percent of the values
+// lie, or 0 if there are none
+func (s *Snapshot) Percentile(p float64) int64 {
+	if s.total == 0 {
+		return 0
+	}
+	target := int64(p / 100 * float64(s.total))
+	if float64(target) < p/100*float64(s.total) {
+		target++
+	}
+	target = max(target, 1)
+
+	var seen int64
+	for i, n := range s.counts {
+		seen += n
+		if seen >= target {
+			return highest(i)
+		}
+	}
+	return Max
+}
+
+// Log2 calls fn with first and its successive doublings, and the number
+// of values below each, stopping at the first bound all values are below.
+// The counts are exact when first has at most subBits significant bits,
+// such as a power of two or 1000.
+func (s *Snapshot) Log2(first int64, fn func(bound, below int64)) {
+	var below int64
+	i := 0
+	for bound := max(first, 1); ; bound *= 2 {
+		// Buckets never straddle a value of subBits significant bits, so
+		// counting the buckets starting below such a bound is exact
+		for ; i < numBuckets && lowest(i) < bound; i++ {
+			below += s.counts[i]
+		}
+		fn(bound, below)
+		if below == s.total {
+			return
+		}
+	}
+}
+
+// index returns the bucket counting v
+func index(v int64) int {
+	v = min(max(v, 0), Max)
+	if v < subCount {
+		return int(v)
+	}
+	shift := bits.Len64(uint64(v)) - subBits
+	return subCount + (shift-1)*subHalf + int(v>>shift) - subHalf
+}
+
+// lowest returns the smallest value counted by bucket i
+func lowest(i int) int64 {
+	if i < subCount {
+		return int64(i)
+	}
+	shift := (i-subCount)/subHalf + 1
+	sub := (i-subCount)%subHalf + subHalf
+	return int64(sub) << shift
+}
+
+// highest returns the largest value counted by bucket i
+func highest(i int) int64 {
+	if i < subCount {
+		return int64(i)
+	}
+	shift := (i-subCount)/subHalf + 1
+	return lowest(i) + 1<<shift - 1
+}
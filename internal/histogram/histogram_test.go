@@ -0,0 +1,98 @@
+package histogram
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuckets(t *testing.T) {
+	// Every value falls in a bucket whose bounds contain it, within 1%
+	for _, v := range []int64{0, 1, 255, 256, 257, 1000, 1023, 1024, 123456, 1 << 30, Max} {
+		i := index(v)
+		assert.LessOrEqual(t, lowest(i), v, v)
+		assert.GreaterOrEqual(t, highest(i), v, v)
+		assert.LessOrEqual(t, float64(highest(i)-lowest(i)), float64(v)/100+1, v)
+	}
+	assert.Equal(t, numBuckets-1, index(Max))
+	assert.Equal(t, numBuckets-1, index(Max*4))
+	assert.Equal(t, 0, index(-5))
+
+	// Buckets are contiguous
+	for i := 1; i < numBuckets; i++ {
+		assert.Equal(t, highest(i-1)+1, lowest(i), i)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	h := New()
+	assert.Equal(t, int64(0), h.Snapshot().Percentile(50))
+
+	values := make([]int64, 10000)
+	for i := range values {
+		values[i] = rand.Int63n(10_000_000)
+		h.Record(values[i])
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+
+	s := h.Snapshot()
+	assert.Equal(t, int64(10000), s.Count())
+	for _, p := range []float64{50, 99, 99.9, 100} {
+		want := values[int(p/100*float64(len(values)))-1]
+		assert.InEpsilon(t, want, s.Percentile(p), 0.01, "p%v", p)
+	}
+}
+
+func TestLog2(t *testing.T) {
+	h := New()
+	for _, v := range []int64{500, 1500, 1500, 3000, 9000} {
+		h.Record(v)
+	}
+
+	var bounds, below []int64
+	h.Snapshot().Log2(1024, func(bound, n int64) {
+		bounds = append(bounds, bound)
+		below = append(below, n)
+	})
+	assert.Equal(t, []int64{1024, 2048, 4096, 8192, 16384}, bounds)
+	assert.Equal(t, []int64{1, 3, 4, 4, 5}, below)
+
+	// Bounds in microseconds are exact too
+	h = New()
+	for _, v := range []int64{999, 1000, 127999, 128000} {
+		h.Record(v)
+	}
+	bounds, below = nil, nil
+	h.Snapshot().Log2(1000, func(bound, n int64) {
+		bounds = append(bounds, bound)
+		below = append(below, n)
+	})
+	assert.Equal(t, []int64{1000, 2000, 4000, 8000, 16000, 32000, 64000, 128000, 256000}, bounds)
+	assert.Equal(t, []int64{1, 2, 2, 2, 2, 2, 2, 3, 4}, below)
+}
+
+func TestConcurrentRecord(t *testing.T) {
+	h := New()
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				h.Record(int64(i))
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int64(8000), h.Snapshot().Count())
+}
+
+func BenchmarkRecord(b *testing.B) {
+	h := New()
+	for i := 0; i < b.N; i++ {
+		h.Record(int64(i))
+	}
+}
@@ -0,0 +1,189 @@
+package protocol
+
+import (
+	"bufio"
+	"io"
+)
+
+// maxRetainedArena is the largest argument buffer kept between commands.
+// A connection that once sent a huge value does not hold on to that much
+// memory for the rest of its life.
+const maxRetainedArena = 64 * 1024
+
+// ReadCommand reads the next client request, either a RESP array of bulk
+// strings or an inline command, and returns its arguments. It is the
+// allocation-free counterpart of Parse for the request path: arguments
+// live in a buffer owned by the parser and the returned vector is reused,
+// so both are only valid until the next call. Callers that keep an
+// argument must copy it. Empty requests are skipped.
+func (p *Parser) ReadCommand() ([][]byte, error) {
+	for {
+		args, err := p.readCommand()
+		if err != nil || len(args) > 0 {
+			return args, err
+		}
+	}
+}
+
+// readCommand reads one request, returning no arguments for an empty one
+func (p *Parser) readCommand() ([][]byte, error) {
+	if cap(p.arena) > maxRetainedArena {
+		p.arena = nil
+	}
+	p.arena = p.arena[:0]
+	p.bounds = p.bounds[:0]
+
+	line, err := p.readHeader()
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, nil
+	}
+	if line[0] != Array {
+		return p.readInline(line)
+	}
+
+	count, ok := parseLength(line[1:])
+	if !ok || count < -1 || (p.limits.MaxArrayLen > 0 && count > p.limits.MaxArrayLen) {
+		return nil, protocolErrorf("invalid multibulk length")
+	}
+
+	for i := 0; i < count; i++ {
+		line, err := p.readHeader()
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 || line[0] != BulkString {
+			got := byte(' ')
+			if len(line) > 0 {
+				got = line[0]
+			}
+			return nil, protocolErrorf("expected '$', got '%c'", got)
+		}
+
+		length, ok := parseLength(line[1:])
+		if !ok || length < 0 || (p.limits.MaxBulkLen > 0 && length > p.limits.MaxBulkLen) {
+			return nil, protocolErrorf("invalid bulk length")
+		}
+
+		start := len(p.arena)
+		if err := p.readBulkInto(length + 2); err != nil { // +2 for \r\n
+			return nil, err
+		}
+		if p.arena[start+length] != '\r' || p.arena[start+length+1] != '\n' {
+			return nil, protocolErrorf("bulk string is not terminated by CRLF")
+		}
+		p.arena = p.arena[:start+length]
+		p.bounds = append(p.bounds, start, start+length)
+	}
+
+	return p.argv(), nil
+}
+
+// readInline splits an inline command into arguments stored in the arena
+func (p *Parser) readInline(line []byte) ([][]byte, error) {
+	var err error
+	p.arena, p.bounds, err = splitInline(p.arena, p.bounds, line)
+	if err != nil {
+		return nil, err
+	}
+	return p.argv(), nil
+}
+
+// argv builds the reusable argument vector from the recorded bounds. The
+// arena may have moved while growing, so slices are only taken at the end.
+func (p *Parser) argv() [][]byte {
+	p.args = p.args[:0]
+	for i := 0; i < len(p.bounds); i += 2 {
+		start, end := p.bounds[i], p.bounds[i+1]
+		p.args = append(p.args, p.arena[start:end:end])
+	}
+	return p.args
+}
+
+// readBulkInto appends n bytes from the reader to the arena. Lengths above
+// bulkChunkSize grow the arena a chunk at a time as data arrives.
+func (p *Parser) readBulkInto(n int) error {
+	for n > 0 {
+		chunk := min(n, bulkChunkSize)
+		start := len(p.arena)
+		if cap(p.arena)-start >= chunk {
+			p.arena = p.arena[:start+chunk]
+		} else {
+			p.arena = append(p.arena, make([]byte, chunk)...)
+		}
+		if _, err := io.ReadFull(p.reader, p.arena[start:]); err != nil {
+			return err
+		}
+		n -= chunk
+	}
+	return nil
+}
+
+// readHeader reads a line without its terminator. Lines that fit in the
+// reader's buffer are returned in place, without copying; the result is
+// only valid until the next read.
+func (p *Parser) readHeader() ([]byte, error) {
+	line, err := p.reader.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		// Longer than the read buffer; fall back to the accumulating path
+		p.line = append(p.line[:0], line...)
+		rest, err := p.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if p.limits.MaxInlineLen > 0 && len(p.line)+len(rest) > p.limits.MaxInlineLen {
+			return nil, protocolErrorf("too big inline request")
+		}
+		p.line = append(p.line, rest...)
+		return p.line, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p.limits.MaxInlineLen > 0 && len(line) > p.limits.MaxInlineLen+2 {
+		return nil, protocolErrorf("too big inline request")
+	}
+
+	line = line[:len(line)-1]
+	if len(line) > 0 && line[len(line)-1] == '\r' {
+		line = line[:len(line)-1]
+	}
+	return line, nil
+}
+
+// parseLength parses a decimal length without allocating. It accepts -1
+// and rejects anything that does not fit in an int32.
+func parseLength(b []byte) (int, bool) {
+	if len(b) == 0 {
+		return 0, false
+	}
+	neg := b[0] == '-'
+	if neg {
+		b = b[1:]
+		if len(b) == 0 {
+			return 0, false
+		}
+	}
+
+	n := 0
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		n = n*10 + int(c-'0')
+		if n > 1<<31-1 {
+			return 0, false
+		}
+	}
+	if neg {
+		return -n, true
+	}
+	return n, true
+}
+
+// isSpace reports whether c separates inline arguments
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\v' || c == '\f'
+}
@@ -0,0 +1,209 @@
+package protocol
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParser_ReadCommand(t *testing.T) {
+	parser := NewParser(bytes.NewBufferString("*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$5\r\nva\r\nl\r\n"))
+
+	args, err := parser.ReadCommand()
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("SET"), []byte("key"), []byte("va\r\nl")}, args)
+}
+
+func TestParser_ReadCommandInline(t *testing.T) {
+	parser := NewParser(bytes.NewBufferString("  SET  key\tvalue \r\n"))
+
+	args, err := parser.ReadCommand()
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("SET"), []byte("key"), []byte("value")}, args)
+}
+
+func TestParser_ReadCommandInlineQuoting(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{`SET greeting "hello world"`, []string{"SET", "greeting", "hello world"}},
+		{`SET k 'it''s'`, nil},
+		{`SET k 'it\'s'`, []string{"SET", "k", "it's"}},
+		{`SET k 'a\nb'`, []string{"SET", "k", `a\nb`}},
+		{`SET k "a\nb\t\"c\"\\"`, []string{"SET", "k", "a\nb\t\"c\"\\"}},
+		{`SET k "\x00\xff\xzz"`, []string{"SET", "k", "\x00\xffxzz"}},
+		{`SET k ""`, []string{"SET", "k", ""}},
+		{`SET k "abc`, nil},
+		{`SET k 'abc`, nil},
+		{`SET k "abc"def`, nil},
+	}
+
+	for _, tt := range tests {
+		parser := NewParser(bytes.NewBufferString(tt.line + "\r\n"))
+
+		args, err := parser.ReadCommand()
+		if tt.want == nil {
+			assert.IsType(t, &ProtocolError{}, err, tt.line)
+			assert.EqualError(t, err, "Protocol error: unbalanced quotes in request", tt.line)
+			continue
+		}
+		assert.NoError(t, err, tt.line)
+
+		want := make([][]byte, len(tt.want))
+		for i, arg := range tt.want {
+			want[i] = []byte(arg)
+		}
+		assert.Equal(t, want, args, tt.line)
+	}
+}
+
+func TestParser_ReadCommandSkipsEmptyRequests(t *testing.T) {
+	parser := NewParser(bytes.NewBufferString("\r\n*0\r\n   \r\n*1\r\n$4\r\nPING\r\n"))
+
+	args, err := parser.ReadCommand()
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("PING")}, args)
+}
+
+func TestParser_ReadCommandReusesBuffers(t *testing.T) {
+	parser := NewParser(bytes.NewBufferString("*1\r\n$4\r\nPING\r\n*1\r\n$4\r\nECHO\r\n"))
+
+	first, err := parser.ReadCommand()
+	assert.NoError(t, err)
+	kept := clone(first[0])
+
+	second, err := parser.ReadCommand()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("ECHO"), second[0])
+
+	// The first vector now aliases the second command's arguments
+	assert.Equal(t, []byte("ECHO"), first[0])
+	assert.Equal(t, []byte("PING"), kept)
+}
+
+func TestParser_ReadCommandRejectsNonBulkArguments(t *testing.T) {
+	parser := NewParser(bytes.NewBufferString("*2\r\n$3\r\nGET\r\n:1\r\n"))
+
+	_, err := parser.ReadCommand()
+	assert.IsType(t, &ProtocolError{}, err)
+	assert.Equal(t, "Protocol error: expected '$', got ':'", err.Error())
+}
+
+func TestParser_ReadCommandLimits(t *testing.T) {
+	parser := NewParser(bytes.NewBufferString("*1\r\n$2000000000\r\n"))
+	_, err := parser.ReadCommand()
+	assert.IsType(t, &ProtocolError{}, err)
+	assert.Contains(t, err.Error(), "invalid bulk length")
+
+	parser = NewParser(bytes.NewBufferString("*3\r\n"))
+	parser.SetLimits(Limits{MaxArrayLen: 2})
+	_, err = parser.ReadCommand()
+	assert.IsType(t, &ProtocolError{}, err)
+	assert.Contains(t, err.Error(), "invalid multibulk length")
+
+	parser = NewParser(strings.NewReader(strings.Repeat("x", 100000) + "\r\n"))
+	_, err = parser.ReadCommand()
+	assert.IsType(t, &ProtocolError{}, err)
+	assert.Contains(t, err.Error(), "too big inline request")
+}
+
+func TestParser_ReadCommandLargeValue(t *testing.T) {
+	value := bytes.Repeat([]byte("ab\r\n\x00"), 1000000)
+	input := append([]byte("*2\r\n$4\r\nECHO\r\n$5000000\r\n"), value...)
+	input = append(input, "\r\n*1\r\n$4\r\nPING\r\n"...)
+	parser := NewParser(bytes.NewReader(input))
+
+	args, err := parser.ReadCommand()
+	assert.NoError(t, err)
+	assert.True(t, bytes.Equal(value, args[1]))
+
+	// The large buffer is dropped rather than retained
+	args, err = parser.ReadCommand()
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("PING")}, args)
+	assert.LessOrEqual(t, cap(parser.arena), maxRetainedArena)
+}
+
+// repeatReader endlessly replays the same bytes without allocating
+type repeatReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *repeatReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		c := copy(p[n:], r.data[r.pos:])
+		n += c
+		r.pos = (r.pos + c) % len(r.data)
+	}
+	return n, nil
+}
+
+const (
+	benchGet = "*2\r\n$3\r\nGET\r\n$8\r\nuser:100\r\n"
+	benchSet = "*3\r\n$3\r\nSET\r\n$8\r\nuser:100\r\n$16\r\n0123456789abcdef\r\n"
+)
+
+func BenchmarkParser_ParseGet(b *testing.B) {
+	parser := NewParser(&repeatReader{data: []byte(benchGet)})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := parser.Parse(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParser_ReadCommandGet(b *testing.B) {
+	parser := NewParser(&repeatReader{data: []byte(benchGet)})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := parser.ReadCommand(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParser_ParseSet(b *testing.B) {
+	parser := NewParser(&repeatReader{data: []byte(benchSet)})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := parser.Parse(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParser_ReadCommandSet(b *testing.B) {
+	parser := NewParser(&repeatReader{data: []byte(benchSet)})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := parser.ReadCommand(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func clone(b []byte) []byte {
+	return append([]byte(nil), b...)
+}
+
+func TestSplitArgsAndQuoteArg(t *testing.T) {
+	args, err := SplitArgs(`requirepass "with space"  'single' plain`)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"requirepass", "with space", "single", "plain"}, args)
+
+	for _, arg := range []string{"plain", "", "with space", `q"uo'te\`, "tab\tnl\n\x00\xff"} {
+		quoted := QuoteArg(arg)
+		args, err := SplitArgs("x " + quoted)
+		assert.NoError(t, err, quoted)
+		assert.Equal(t, []string{"x", arg}, args, quoted)
+	}
+	assert.Equal(t, "plain", QuoteArg("plain"))
+	assert.Equal(t, `x "plain"`, string(AppendQuoted([]byte("x "), []byte("plain"))))
+	assert.Equal(t, `"\\\"\x00\xff"`, string(AppendQuoted(nil, []byte("\\\"\x00\xff"))))
+}
@@ -4,34 +4,53 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"math"
+	"strconv"
+	"strings"
 )
 
 // Encoder encodes RESP (REdis Serialization Protocol) responses
 type Encoder struct {
 	writer *bufio.Writer
+	proto  int
+	nested int
+	manual bool
 }
 
 // NewEncoder creates a new RESP encoder
 func NewEncoder(w io.Writer) *Encoder {
 	return &Encoder{
 		writer: bufio.NewWriter(w),
+		proto:  2,
 	}
 }
 
-// WriteSimpleString writes a RESP simple string (+OK\r\n)
+// WriteSimpleString writes a RESP simple string (+OK\r\n). Line breaks
+// in s are sent as spaces.
 func (e *Encoder) WriteSimpleString(s string) error {
-	if _, err := e.writer.WriteString(fmt.Sprintf("+%s\r\n", s)); err != nil {
-		return err
-	}
-	return e.writer.Flush()
+	return e.writeLine(SimpleString, singleLine(s))
 }
 
-// WriteError writes a RESP error (-ERR message\r\n)
+// WriteError writes a RESP error (-ERR message\r\n). Line breaks in msg,
+// which may echo client input, are sent as spaces.
 func (e *Encoder) WriteError(msg string) error {
-	if _, err := e.writer.WriteString(fmt.Sprintf("-%s\r\n", msg)); err != nil {
-		return err
+	return e.writeLine(Error, singleLine(msg))
+}
+
+// singleLine replaces the CR and LF characters of s with spaces, as Redis
+// does for status and error replies, so that text echoed from a request
+// cannot end the line early and add frames of its own
+func singleLine(s string) string {
+	if !strings.ContainsAny(s, "\r\n") {
+		return s
 	}
-	return e.writer.Flush()
+	b := []byte(s)
+	for i, c := range b {
+		if c == '\r' || c == '\n' {
+			b[i] = ' '
+		}
+	}
+	return string(b)
 }
 
 // WriteInteger writes a RESP integer (:123\r\n)
@@ -39,23 +58,50 @@ func (e *Encoder) WriteInteger(n int64) error {
 	if _, err := e.writer.WriteString(fmt.Sprintf(":%d\r\n", n)); err != nil {
 		return err
 	}
-	return e.writer.Flush()
+	return e.flush()
 }
 
 // WriteBulkString writes a RESP bulk string ($6\r\nfoobar\r\n)
 func (e *Encoder) WriteBulkString(s string) error {
-	if _, err := e.writer.WriteString(fmt.Sprintf("$%d\r\n%s\r\n", len(s), s)); err != nil {
+	if err := e.writeBulkString(s); err != nil {
 		return err
 	}
-	return e.writer.Flush()
+	return e.flush()
 }
 
-// WriteNull writes a RESP null bulk string ($-1\r\n)
+// WriteBulk writes a binary-safe RESP bulk string from a byte slice
+func (e *Encoder) WriteBulk(b []byte) error {
+	if err := e.writeBulkHeader(len(b)); err != nil {
+		return err
+	}
+	if _, err := e.writer.Write(b); err != nil {
+		return err
+	}
+	if _, err := e.writer.WriteString("\r\n"); err != nil {
+		return err
+	}
+	return e.flush()
+}
+
+// WriteNull writes a RESP null bulk string ($-1\r\n), or the RESP3 null
+// (_\r\n) when protocol version 3 is selected
 func (e *Encoder) WriteNull() error {
+	if e.proto >= 3 {
+		return e.writeLine('_', "")
+	}
 	if _, err := e.writer.WriteString("$-1\r\n"); err != nil {
 		return err
 	}
-	return e.writer.Flush()
+	return e.flush()
+}
+
+// WriteArrayHeader writes the header of a RESP array of n elements (*n\r\n).
+// The caller writes the elements next.
+func (e *Encoder) WriteArrayHeader(n int) error {
+	if _, err := e.writer.WriteString(fmt.Sprintf("*%d\r\n", n)); err != nil {
+		return err
+	}
+	return e.flush()
 }
 
 // WriteArray writes a RESP array (*2\r\n$3\r\nfoo\r\n$3\r\nbar\r\n)
@@ -64,9 +110,174 @@ func (e *Encoder) WriteArray(arr []string) error {
 		return err
 	}
 	for _, s := range arr {
-		if _, err := e.writer.WriteString(fmt.Sprintf("$%d\r\n%s\r\n", len(s), s)); err != nil {
+		if err := e.writeBulkString(s); err != nil {
 			return err
 		}
 	}
+	return e.flush()
+}
+
+// SetProtocol selects the RESP version used for replies. Version 3 enables
+// the RESP3 types; with version 2 they are written as their closest RESP2
+// equivalents.
+func (e *Encoder) SetProtocol(version int) {
+	e.proto = version
+}
+
+// Protocol returns the RESP version used for replies
+func (e *Encoder) Protocol() int {
+	return e.proto
+}
+
+// WriteMapHeader writes the header of a map of n key/value pairs
+// (%n\r\n). In RESP2 this is an array of 2n elements.
+func (e *Encoder) WriteMapHeader(n int) error {
+	if e.proto < 3 {
+		return e.WriteArrayHeader(2 * n)
+	}
+	return e.writeHeader('%', n)
+}
+
+// WriteSetHeader writes the header of a set of n elements (~n\r\n). In
+// RESP2 this is an array header.
+func (e *Encoder) WriteSetHeader(n int) error {
+	if e.proto < 3 {
+		return e.WriteArrayHeader(n)
+	}
+	return e.writeHeader('~', n)
+}
+
+// WritePushHeader writes the header of an out-of-band push frame of n
+// elements (>n\r\n). In RESP2 this is an array header.
+func (e *Encoder) WritePushHeader(n int) error {
+	if e.proto < 3 {
+		return e.WriteArrayHeader(n)
+	}
+	return e.writeHeader('>', n)
+}
+
+// WriteAttributeHeader writes the header of an attribute map of n pairs
+// (|n\r\n) that precedes the reply it describes. Attributes have no RESP2
+// form, so callers must skip them entirely for RESP2 clients.
+func (e *Encoder) WriteAttributeHeader(n int) error {
+	return e.writeHeader('|', n)
+}
+
+// WriteDouble writes a RESP3 double (,3.14\r\n). In RESP2 the number is
+// sent as a bulk string.
+func (e *Encoder) WriteDouble(f float64) error {
+	var s string
+	switch {
+	case math.IsInf(f, 1):
+		s = "inf"
+	case math.IsInf(f, -1):
+		s = "-inf"
+	case math.IsNaN(f):
+		s = "nan"
+	default:
+		s = strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	if e.proto < 3 {
+		return e.WriteBulkString(s)
+	}
+	return e.writeLine(',', s)
+}
+
+// WriteBoolean writes a RESP3 boolean (#t\r\n). In RESP2 it is sent as the
+// integer 1 or 0.
+func (e *Encoder) WriteBoolean(b bool) error {
+	if e.proto < 3 {
+		if b {
+			return e.WriteInteger(1)
+		}
+		return e.WriteInteger(0)
+	}
+	if b {
+		return e.writeLine('#', "t")
+	}
+	return e.writeLine('#', "f")
+}
+
+// WriteBigNumber writes a RESP3 big number given as decimal digits
+// ((12345678901234567890\r\n). In RESP2 it is sent as a bulk string.
+func (e *Encoder) WriteBigNumber(digits string) error {
+	if e.proto < 3 {
+		return e.WriteBulkString(digits)
+	}
+	return e.writeLine('(', digits)
+}
+
+// WriteVerbatim writes a RESP3 verbatim string with a three letter format
+// such as txt or mkd (=8\r\ntxt:text\r\n). In RESP2 only the text is sent,
+// as a bulk string.
+func (e *Encoder) WriteVerbatim(format, text string) error {
+	if e.proto < 3 {
+		return e.WriteBulkString(text)
+	}
+	if _, err := e.writer.WriteString(fmt.Sprintf("=%d\r\n%s:%s\r\n", len(format)+1+len(text), format, text)); err != nil {
+		return err
+	}
+	return e.flush()
+}
+
+// SetAutoFlush controls whether every reply is sent as soon as it is
+// written (the default). With auto flush off, replies accumulate in the
+// buffer until Flush is called, so a batch of replies costs one write.
+func (e *Encoder) SetAutoFlush(enabled bool) {
+	e.manual = !enabled
+}
+
+// Flush sends all buffered replies to the underlying writer
+func (e *Encoder) Flush() error {
 	return e.writer.Flush()
 }
+
+// Buffered returns the number of bytes of replies not yet sent
+func (e *Encoder) Buffered() int {
+	return e.writer.Buffered()
+}
+
+// flush sends buffered output to the underlying writer, unless an
+// aggregate reply is still being written by WriteValue or auto flush is off
+func (e *Encoder) flush() error {
+	if e.nested > 0 || e.manual {
+		return nil
+	}
+	return e.writer.Flush()
+}
+
+// writeBulkString writes a bulk string without flushing
+func (e *Encoder) writeBulkString(s string) error {
+	if err := e.writeBulkHeader(len(s)); err != nil {
+		return err
+	}
+	if _, err := e.writer.WriteString(s); err != nil {
+		return err
+	}
+	_, err := e.writer.WriteString("\r\n")
+	return err
+}
+
+// writeBulkHeader writes the length line of a bulk string ($6\r\n)
+func (e *Encoder) writeBulkHeader(n int) error {
+	var scratch [24]byte
+	line := append(strconv.AppendInt(append(scratch[:0], '$'), int64(n), 10), '\r', '\n')
+	_, err := e.writer.Write(line)
+	return err
+}
+
+// writeHeader writes an aggregate type header such as %2\r\n
+func (e *Encoder) writeHeader(prefix byte, n int) error {
+	return e.writeLine(prefix, strconv.Itoa(n))
+}
+
+// writeLine writes a single-line RESP frame with the given type prefix
+func (e *Encoder) writeLine(prefix byte, s string) error {
+	if err := e.writer.WriteByte(prefix); err != nil {
+		return err
+	}
+	if _, err := e.writer.WriteString(s + "\r\n"); err != nil {
+		return err
+	}
+	return e.flush()
+}
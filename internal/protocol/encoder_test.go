@@ -2,6 +2,9 @@ package protocol
 
 import (
 	"bytes"
+	"io"
+	"math"
+	"net"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -25,6 +28,16 @@ func TestEncoder_Error(t *testing.T) {
 	assert.Equal(t, "-ERR unknown command\r\n", buf.String())
 }
 
+func TestEncoder_LineBreaksInStatus(t *testing.T) {
+	buf := &bytes.Buffer{}
+	enc := NewEncoder(buf)
+
+	// Text echoed from a request cannot add frames to the reply
+	assert.NoError(t, enc.WriteError("ERR unknown command 'X\r\n+OK'"))
+	assert.NoError(t, enc.WriteSimpleString("a\nb\xff"))
+	assert.Equal(t, "-ERR unknown command 'X  +OK'\r\n+a b\xff\r\n", buf.String())
+}
+
 func TestEncoder_Integer(t *testing.T) {
 	buf := &bytes.Buffer{}
 	enc := NewEncoder(buf)
@@ -69,3 +82,98 @@ func TestEncoder_EmptyArray(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "*0\r\n", buf.String())
 }
+
+func TestEncoder_RESP3Types(t *testing.T) {
+	buf := &bytes.Buffer{}
+	enc := NewEncoder(buf)
+	enc.SetProtocol(3)
+
+	assert.NoError(t, enc.WriteNull())
+	assert.NoError(t, enc.WriteMapHeader(1))
+	assert.NoError(t, enc.WriteSetHeader(2))
+	assert.NoError(t, enc.WritePushHeader(3))
+	assert.NoError(t, enc.WriteAttributeHeader(1))
+	assert.NoError(t, enc.WriteDouble(3.5))
+	assert.NoError(t, enc.WriteDouble(math.Inf(-1)))
+	assert.NoError(t, enc.WriteBoolean(true))
+	assert.NoError(t, enc.WriteBoolean(false))
+	assert.NoError(t, enc.WriteBigNumber("12345678901234567890"))
+	assert.NoError(t, enc.WriteVerbatim("txt", "Some string"))
+
+	assert.Equal(t, "_\r\n%1\r\n~2\r\n>3\r\n|1\r\n,3.5\r\n,-inf\r\n#t\r\n#f\r\n"+
+		"(12345678901234567890\r\n=15\r\ntxt:Some string\r\n", buf.String())
+}
+
+func TestEncoder_RESP3TypesInRESP2(t *testing.T) {
+	buf := &bytes.Buffer{}
+	enc := NewEncoder(buf)
+
+	assert.NoError(t, enc.WriteNull())
+	assert.NoError(t, enc.WriteMapHeader(1))
+	assert.NoError(t, enc.WriteSetHeader(2))
+	assert.NoError(t, enc.WritePushHeader(3))
+	assert.NoError(t, enc.WriteDouble(3.5))
+	assert.NoError(t, enc.WriteBoolean(true))
+	assert.NoError(t, enc.WriteBigNumber("42"))
+	assert.NoError(t, enc.WriteVerbatim("txt", "hi"))
+
+	assert.Equal(t, "$-1\r\n*2\r\n*2\r\n*3\r\n$3\r\n3.5\r\n:1\r\n$2\r\n42\r\n$2\r\nhi\r\n", buf.String())
+}
+
+func TestEncoder_ManualFlush(t *testing.T) {
+	buf := &bytes.Buffer{}
+	enc := NewEncoder(buf)
+	enc.SetAutoFlush(false)
+
+	assert.NoError(t, enc.WriteSimpleString("OK"))
+	assert.NoError(t, enc.WriteInteger(1))
+	assert.Equal(t, "", buf.String())
+
+	assert.NoError(t, enc.Flush())
+	assert.Equal(t, "+OK\r\n:1\r\n", buf.String())
+}
+
+// benchmarkEncoderOverTCP writes b.N replies to a loopback TCP connection,
+// flushing every batch replies
+func benchmarkEncoderOverTCP(b *testing.B, batch int) {
+	ln, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer ln.Close()
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.Copy(io.Discard, conn)
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer conn.Close()
+
+	enc := NewEncoder(conn)
+	enc.SetAutoFlush(false)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		enc.WriteSimpleString("PONG")
+		if (i+1)%batch == 0 {
+			enc.Flush()
+		}
+	}
+	enc.Flush()
+}
+
+func BenchmarkEncoder_FlushPerReply(b *testing.B) {
+	benchmarkEncoderOverTCP(b, 1)
+}
+
+func BenchmarkEncoder_FlushPerBatch(b *testing.B) {
+	benchmarkEncoderOverTCP(b, 1000)
+}
@@ -0,0 +1,173 @@
+package protocol
+
+// splitInline splits an inline command line into arguments the way
+// redis-cli does. Arguments are separated by whitespace and may be quoted:
+// double quotes support the escapes \n \r \t \b \a \\ \" and \xHH, single
+// quotes only \'. A closing quote must be followed by whitespace or the end
+// of the line.
+//
+// The unescaped arguments are appended to arena and their start and end
+// offsets in arena to bounds, so no memory is allocated once both have
+// grown to size.
+func splitInline(arena []byte, bounds []int, line []byte) ([]byte, []int, error) {
+	i := 0
+	for {
+		for i < len(line) && isSpace(line[i]) {
+			i++
+		}
+		if i == len(line) {
+			return arena, bounds, nil
+		}
+
+		start := len(arena)
+		inDouble, inSingle := false, false
+		for done := false; !done; {
+			if i == len(line) {
+				if inDouble || inSingle {
+					return arena, bounds, protocolErrorf("unbalanced quotes in request")
+				}
+				break
+			}
+			c := line[i]
+
+			switch {
+			case inDouble:
+				switch {
+				case c == '\\' && i+3 < len(line) && line[i+1] == 'x' && isHex(line[i+2]) && isHex(line[i+3]):
+					arena = append(arena, unhex(line[i+2])<<4|unhex(line[i+3]))
+					i += 3
+				case c == '\\' && i+1 < len(line):
+					i++
+					arena = append(arena, unescape(line[i]))
+				case c == '"':
+					if i+1 < len(line) && !isSpace(line[i+1]) {
+						return arena, bounds, protocolErrorf("unbalanced quotes in request")
+					}
+					done = true
+				default:
+					arena = append(arena, c)
+				}
+			case inSingle:
+				switch {
+				case c == '\\' && i+1 < len(line) && line[i+1] == '\'':
+					i++
+					arena = append(arena, '\'')
+				case c == '\'':
+					if i+1 < len(line) && !isSpace(line[i+1]) {
+						return arena, bounds, protocolErrorf("unbalanced quotes in request")
+					}
+					done = true
+				default:
+					arena = append(arena, c)
+				}
+			default:
+				switch {
+				case isSpace(c):
+					done = true
+				case c == '"':
+					inDouble = true
+				case c == '\'':
+					inSingle = true
+				default:
+					arena = append(arena, c)
+				}
+			}
+			i++
+		}
+
+		bounds = append(bounds, start, len(arena))
+	}
+}
+
+// unescape returns the byte a backslash escape inside double quotes stands
+// for; unknown escapes stand for the character itself
+func unescape(c byte) byte {
+	switch c {
+	case 'n':
+		return '\n'
+	case 'r':
+		return '\r'
+	case 't':
+		return '\t'
+	case 'b':
+		return '\b'
+	case 'a':
+		return '\a'
+	default:
+		return c
+	}
+}
+
+// isHex reports whether c is a hexadecimal digit
+func isHex(c byte) bool {
+	return ('0' <= c && c <= '9') || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
+
+// unhex returns the value of the hexadecimal digit c
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
+
+// SplitArgs splits a line into arguments with the quoting rules of inline
+// commands. Configuration files use the same syntax.
+func SplitArgs(line string) ([]string, error) {
+	arena, bounds, err := splitInline(nil, nil, []byte(line))
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, 0, len(bounds)/2)
+	for i := 0; i < len(bounds); i += 2 {
+		args = append(args, string(arena[bounds[i]:bounds[i+1]]))
+	}
+	return args, nil
+}
+
+// QuoteArg returns arg in a form SplitArgs reads back as a single
+// argument: unchanged when that is unambiguous, otherwise double-quoted
+// with special characters escaped
+func QuoteArg(arg string) string {
+	plain := arg != ""
+	for i := 0; i < len(arg) && plain; i++ {
+		c := arg[i]
+		plain = c > ' ' && c < 0x7f && c != '"' && c != '\'' && c != '\\'
+	}
+	if plain {
+		return arg
+	}
+
+	return string(AppendQuoted(make([]byte, 0, len(arg)+2), []byte(arg)))
+}
+
+// AppendQuoted appends arg to dst double-quoted, with backslashes, quotes
+// and non-printable characters escaped
+func AppendQuoted(dst, arg []byte) []byte {
+	const hex = "0123456789abcdef"
+	dst = append(dst, '"')
+	for _, c := range arg {
+		switch c {
+		case '\\', '"':
+			dst = append(dst, '\\', c)
+		case '\n':
+			dst = append(dst, '\\', 'n')
+		case '\r':
+			dst = append(dst, '\\', 'r')
+		case '\t':
+			dst = append(dst, '\\', 't')
+		default:
+			if c < ' ' || c >= 0x7f {
+				dst = append(dst, '\\', 'x', hex[c>>4], hex[c&0xf])
+			} else {
+				dst = append(dst, c)
+			}
+		}
+	}
+	return append(dst, '"')
+}
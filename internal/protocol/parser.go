@@ -2,10 +2,10 @@ package protocol
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"io"
 	"strconv"
-	"strings"
 )
 
 // RESP (REdis Serialization Protocol) types
@@ -17,20 +17,84 @@ const (
 	Array        = '*'
 )
 
+// Limits bounds what a single message may make the parser allocate. A
+// zero field means no limit.
+type Limits struct {
+	// MaxBulkLen is the largest accepted bulk string (proto-max-bulk-len)
+	MaxBulkLen int
+	// MaxArrayLen is the largest accepted number of array elements
+	MaxArrayLen int
+	// MaxInlineLen is the longest accepted line, which bounds inline
+	// commands as well as the header lines of other types
+	MaxInlineLen int
+	// MaxDepth is the deepest accepted nesting of arrays
+	MaxDepth int
+}
+
+// DefaultLimits mirrors the limits of Redis
+var DefaultLimits = Limits{
+	MaxBulkLen:   512 * 1024 * 1024,
+	MaxArrayLen:  1024 * 1024 * 1024,
+	MaxInlineLen: 64 * 1024,
+	MaxDepth:     8,
+}
+
+// bulkChunkSize is how much of a large bulk string is allocated at a time,
+// so that memory grows with the data actually received rather than with
+// the length a client announces
+const bulkChunkSize = 1024 * 1024
+
+// maxArrayPrealloc caps how many array elements are allocated up front
+const maxArrayPrealloc = 1024
+
+// ProtocolError reports a malformed or oversized message. The stream can
+// no longer be parsed reliably afterwards, so the connection is closed.
+type ProtocolError struct {
+	msg string
+}
+
+// Error implements the error interface
+func (e *ProtocolError) Error() string {
+	return "Protocol error: " + e.msg
+}
+
+// protocolErrorf creates a ProtocolError with a formatted message
+func protocolErrorf(format string, args ...interface{}) error {
+	return &ProtocolError{msg: fmt.Sprintf(format, args...)}
+}
+
 // Parser handles RESP protocol parsing
 type Parser struct {
 	reader *bufio.Reader
+	limits Limits
+
+	// Reused across ReadCommand calls
+	arena  []byte
+	bounds []int
+	args   [][]byte
+	line   []byte
 }
 
-// NewParser creates a new RESP parser
+// NewParser creates a new RESP parser with DefaultLimits
 func NewParser(reader io.Reader) *Parser {
 	return &Parser{
 		reader: bufio.NewReader(reader),
+		limits: DefaultLimits,
 	}
 }
 
+// SetLimits replaces the limits applied to subsequent messages
+func (p *Parser) SetLimits(limits Limits) {
+	p.limits = limits
+}
+
 // Parse reads and parses a RESP message
 func (p *Parser) Parse() (interface{}, error) {
+	return p.parse(0)
+}
+
+// parse reads and parses a RESP message nested depth arrays deep
+func (p *Parser) parse(depth int) (interface{}, error) {
 	line, err := p.readLine()
 	if err != nil {
 		return nil, err
@@ -44,82 +108,288 @@ func (p *Parser) Parse() (interface{}, error) {
 	case SimpleString:
 		return string(line[1:]), nil
 	case Error:
-		return nil, fmt.Errorf(string(line[1:]))
+		return nil, fmt.Errorf("%s", line[1:])
 	case Integer:
 		return strconv.ParseInt(string(line[1:]), 10, 64)
 	case BulkString:
 		return p.parseBulkString(line)
 	case Array:
-		return p.parseArray(line)
+		return p.parseArray(line, depth)
 	default:
 		// Handle inline commands (plain text)
-		return p.parseInline(string(line))
+		return p.parseInline(line)
 	}
 }
 
-// parseBulkString parses a RESP bulk string
+// parseBulkString parses a RESP bulk string into a binary-safe []byte
 func (p *Parser) parseBulkString(line []byte) (interface{}, error) {
 	length, err := strconv.Atoi(string(line[1:]))
-	if err != nil {
-		return nil, fmt.Errorf("invalid bulk string length: %w", err)
+	if err != nil || length < -1 {
+		return nil, protocolErrorf("invalid bulk length")
 	}
 
 	if length == -1 {
 		return nil, nil // Null bulk string
 	}
+	if p.limits.MaxBulkLen > 0 && length > p.limits.MaxBulkLen {
+		return nil, protocolErrorf("invalid bulk length")
+	}
 
-	buf := make([]byte, length+2) // +2 for \r\n
-	_, err = io.ReadFull(p.reader, buf)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read bulk string: %w", err)
+	// Small strings are read at once; large ones a chunk at a time
+	var buf []byte
+	for read := 0; read < length+2; { // +2 for \r\n
+		n := length + 2 - read
+		if n > bulkChunkSize {
+			n = bulkChunkSize
+		}
+		if buf == nil && n == length+2 {
+			buf = make([]byte, n)
+		} else {
+			buf = append(buf, make([]byte, n)...)
+		}
+		if _, err := io.ReadFull(p.reader, buf[read:read+n]); err != nil {
+			return nil, fmt.Errorf("failed to read bulk string: %w", err)
+		}
+		read += n
+	}
+	if buf[length] != '\r' || buf[length+1] != '\n' {
+		return nil, protocolErrorf("bulk string is not terminated by CRLF")
 	}
 
-	return string(buf[:length]), nil
+	return buf[:length:length], nil
 }
 
-// parseArray parses a RESP array
-func (p *Parser) parseArray(line []byte) (interface{}, error) {
+// parseArray parses a RESP array found depth arrays deep
+func (p *Parser) parseArray(line []byte, depth int) (interface{}, error) {
 	count, err := strconv.Atoi(string(line[1:]))
-	if err != nil {
-		return nil, fmt.Errorf("invalid array length: %w", err)
+	if err != nil || count < -1 {
+		return nil, protocolErrorf("invalid multibulk length")
 	}
 
 	if count == -1 {
 		return nil, nil // Null array
 	}
+	if p.limits.MaxArrayLen > 0 && count > p.limits.MaxArrayLen {
+		return nil, protocolErrorf("invalid multibulk length")
+	}
+	if p.limits.MaxDepth > 0 && depth >= p.limits.MaxDepth {
+		return nil, protocolErrorf("too deeply nested arrays")
+	}
 
-	array := make([]interface{}, count)
+	// Grow as elements arrive rather than trusting the announced count
+	array := make([]interface{}, 0, min(count, maxArrayPrealloc))
 	for i := 0; i < count; i++ {
-		element, err := p.Parse()
+		element, err := p.parse(depth + 1)
 		if err != nil {
 			return nil, err
 		}
-		array[i] = element
+		array = append(array, element)
 	}
 
 	return array, nil
 }
 
-// parseInline parses inline commands (plain text, space-separated)
-func (p *Parser) parseInline(line string) (interface{}, error) {
-	parts := strings.Fields(line)
-	if len(parts) == 0 {
+// parseInline parses inline commands (plain text, space-separated, with
+// optional quoting as described for splitInline). Like bulk strings, the
+// arguments are returned as []byte.
+func (p *Parser) parseInline(line []byte) (interface{}, error) {
+	arena, bounds, err := splitInline(nil, nil, line)
+	if err != nil {
+		return nil, err
+	}
+	if len(bounds) == 0 {
 		return nil, fmt.Errorf("empty command")
 	}
 
-	result := make([]interface{}, len(parts))
-	for i, part := range parts {
-		result[i] = part
+	result := make([]interface{}, 0, len(bounds)/2)
+	for i := 0; i < len(bounds); i += 2 {
+		result = append(result, arena[bounds[i]:bounds[i+1]:bounds[i+1]])
 	}
 
 	return result, nil
 }
 
-// readLine reads a line from the reader
+// Pending reports whether a complete request is already buffered, so that
+// the next ReadCommand returns without reading from the underlying reader.
+// Empty requests, which ReadCommand skips, do not count.
+func (p *Parser) Pending() bool {
+	n := p.reader.Buffered()
+	if n == 0 {
+		return false
+	}
+	buf, _ := p.reader.Peek(n)
+	for len(buf) > 0 {
+		length := FrameLength(buf, p.limits)
+		if length < 0 {
+			return false
+		}
+		if !emptyRequest(buf[:length]) {
+			return true
+		}
+		buf = buf[length:]
+	}
+	return false
+}
+
+// emptyRequest reports whether a complete message is a request without
+// arguments: a blank inline line, or an empty or null array
+func emptyRequest(frame []byte) bool {
+	if frame[0] == Array {
+		count, ok := parseLength(bytes.TrimRight(frame[1:], "\r\n"))
+		return ok && (count == 0 || count == -1)
+	}
+	for _, c := range frame {
+		if !isSpace(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// Buffered returns the number of bytes received but not yet parsed, and
+// how many more the read buffer can hold
+func (p *Parser) Buffered() (n, free int) {
+	n = p.reader.Buffered()
+	return n, p.reader.Size() - n
+}
+
+// FrameLength returns the length of the complete message at the start of
+// buf, or -1 if more data is needed. Malformed headers, and headers over
+// limits, count as complete, since parsing them fails without further
+// input. Readers of non-blocking sockets use it to pass on only whole
+// requests. Nested arrays are followed without recursion, so that deep
+// nesting cannot exhaust the stack even without a depth limit.
+func FrameLength(buf []byte, limits Limits) int {
+	var pending []int // elements still expected by each enclosing array
+	pos := 0
+	for {
+		n, elements, bad := frameElement(buf[pos:], limits, len(pending))
+		if n < 0 {
+			return -1
+		}
+		pos += n
+		if bad {
+			return pos
+		}
+		if elements > 0 {
+			pending = append(pending, elements)
+			continue
+		}
+		if pending = completeElement(pending); len(pending) == 0 {
+			return pos
+		}
+	}
+}
+
+// FrameScanner finds the complete messages in a buffer that grows as data
+// arrives. It remembers how far into a partly received message it got, so
+// that each byte is scanned once however many reads the message takes.
+type FrameScanner struct {
+	pos     int   // offset of the next element to scan
+	pending []int // elements still expected by each enclosing array
+}
+
+// Scan returns the length of the complete messages at the start of buf,
+// handling headers like FrameLength. The caller must drop them from the
+// buffer before passing it, with more data appended, to the next Scan.
+func (s *FrameScanner) Scan(buf []byte, limits Limits) int {
+	complete := 0
+	for s.pos < len(buf) {
+		n, elements, bad := frameElement(buf[s.pos:], limits, len(s.pending))
+		if n < 0 {
+			break
+		}
+		s.pos += n
+		switch {
+		case bad:
+			s.pending = s.pending[:0]
+		case elements > 0:
+			s.pending = append(s.pending, elements)
+			continue
+		default:
+			s.pending = completeElement(s.pending)
+		}
+		if len(s.pending) == 0 {
+			complete = s.pos
+		}
+	}
+	s.pos -= complete
+	return complete
+}
+
+// completeElement counts an element as received in the innermost of the
+// pending arrays, which completes the arrays it was the last element of
+func completeElement(pending []int) []int {
+	for len(pending) > 0 {
+		pending[len(pending)-1]--
+		if pending[len(pending)-1] > 0 {
+			break
+		}
+		pending = pending[:len(pending)-1]
+	}
+	return pending
+}
+
+// frameElement scans the header of the element at the start of buf,
+// nested depth arrays deep, and the data of a bulk string. It returns the
+// bytes taken, or -1 if more data is needed, and how many elements an
+// array announces. bad reports a malformed header or one over limits.
+func frameElement(buf []byte, limits Limits, depth int) (n, elements int, bad bool) {
+	end := bytes.IndexByte(buf, '\n')
+	if end < 0 {
+		if limits.MaxInlineLen > 0 && len(buf) > limits.MaxInlineLen+2 {
+			return len(buf), 0, true
+		}
+		return -1, 0, false
+	}
+	line := bytes.TrimSuffix(buf[:end], []byte("\r"))
+	n = end + 1
+	if len(line) == 0 {
+		return n, 0, false
+	}
+
+	switch line[0] {
+	case BulkString:
+		length, err := strconv.Atoi(string(line[1:]))
+		if err != nil || length < -1 || (limits.MaxBulkLen > 0 && length > limits.MaxBulkLen) {
+			return n, 0, true
+		}
+		if length == -1 {
+			return n, 0, false
+		}
+		if n+length+2 > len(buf) {
+			return -1, 0, false
+		}
+		return n + length + 2, 0, false
+	case Array:
+		count, err := strconv.Atoi(string(line[1:]))
+		if err != nil || count < -1 || (limits.MaxArrayLen > 0 && count > limits.MaxArrayLen) ||
+			(limits.MaxDepth > 0 && depth >= limits.MaxDepth) {
+			return n, 0, true
+		}
+		return n, max(count, 0), false
+	default:
+		return n, 0, false
+	}
+}
+
+// readLine reads a line from the reader, failing once it grows past
+// MaxInlineLen without a newline
 func (p *Parser) readLine() ([]byte, error) {
-	line, err := p.reader.ReadBytes('\n')
-	if err != nil {
-		return nil, err
+	var line []byte
+	for {
+		chunk, err := p.reader.ReadSlice('\n')
+		line = append(line, chunk...)
+		if p.limits.MaxInlineLen > 0 && len(line) > p.limits.MaxInlineLen+2 {
+			return nil, protocolErrorf("too big inline request")
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		break
 	}
 
 	// Remove \r\n
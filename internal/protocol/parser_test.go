@@ -2,6 +2,7 @@ package protocol
 
 import (
 	"bytes"
+	"strconv"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -40,7 +41,7 @@ func TestParser_BulkString(t *testing.T) {
 
 	result, err := parser.Parse()
 	assert.NoError(t, err)
-	assert.Equal(t, "foobar", result)
+	assert.Equal(t, []byte("foobar"), result)
 }
 
 func TestParser_NullBulkString(t *testing.T) {
@@ -62,8 +63,8 @@ func TestParser_Array(t *testing.T) {
 	array, ok := result.([]interface{})
 	assert.True(t, ok)
 	assert.Equal(t, 2, len(array))
-	assert.Equal(t, "GET", array[0])
-	assert.Equal(t, "key", array[1])
+	assert.Equal(t, []byte("GET"), array[0])
+	assert.Equal(t, []byte("key"), array[1])
 }
 
 func TestParser_InlineCommand(t *testing.T) {
@@ -76,6 +77,217 @@ func TestParser_InlineCommand(t *testing.T) {
 	array, ok := result.([]interface{})
 	assert.True(t, ok)
 	assert.Equal(t, 2, len(array))
-	assert.Equal(t, "GET", array[0])
-	assert.Equal(t, "key", array[1])
+	assert.Equal(t, []byte("GET"), array[0])
+	assert.Equal(t, []byte("key"), array[1])
+}
+
+func TestParser_InlineCommandQuoted(t *testing.T) {
+	parser := NewParser(bytes.NewBufferString("SET greeting \"hello world\"\n"))
+
+	result, err := parser.Parse()
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{[]byte("SET"), []byte("greeting"), []byte("hello world")}, result)
+
+	parser = NewParser(bytes.NewBufferString("SET greeting \"hello\n"))
+
+	_, err = parser.Parse()
+	assert.IsType(t, &ProtocolError{}, err)
+}
+
+func TestParser_Pending(t *testing.T) {
+	input := "*1\r\n$4\r\nPING\r\n*2\r\n$3\r\nGET\r\n$1\r\nk\r\n*2\r\n$3\r\nGET\r\n$1"
+	parser := NewParser(bytes.NewBufferString(input))
+
+	assert.False(t, parser.Pending())
+
+	_, err := parser.Parse()
+	assert.NoError(t, err)
+	assert.True(t, parser.Pending())
+
+	_, err = parser.Parse()
+	assert.NoError(t, err)
+
+	// Only part of the third command has arrived
+	assert.False(t, parser.Pending())
+}
+
+func TestParser_PendingInline(t *testing.T) {
+	parser := NewParser(bytes.NewBufferString("PING\r\nPING\r\nPI"))
+
+	_, err := parser.Parse()
+	assert.NoError(t, err)
+	assert.True(t, parser.Pending())
+
+	_, err = parser.Parse()
+	assert.NoError(t, err)
+	assert.False(t, parser.Pending())
+}
+
+func TestParser_PendingSkipsEmptyRequests(t *testing.T) {
+	for _, empty := range []string{"\r\n", "\n", "   \r\n", "*0\r\n", "*-1\r\n"} {
+		// ReadCommand skips empty requests, so they must not count as pending
+		parser := NewParser(bytes.NewBufferString("PING\r\n" + empty))
+		_, err := parser.ReadCommand()
+		assert.NoError(t, err)
+		assert.False(t, parser.Pending(), "%q", empty)
+
+		parser = NewParser(bytes.NewBufferString("PING\r\n" + empty + "PING\r\n"))
+		_, err = parser.ReadCommand()
+		assert.NoError(t, err)
+		assert.True(t, parser.Pending(), "%q", empty)
+	}
+
+	// A malformed header fails the next read without more input
+	parser := NewParser(bytes.NewBufferString("PING\r\n*x\r\n"))
+	_, err := parser.ReadCommand()
+	assert.NoError(t, err)
+	assert.True(t, parser.Pending())
+}
+
+func TestParser_BinaryBulkString(t *testing.T) {
+	value := "a\r\nb\x00c\r\n"
+	input := "$" + strconv.Itoa(len(value)) + "\r\n" + value + "\r\n"
+	parser := NewParser(bytes.NewBufferString(input))
+
+	result, err := parser.Parse()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte(value), result)
+}
+
+func TestParser_LargeBulkString(t *testing.T) {
+	value := bytes.Repeat([]byte("0123456789\r\n\x00"), 400000)
+	input := append([]byte("*2\r\n$3\r\nSET\r\n$"+strconv.Itoa(len(value))+"\r\n"), value...)
+	input = append(input, "\r\n"...)
+	parser := NewParser(bytes.NewReader(input))
+
+	result, err := parser.Parse()
+	assert.NoError(t, err)
+	array := result.([]interface{})
+	assert.Equal(t, value, array[1])
+}
+
+func TestParser_BulkStringMissingCRLF(t *testing.T) {
+	parser := NewParser(bytes.NewBufferString("$3\r\nfooXY"))
+
+	_, err := parser.Parse()
+	assert.Error(t, err)
+}
+
+func TestParser_BulkLengthLimit(t *testing.T) {
+	parser := NewParser(bytes.NewBufferString("$2000000000\r\n"))
+
+	_, err := parser.Parse()
+	assert.Error(t, err)
+	assert.IsType(t, &ProtocolError{}, err)
+	assert.Equal(t, "Protocol error: invalid bulk length", err.Error())
+
+	parser = NewParser(bytes.NewBufferString("$6\r\nfoobar\r\n"))
+	parser.SetLimits(Limits{MaxBulkLen: 5})
+	_, err = parser.Parse()
+	assert.IsType(t, &ProtocolError{}, err)
+}
+
+func TestParser_ArrayLengthLimit(t *testing.T) {
+	parser := NewParser(bytes.NewBufferString("*3\r\n:1\r\n:2\r\n:3\r\n"))
+	parser.SetLimits(Limits{MaxArrayLen: 2})
+
+	_, err := parser.Parse()
+	assert.IsType(t, &ProtocolError{}, err)
+	assert.Contains(t, err.Error(), "invalid multibulk length")
+
+	parser = NewParser(bytes.NewBufferString("*-5\r\n"))
+	_, err = parser.Parse()
+	assert.IsType(t, &ProtocolError{}, err)
+}
+
+func TestParser_HugeArrayCountDoesNotPreallocate(t *testing.T) {
+	// A huge announced count followed by EOF fails on the missing data
+	// instead of allocating a billion elements first
+	parser := NewParser(bytes.NewBufferString("*1000000000\r\n:1\r\n"))
+
+	_, err := parser.Parse()
+	assert.Error(t, err)
+}
+
+func TestParser_InlineLengthLimit(t *testing.T) {
+	parser := NewParser(bytes.NewReader(bytes.Repeat([]byte("a"), 100000)))
+
+	_, err := parser.Parse()
+	assert.IsType(t, &ProtocolError{}, err)
+	assert.Contains(t, err.Error(), "too big inline request")
+}
+
+func TestFrameLength(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		want  int
+	}{
+		{"*1\r\n$4\r\nPING\r\n*1", 14},
+		{"*2\r\n$3\r\nGET\r\n$1\r\n", -1},
+		{"*2\r\n*1\r\n:1\r\n$-1\r\nPING\r\n", 17},
+		{"*0\r\nPING\r\n", 4},
+		{"PING\r\nPI", 6},
+		{"$5\r\nhel", -1},
+
+		// Headers over the limits are complete, so that parsing fails at
+		// once instead of waiting for the data they announce
+		{"$2000000000\r\n", 13},
+		{"*2\r\n$2000000000\r\n", 17},
+		{"*2000000000\r\n", 13},
+		{"*1\r\n*1\r\n*1\r\n", 12},
+		{"$x\r\nhello", 4},
+	} {
+		assert.Equal(t, tc.want, FrameLength([]byte(tc.input), Limits{MaxBulkLen: 1024, MaxArrayLen: 1024, MaxDepth: 2}), tc.input)
+	}
+
+	// Lines longer than MaxInlineLen do not wait for their end
+	assert.Equal(t, 10, FrameLength(bytes.Repeat([]byte("a"), 10), Limits{MaxInlineLen: 4}))
+
+	// Deep nesting without a depth limit does not exhaust the stack
+	deep := bytes.Repeat([]byte("*1\r\n"), 5_000_000)
+	assert.Equal(t, -1, FrameLength(deep, Limits{}))
+	assert.Equal(t, len(deep)+4, FrameLength(append(deep, ":1\r\n"...), Limits{}))
+}
+
+func TestFrameScanner(t *testing.T) {
+	input := "*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$5\r\nvalue\r\nPING\r\n*2\r\n*1\r\n:1\r\n$-1\r\n"
+	limits := Limits{MaxBulkLen: 1024, MaxDepth: 2}
+
+	// Fed a byte at a time, the scanner finds the same messages as
+	// FrameLength, resuming inside partly received ones
+	var scanner FrameScanner
+	var buf []byte
+	var lengths []int
+	for i := 0; i < len(input); i++ {
+		buf = append(buf, input[i])
+		if n := scanner.Scan(buf, limits); n > 0 {
+			lengths = append(lengths, n)
+			buf = buf[n:]
+		}
+	}
+	assert.Equal(t, []int{31, 6, 17}, lengths)
+	assert.Empty(t, buf)
+
+	// Several messages received at once are found together
+	scanner = FrameScanner{}
+	assert.Equal(t, 37, scanner.Scan([]byte(input[:45]), limits))
+	assert.Equal(t, 17, scanner.Scan([]byte(input[37:]), limits))
+
+	// Headers over the limits complete what came before them
+	scanner = FrameScanner{}
+	assert.Equal(t, 17, scanner.Scan([]byte("PING\r\n*1\r\n$2000\r\nab"), limits))
+}
+
+func TestParser_NestingLimit(t *testing.T) {
+	parser := NewParser(bytes.NewBufferString("*1\r\n*1\r\n*1\r\n:1\r\n"))
+	parser.SetLimits(Limits{MaxDepth: 2})
+
+	_, err := parser.Parse()
+	assert.IsType(t, &ProtocolError{}, err)
+
+	parser = NewParser(bytes.NewBufferString("*1\r\n*1\r\n:1\r\n"))
+	parser.SetLimits(Limits{MaxDepth: 2})
+	result, err := parser.Parse()
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{[]interface{}{int64(1)}}, result)
 }
@@ -0,0 +1,80 @@
+package protocol
+
+import "fmt"
+
+// Marshaler is implemented by reply types that know how to write
+// themselves, typically by calling the Encoder's Write methods and
+// WriteValue for nested elements
+type Marshaler interface {
+	MarshalRESP(e *Encoder) error
+}
+
+// WriteValue writes an arbitrary reply value, recursing into aggregates.
+// Go values map to RESP types as follows:
+//
+//	nil                  null
+//	string, []byte       bulk string
+//	int, int64           integer
+//	bool                 boolean (integer in RESP2)
+//	float64              double (bulk string in RESP2)
+//	error                error
+//	[]string             array of bulk strings
+//	[]interface{}        array of any of these values
+//	Marshaler            whatever MarshalRESP writes
+//
+// Values of any other type are written as an error in their place. The
+// whole reply is flushed once, after its last element is written.
+func (e *Encoder) WriteValue(v interface{}) error {
+	e.nested++
+	err := e.writeValue(v)
+	e.nested--
+	if err != nil {
+		return err
+	}
+	return e.flush()
+}
+
+// writeValue writes v without flushing
+func (e *Encoder) writeValue(v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		return e.WriteNull()
+	case Marshaler:
+		return v.MarshalRESP(e)
+	case string:
+		return e.WriteBulkString(v)
+	case []byte:
+		return e.WriteBulk(v)
+	case int:
+		return e.WriteInteger(int64(v))
+	case int64:
+		return e.WriteInteger(v)
+	case bool:
+		return e.WriteBoolean(v)
+	case float64:
+		return e.WriteDouble(v)
+	case error:
+		return e.WriteError(v.Error())
+	case []string:
+		return e.WriteArray(v)
+	case []interface{}:
+		if err := e.WriteArrayHeader(len(v)); err != nil {
+			return err
+		}
+		return e.WriteValues(v)
+	default:
+		// Keep the stream well framed by replying with an error in place
+		return e.WriteError(fmt.Sprintf("ERR unknown response type %T", v))
+	}
+}
+
+// WriteValues writes each of vals in order with WriteValue. It is meant for
+// the elements of an aggregate whose header has already been written.
+func (e *Encoder) WriteValues(vals []interface{}) error {
+	for _, v := range vals {
+		if err := e.WriteValue(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
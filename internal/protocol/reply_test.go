@@ -0,0 +1,77 @@
+package protocol
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type pair struct{ a, b string }
+
+func (p pair) MarshalRESP(e *Encoder) error {
+	if err := e.WriteArrayHeader(2); err != nil {
+		return err
+	}
+	return e.WriteValues([]interface{}{p.a, p.b})
+}
+
+func TestEncoder_WriteValueNested(t *testing.T) {
+	buf := &bytes.Buffer{}
+	enc := NewEncoder(buf)
+
+	err := enc.WriteValue([]interface{}{
+		"foo",
+		int64(42),
+		nil,
+		errors.New("ERR boom"),
+		[]interface{}{1, []string{"a"}, []interface{}{}},
+		pair{"x", "y"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "*6\r\n"+
+		"$3\r\nfoo\r\n"+
+		":42\r\n"+
+		"$-1\r\n"+
+		"-ERR boom\r\n"+
+		"*3\r\n:1\r\n*1\r\n$1\r\na\r\n*0\r\n"+
+		"*2\r\n$1\r\nx\r\n$1\r\ny\r\n", buf.String())
+}
+
+func TestEncoder_WriteValueRESP3Scalars(t *testing.T) {
+	buf := &bytes.Buffer{}
+	enc := NewEncoder(buf)
+	enc.SetProtocol(3)
+
+	err := enc.WriteValue([]interface{}{nil, true, 1.5, []byte("bin")})
+	assert.NoError(t, err)
+	assert.Equal(t, "*4\r\n_\r\n#t\r\n,1.5\r\n$3\r\nbin\r\n", buf.String())
+}
+
+func TestEncoder_WriteValueUnknownType(t *testing.T) {
+	buf := &bytes.Buffer{}
+	enc := NewEncoder(buf)
+
+	err := enc.WriteValue([]interface{}{struct{}{}, int64(1)})
+	assert.NoError(t, err)
+	assert.Equal(t, "*2\r\n-ERR unknown response type struct {}\r\n:1\r\n", buf.String())
+}
+
+type countingWriter struct {
+	writes int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return len(p), nil
+}
+
+func TestEncoder_WriteValueFlushesOnce(t *testing.T) {
+	w := &countingWriter{}
+	enc := NewEncoder(w)
+
+	err := enc.WriteValue([]interface{}{"a", "b", []interface{}{int64(1), int64(2)}})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, w.writes)
+}
@@ -0,0 +1,189 @@
+package server
+
+import (
+	"crypto/tls"
+	"math"
+	"net"
+	"os"
+	"time"
+
+	"github.com/Shaso41/Backend-SystemFocus/internal/acl"
+	"github.com/Shaso41/Backend-SystemFocus/internal/config"
+	"github.com/Shaso41/Backend-SystemFocus/internal/protocol"
+)
+
+// params holds the server's configuration parameters
+type params struct {
+	addr           *config.Value[[]string]
+	unixSocket     *config.Value[string]
+	unixSocketPerm *config.Value[int64]
+
+	tlsAddr        *config.Value[string]
+	tlsCertFile    *config.Value[string]
+	tlsKeyFile     *config.Value[string]
+	tlsCAFile      *config.Value[string]
+	tlsAuthClients *config.Value[string]
+
+	requirePass *config.Value[string]
+	aclFile     *config.Value[string]
+
+	maxBulkLen   *config.Value[int64]
+	maxArrayLen  *config.Value[int64]
+	maxInlineLen *config.Value[int64]
+	maxNesting   *config.Value[int64]
+
+	timeout      *config.Value[int64]
+	tcpKeepAlive *config.Value[int64]
+	outputLimits *config.Value[outputLimits]
+	hz           *config.Value[int64]
+	ioModel      *config.Value[string]
+}
+
+// I/O models selected by io-model
+const (
+	ioGoroutines = "goroutines"
+	ioEventLoop  = "eventloop"
+)
+
+// tlsAuthModes maps tls-auth-clients values to the client certificate
+// policy they select
+var tlsAuthModes = map[string]tls.ClientAuthType{
+	"yes":      tls.RequireAndVerifyClientCert,
+	"optional": tls.VerifyClientCertIfGiven,
+	"no":       tls.NoClientCert,
+}
+
+// registerParams registers the server's parameters in c. Hooks that need
+// the running server are attached by New.
+func registerParams(c *config.Config) *params {
+	limits := protocol.DefaultLimits
+	return &params{
+		addr: c.List(config.Spec{Name: "addr",
+			Doc: "Addresses to listen on: host:port, unix:///path or an absolute socket path"}, []string{":6379"}),
+		unixSocket: c.String(config.Spec{Name: "unixsocket",
+			Doc: "Path of a unix socket to listen on as well"}, ""),
+		unixSocketPerm: c.Octal(config.Spec{Name: "unixsocketperm",
+			Doc: "Octal permissions of unix sockets, e.g. 770; 0 follows the umask"}, 0),
+
+		tlsAddr: c.String(config.Spec{Name: "tls-addr",
+			Doc: "TLS listen address (host:port); disabled when empty"}, ""),
+		tlsCertFile: c.String(config.Spec{Name: "tls-cert-file", Mutable: true,
+			Doc: "PEM server certificate for TLS"}, ""),
+		tlsKeyFile: c.String(config.Spec{Name: "tls-key-file", Mutable: true,
+			Doc: "PEM private key for TLS"}, ""),
+		tlsCAFile: c.String(config.Spec{Name: "tls-ca-cert-file", Mutable: true,
+			Doc: "PEM CA certificates used to verify client certificates"}, ""),
+		tlsAuthClients: c.Enum(config.Spec{Name: "tls-auth-clients", Mutable: true,
+			Doc: "Require client certificates: yes, no or optional"}, "yes", "yes", "no", "optional"),
+
+		requirePass: c.String(config.Spec{Name: "requirepass", Mutable: true,
+			Doc: "Password clients must AUTH with as the default user"}, ""),
+		aclFile: c.String(config.Spec{Name: "aclfile",
+			Doc: "File to load ACL users from at startup"}, ""),
+
+		maxBulkLen: c.Memory(config.Spec{Name: "proto-max-bulk-len", Mutable: true,
+			Doc: "Largest accepted bulk string in bytes; 0 for no limit"}, int64(limits.MaxBulkLen), 0, math.MaxInt32),
+		maxArrayLen: c.Int(config.Spec{Name: "proto-max-multibulk-len", Mutable: true,
+			Doc: "Largest accepted number of array elements; 0 for no limit"}, int64(limits.MaxArrayLen), 0, math.MaxInt32),
+		maxInlineLen: c.Memory(config.Spec{Name: "proto-max-inline-len", Mutable: true,
+			Doc: "Longest accepted inline command in bytes; 0 for no limit"}, int64(limits.MaxInlineLen), 0, math.MaxInt32),
+		maxNesting: c.Int(config.Spec{Name: "proto-max-nesting", Mutable: true,
+			Doc: "Deepest accepted nesting of arrays; 0 for no limit"}, int64(limits.MaxDepth), 0, 1024),
+
+		timeout: c.Int(config.Spec{Name: "timeout", Mutable: true,
+			Doc: "Close connections idle for this many seconds; 0 to never close them"}, 0, 0, math.MaxInt32),
+		tcpKeepAlive: c.Int(config.Spec{Name: "tcp-keepalive", Mutable: true,
+			Doc: "Seconds between TCP keepalive probes on new connections; 0 to disable them"}, 300, 0, math.MaxInt32),
+		outputLimits: registerOutputLimits(c),
+		hz: c.Int(config.Spec{Name: "hz", Mutable: true,
+			Doc: "How many times a second expired keys are cleaned up"}, 1, 1, 500),
+		ioModel: c.Enum(config.Spec{Name: "io-model",
+			Doc: "How connections are served: goroutines, one per client, or eventloop, an epoll loop feeding a single command executor (Linux only)"},
+			ioGoroutines, ioGoroutines, ioEventLoop),
+	}
+}
+
+// watchParams applies changes to mutable parameters to the running server
+func (s *Server) watchParams() {
+	p := s.params
+
+	p.requirePass.OnChange(func(password string) error {
+		if password == "" {
+			return s.handler.ACL().SetUser(acl.DefaultUser, "nopass")
+		}
+		return s.handler.ACL().SetUser(acl.DefaultUser, "resetpass", ">"+password)
+	})
+
+	reloadTLS := func(string) error {
+		if s.tls == nil {
+			// Checked when the listener starts
+			return nil
+		}
+		return s.tls.reload()
+	}
+	p.tlsCertFile.OnChange(reloadTLS)
+	p.tlsKeyFile.OnChange(reloadTLS)
+	p.tlsCAFile.OnChange(reloadTLS)
+	p.tlsAuthClients.OnChange(reloadTLS)
+
+	p.hz.OnChange(func(hz int64) error {
+		s.store.SetCleanupInterval(time.Second / time.Duration(hz))
+		return nil
+	})
+}
+
+// tlsOptions returns the configured TLS certificates
+func (s *Server) tlsOptions() TLSOptions {
+	p := s.params
+	return TLSOptions{
+		CertFile:   p.tlsCertFile.Get(),
+		KeyFile:    p.tlsKeyFile.Get(),
+		CAFile:     p.tlsCAFile.Get(),
+		ClientAuth: tlsAuthModes[p.tlsAuthClients.Get()],
+	}
+}
+
+// protocolLimits returns the configured request size limits
+func (s *Server) protocolLimits() protocol.Limits {
+	p := s.params
+	return protocol.Limits{
+		MaxBulkLen:   int(p.maxBulkLen.Get()),
+		MaxArrayLen:  int(p.maxArrayLen.Get()),
+		MaxInlineLen: int(p.maxInlineLen.Get()),
+		MaxDepth:     int(p.maxNesting.Get()),
+	}
+}
+
+// idleDeadline returns when a connection idle from now on times out, or
+// the zero time when idle connections are kept
+func (s *Server) idleDeadline(now time.Time) time.Time {
+	timeout := s.params.timeout.Get()
+	if timeout == 0 {
+		return time.Time{}
+	}
+	return now.Add(time.Duration(timeout) * time.Second)
+}
+
+// setKeepAlive applies tcp-keepalive to a newly accepted TCP connection
+func (s *Server) setKeepAlive(conn net.Conn) {
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		conn = tlsConn.NetConn()
+	}
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return
+	}
+
+	period := s.params.tcpKeepAlive.Get()
+	if period == 0 {
+		tcpConn.SetKeepAlive(false)
+		return
+	}
+	tcpConn.SetKeepAlive(true)
+	tcpConn.SetKeepAlivePeriod(time.Duration(period) * time.Second)
+}
+
+// unixSocketPerm returns the configured permissions of unix sockets
+func (s *Server) unixSocketPerm() os.FileMode {
+	return os.FileMode(s.params.unixSocketPerm.Get())
+}
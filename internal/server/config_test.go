@@ -0,0 +1,134 @@
+package server
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_ConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "redis.conf")
+	require.NoError(t, os.WriteFile(path, []byte("# test server\nrequirepass secret\ntimeout 0\n"), 0o600))
+
+	srv := New("localhost:16398", WithConfigFile(path))
+	go srv.Start()
+	time.Sleep(100 * time.Millisecond)
+	defer srv.Stop()
+
+	send := func(conn net.Conn, reader *bufio.Reader, command string) string {
+		_, err := conn.Write([]byte(command + "\r\n"))
+		require.NoError(t, err)
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		return line
+	}
+
+	conn, err := net.Dial("tcp", "localhost:16398")
+	require.NoError(t, err)
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	assert.Equal(t, "-NOAUTH Authentication required.\r\n", send(conn, reader, "PING"))
+	assert.Equal(t, "+OK\r\n", send(conn, reader, "AUTH secret"))
+	assert.Equal(t, "*2\r\n", send(conn, reader, "CONFIG GET timeout"))
+	var reply []string
+	for i := 0; i < 4; i++ {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		reply = append(reply, line)
+	}
+	assert.Equal(t, []string{"$7\r\n", "timeout\r\n", "$1\r\n", "0\r\n"}, reply)
+
+	// Changes apply to established connections right away
+	assert.Equal(t, "+OK\r\n", send(conn, reader, "CONFIG SET proto-max-inline-len 64 requirepass \"\""))
+	assert.Equal(t, "-ERR Protocol error: too big inline request\r\n", send(conn, reader, "ECHO "+string(make([]byte, 100))))
+	conn.Close()
+
+	conn, err = net.Dial("tcp", "localhost:16398")
+	require.NoError(t, err)
+	defer conn.Close()
+	reader = bufio.NewReader(conn)
+	assert.Equal(t, "+PONG\r\n", send(conn, reader, "PING"))
+
+	assert.Equal(t, "-ERR CONFIG SET failed (possibly related to argument 'addr') - can't set immutable config\r\n",
+		send(conn, reader, "CONFIG SET addr :1234"))
+	assert.Equal(t, "-ERR CONFIG SET failed (possibly related to argument 'hz') - argument must be between 1 and 500 inclusive\r\n",
+		send(conn, reader, "CONFIG SET hz 0"))
+
+	assert.Equal(t, "+OK\r\n", send(conn, reader, "CONFIG SET hz 10"))
+	assert.Equal(t, "+OK\r\n", send(conn, reader, "CONFIG REWRITE"))
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "# test server\nrequirepass \"\"\ntimeout 0\n"+
+		"# Generated by CONFIG REWRITE\naddr localhost:16398\nproto-max-inline-len 64\nhz 10\n", string(content))
+}
+
+func TestServer_InvalidConfig(t *testing.T) {
+	err := New("localhost:0", WithConfigValue("timeout", "-1")).Start()
+	assert.EqualError(t, err, "invalid configuration: timeout: argument must be between 0 and 2147483647 inclusive")
+
+	err = New("localhost:0", WithConfigFile(filepath.Join(t.TempDir(), "missing.conf"))).Start()
+	assert.Error(t, err)
+}
+
+func TestServer_MaxClientsAndTimeout(t *testing.T) {
+	srv := New("localhost:16400", WithConfigValue("maxclients", "1"), WithConfigValue("timeout", "1"))
+	go srv.Start()
+	time.Sleep(100 * time.Millisecond)
+	defer srv.Stop()
+
+	first, err := net.Dial("tcp", "localhost:16400")
+	require.NoError(t, err)
+	defer first.Close()
+	firstReader := bufio.NewReader(first)
+	_, err = first.Write([]byte("PING\r\n"))
+	require.NoError(t, err)
+	first.SetReadDeadline(time.Now().Add(3 * time.Second))
+	line, err := firstReader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "+PONG\r\n", line)
+
+	// Over the limit, clients get an error and are disconnected
+	second, err := net.Dial("tcp", "localhost:16400")
+	require.NoError(t, err)
+	defer second.Close()
+	second.SetReadDeadline(time.Now().Add(2 * time.Second))
+	secondReader := bufio.NewReader(second)
+	line, err = secondReader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "-ERR max number of clients reached\r\n", line)
+	_, err = secondReader.ReadString('\n')
+	assert.Equal(t, io.EOF, err)
+
+	// The first client is dropped after a second of idleness
+	_, err = firstReader.ReadString('\n')
+	assert.Equal(t, io.EOF, err)
+
+	third, err := net.Dial("tcp", "localhost:16400")
+	require.NoError(t, err)
+	defer third.Close()
+	_, err = third.Write([]byte("INFO\r\n"))
+	require.NoError(t, err)
+	third.SetReadDeadline(time.Now().Add(2 * time.Second))
+	thirdReader := bufio.NewReader(third)
+	header, err := thirdReader.ReadString('\n')
+	require.NoError(t, err)
+	n, err := strconv.Atoi(strings.TrimSpace(header[1:]))
+	require.NoError(t, err)
+	info := make([]byte, n)
+	_, err = io.ReadFull(thirdReader, info)
+	require.NoError(t, err)
+	assert.Contains(t, string(info), "connected_clients:1\r\nmaxclients:1\r\n")
+	assert.Contains(t, string(info), "total_connections_received:2\r\n")
+	assert.Contains(t, string(info), "rejected_connections:1\r\n")
+}
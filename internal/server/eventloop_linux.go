@@ -0,0 +1,635 @@
+//go:build linux
+
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/Shaso41/Backend-SystemFocus/internal/commands"
+	"github.com/Shaso41/Backend-SystemFocus/internal/protocol"
+)
+
+const (
+	// maxEvents is the most socket events taken from epoll at once
+	maxEvents = 256
+
+	// readChunk is the most read from a socket for one event
+	readChunk = 64 * 1024
+
+	// maxQueryBuffer is the most a client may send of a request before it
+	// is complete, as Redis's default client-query-buffer-limit
+	maxQueryBuffer = 1 << 30
+
+	// loopTick is how often the loop checks idle timeouts and soft output
+	// buffer limits
+	loopTick = 100 * time.Millisecond
+)
+
+// Kinds of loopEvent
+const (
+	eventAdd = iota
+	eventReply
+	eventKill
+)
+
+// errNeedMore is returned by frameFeed once the requests it was given are
+// used up
+var errNeedMore = errors.New("need more input")
+
+// eventLoop serves plain connections from one goroutine polling their
+// sockets with epoll. Whole requests are handed to the server's executor,
+// which runs the commands of every client one at a time, and the replies
+// it posts back are written out by the loop without blocking.
+type eventLoop struct {
+	s    *Server
+	epfd int
+	wake [2]int // pipe that wakes the loop for posted events
+
+	mu       sync.Mutex
+	posted   []loopEvent
+	sleeping bool // waiting in epoll, so posting must wake it
+	closed   bool
+
+	// Owned by the loop goroutine
+	conns map[int]*loopConn
+}
+
+// loopEvent is work for the loop posted by other goroutines: a connection
+// to start polling, replies to send, or a connection to close
+type loopEvent struct {
+	kind  int
+	conn  *loopConn
+	data  []byte
+	close bool // close once data is sent
+}
+
+// loopConn is a connection served by the event loop. Its socket and
+// buffers belong to the loop goroutine and its session and parser to the
+// executor.
+type loopConn struct {
+	loop *eventLoop
+	fd   int
+	addr string
+
+	// Owned by the loop goroutine
+	in       []byte
+	scanner  protocol.FrameScanner // how far in has been scanned
+	out      output
+	lastRead time.Time
+	added    bool // polled by epoll
+	writing  bool // polled for writability too
+	closing  bool // closed once out is sent
+	killed   bool // closed before it was added
+	gone     bool
+
+	// Owned by the executor
+	sess        *commands.Session
+	feed        frameFeed
+	parser      *protocol.Parser
+	replies     bytes.Buffer
+	encoder     *protocol.Encoder
+	closed      bool // no more commands are run
+	registered  bool
+	held        [][]byte // command set aside while clients are paused
+	parked      bool     // waiting for the pause to end
+	monitorDone chan struct{}
+
+	// Exempts monitoring clients from the idle timeout and puts them in
+	// the pubsub class
+	monitoring atomic.Bool
+
+	// Replies not sent yet, for CLIENT LIST
+	outLen atomic.Int64
+}
+
+// frameFeed hands the parser the whole requests received so far and fails
+// with errNeedMore once they are used up, so reading a command never waits
+// on the socket
+type frameFeed struct {
+	buf []byte
+}
+
+func (f *frameFeed) Read(p []byte) (int, error) {
+	if len(f.buf) == 0 {
+		return 0, errNeedMore
+	}
+	n := copy(p, f.buf)
+	f.buf = f.buf[n:]
+	return n, nil
+}
+
+// newEventLoop creates the epoll instance and wakeup pipe of an event loop
+func newEventLoop(s *Server) (*eventLoop, error) {
+	epfd, err := syscall.EpollCreate1(syscall.EPOLL_CLOEXEC)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create epoll instance: %w", err)
+	}
+	l := &eventLoop{s: s, epfd: epfd, conns: make(map[int]*loopConn)}
+	if err := syscall.Pipe2(l.wake[:], syscall.O_NONBLOCK|syscall.O_CLOEXEC); err != nil {
+		syscall.Close(epfd)
+		return nil, fmt.Errorf("failed to create wakeup pipe: %w", err)
+	}
+	if err := l.ctl(syscall.EPOLL_CTL_ADD, l.wake[0], syscall.EPOLLIN); err != nil {
+		l.close()
+		return nil, fmt.Errorf("failed to poll wakeup pipe: %w", err)
+	}
+	return l, nil
+}
+
+// ctl adds, changes or removes the events polled for fd
+func (l *eventLoop) ctl(op, fd int, events uint32) error {
+	return syscall.EpollCtl(l.epfd, op, fd, &syscall.EpollEvent{Events: events, Fd: int32(fd)})
+}
+
+// add takes over an accepted connection, registering its client and
+// handing its socket to the loop
+func (l *eventLoop) add(conn net.Conn) {
+	addr := clientAddr(conn)
+	log.Printf("✅ New client connected: %s", addr)
+
+	sess := l.s.handler.NewSession()
+	sess.Addr = addr
+	sess.LocalAddr = conn.LocalAddr().String()
+
+	fd, err := detach(conn)
+	if err != nil {
+		log.Printf("❌ Failed to serve client %s from the event loop: %v", addr, err)
+		return
+	}
+
+	c := &loopConn{loop: l, fd: fd, addr: addr, sess: sess, out: output{class: classNormal}}
+	c.parser = protocol.NewParser(&c.feed)
+	c.encoder = protocol.NewEncoder(&c.replies)
+	c.encoder.SetAutoFlush(false)
+
+	if err := l.s.handler.Register(sess, c); err != nil {
+		log.Printf("❌ Refused client %s: %v", addr, err)
+		syscall.Write(fd, []byte("-"+err.Error()+"\r\n"))
+		syscall.Close(fd)
+		return
+	}
+	c.registered = true
+
+	if !l.post(loopEvent{kind: eventAdd, conn: c}) {
+		syscall.Close(fd)
+		l.s.handler.Unregister(sess)
+	}
+}
+
+// detach takes over the socket of conn as a non-blocking file descriptor
+// that the loop reads and writes directly, and closes conn itself
+func detach(conn net.Conn) (int, error) {
+	defer conn.Close()
+
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return -1, fmt.Errorf("%T has no file descriptor", conn)
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return -1, err
+	}
+
+	fd, dupErr := -1, error(nil)
+	err = raw.Control(func(f uintptr) {
+		fd, dupErr = syscall.Dup(int(f))
+	})
+	if err == nil {
+		err = dupErr
+	}
+	if err != nil {
+		return -1, err
+	}
+
+	syscall.CloseOnExec(fd)
+	if err := syscall.SetNonblock(fd, true); err != nil {
+		syscall.Close(fd)
+		return -1, err
+	}
+	return fd, nil
+}
+
+// post queues an event for the loop goroutine, waking it if needed. It
+// reports false once the loop has stopped.
+func (l *eventLoop) post(ev loopEvent) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return false
+	}
+	// A loop that is awake takes the event before it next waits
+	if l.sleeping {
+		syscall.Write(l.wake[1], []byte{0})
+		l.sleeping = false
+	}
+	l.posted = append(l.posted, ev)
+	return true
+}
+
+// wakeup wakes the loop, so that it notices the server was stopped
+func (l *eventLoop) wakeup() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.closed && l.sleeping {
+		syscall.Write(l.wake[1], []byte{0})
+	}
+}
+
+// run polls the connections until the server is stopped, then closes them
+func (l *eventLoop) run() {
+	defer l.close()
+
+	events := make([]syscall.EpollEvent, maxEvents)
+	buf := make([]byte, readChunk)
+	nextTick := time.Now().Add(loopTick)
+	for {
+		n, err := l.wait(events)
+		if err != nil && err != syscall.EINTR {
+			log.Printf("❌ Event loop failed: %v", err)
+			return
+		}
+		select {
+		case <-l.s.stopCh:
+			return
+		default:
+		}
+
+		for _, ev := range events[:max(n, 0)] {
+			fd := int(ev.Fd)
+			if fd == l.wake[0] {
+				l.drainWakeups(buf)
+				continue
+			}
+			c, ok := l.conns[fd]
+			if !ok {
+				continue
+			}
+			if ev.Events&(syscall.EPOLLIN|syscall.EPOLLERR|syscall.EPOLLHUP) != 0 {
+				l.read(c, buf)
+			}
+			if ev.Events&syscall.EPOLLOUT != 0 && !c.gone {
+				l.flush(c)
+			}
+		}
+		l.handlePosted()
+
+		if now := time.Now(); !now.Before(nextTick) {
+			l.tick(now)
+			nextTick = now.Add(loopTick)
+		}
+	}
+}
+
+// wait waits for socket events or posted events. It first polls without
+// blocking, and lets the executor run the requests just handed to it
+// before blocking, since a goroutine blocked in epoll holds on to its
+// thread until the runtime notices.
+func (l *eventLoop) wait(events []syscall.EpollEvent) (int, error) {
+	n, err := syscall.EpollWait(l.epfd, events, 0)
+	if n != 0 || err != nil {
+		return n, err
+	}
+	runtime.Gosched()
+
+	l.mu.Lock()
+	if len(l.posted) > 0 {
+		l.mu.Unlock()
+		return 0, nil
+	}
+	l.sleeping = true
+	l.mu.Unlock()
+
+	n, err = syscall.EpollWait(l.epfd, events, int(loopTick/time.Millisecond))
+
+	l.mu.Lock()
+	l.sleeping = false
+	l.mu.Unlock()
+	return n, err
+}
+
+// drainWakeups empties the wakeup pipe
+func (l *eventLoop) drainWakeups(buf []byte) {
+	for {
+		n, err := syscall.Read(l.wake[0], buf)
+		if n <= 0 && err != syscall.EINTR {
+			return
+		}
+	}
+}
+
+// handlePosted handles the events posted since it last ran
+func (l *eventLoop) handlePosted() {
+	l.mu.Lock()
+	posted := l.posted
+	l.posted = nil
+	l.mu.Unlock()
+
+	for _, ev := range posted {
+		c := ev.conn
+		switch ev.kind {
+		case eventAdd:
+			if c.killed {
+				l.closeConn(c)
+				continue
+			}
+			if err := l.ctl(syscall.EPOLL_CTL_ADD, c.fd, syscall.EPOLLIN); err != nil {
+				log.Printf("❌ Failed to poll client %s: %v", c.addr, err)
+				l.closeConn(c)
+				continue
+			}
+			c.added, c.lastRead = true, time.Now()
+			l.conns[c.fd] = c
+
+		case eventReply:
+			if c.gone {
+				continue
+			}
+			if c.monitoring.Load() {
+				c.out.class = classPubSub
+			}
+			c.out.limit = l.s.params.outputLimits.Get()[c.out.class]
+			if _, err := c.out.Write(ev.data); err != nil {
+				logWriteError(c.addr, err)
+				l.closeConn(c)
+				continue
+			}
+			c.closing = c.closing || ev.close
+			l.flush(c)
+
+		case eventKill:
+			if !c.added {
+				c.killed = true
+				continue
+			}
+			l.closeConn(c)
+		}
+	}
+}
+
+// read reads what the client sent and hands any whole requests to the
+// executor
+func (l *eventLoop) read(c *loopConn, buf []byte) {
+	n, err := syscall.Read(c.fd, buf)
+	if err == syscall.EAGAIN || err == syscall.EINTR {
+		return
+	}
+	if err != nil || n == 0 {
+		l.closeConn(c)
+		return
+	}
+	if c.closing {
+		// Requests after QUIT or CLIENT KILL of itself are ignored
+		return
+	}
+	c.lastRead = time.Now()
+
+	data := buf[:n]
+	if len(c.in) > 0 {
+		c.in = append(c.in, data...)
+		data = c.in
+	}
+	end := c.scanner.Scan(data, l.s.protocolLimits())
+
+	if end > 0 {
+		frames := bytes.Clone(data[:end])
+		if !l.s.exec.submit(func() { c.execute(frames) }) {
+			return
+		}
+	}
+	c.in = append(c.in[:0], data[end:]...)
+	if len(c.in) > maxQueryBuffer {
+		log.Printf("❌ Closing client %s for overcoming the query buffer limit", c.addr)
+		l.closeConn(c)
+	}
+}
+
+// flush writes as much of the buffered replies as the socket takes,
+// polling for writability while some remain
+func (l *eventLoop) flush(c *loopConn) {
+	for c.out.Len() > 0 {
+		n, err := syscall.Write(c.fd, c.out.buf[:min(c.out.Len(), outputChunk)])
+		if err == syscall.EINTR {
+			continue
+		}
+		if err == syscall.EAGAIN {
+			break
+		}
+		if err != nil {
+			logWriteError(c.addr, err)
+			l.closeConn(c)
+			return
+		}
+		c.out.buf = c.out.buf[n:]
+	}
+	c.outLen.Store(int64(c.out.Len()))
+
+	pending := c.out.Len() > 0
+	if !pending {
+		c.out.reset()
+		if c.closing {
+			l.closeConn(c)
+			return
+		}
+	}
+	if pending != c.writing {
+		events := uint32(syscall.EPOLLIN)
+		if pending {
+			events |= syscall.EPOLLOUT
+		}
+		if err := l.ctl(syscall.EPOLL_CTL_MOD, c.fd, events); err != nil {
+			log.Printf("❌ Failed to poll client %s: %v", c.addr, err)
+			l.closeConn(c)
+			return
+		}
+		c.writing = pending
+	}
+}
+
+// tick closes connections idle for longer than the timeout, and those
+// whose unsent replies stayed over the soft output limit for too long
+func (l *eventLoop) tick(now time.Time) {
+	timeout := time.Duration(l.s.params.timeout.Get()) * time.Second
+	for _, c := range l.conns {
+		if timeout > 0 && !c.monitoring.Load() && now.Sub(c.lastRead) > timeout {
+			l.closeConn(c)
+			continue
+		}
+		if c.out.Len() > 0 {
+			if err := c.out.check(c.out.Len()); err != nil {
+				logWriteError(c.addr, err)
+				l.closeConn(c)
+			}
+		}
+	}
+}
+
+// closeConn closes a connection and has the executor unregister its client
+func (l *eventLoop) closeConn(c *loopConn) {
+	if c.gone {
+		return
+	}
+	c.gone = true
+	if c.added {
+		syscall.EpollCtl(l.epfd, syscall.EPOLL_CTL_DEL, c.fd, nil)
+		delete(l.conns, c.fd)
+	}
+	syscall.Close(c.fd)
+	l.s.exec.submit(c.unregister)
+	log.Printf("👋 Client disconnected: %s", c.addr)
+}
+
+// close closes every connection and the loop's own descriptors
+func (l *eventLoop) close() {
+	l.mu.Lock()
+	l.closed = true
+	posted := l.posted
+	l.posted = nil
+	l.mu.Unlock()
+
+	for _, ev := range posted {
+		if ev.kind == eventAdd {
+			l.closeConn(ev.conn)
+		}
+	}
+	for _, c := range l.conns {
+		l.closeConn(c)
+	}
+	syscall.Close(l.epfd)
+	syscall.Close(l.wake[0])
+	syscall.Close(l.wake[1])
+}
+
+// Close closes the connection when its client is killed. It is called by
+// the executor, so the loop closes the socket later.
+func (c *loopConn) Close() error {
+	c.loop.post(loopEvent{kind: eventKill, conn: c})
+	return nil
+}
+
+// execute queues the whole requests in frames and runs them on the
+// executor, unless the connection is waiting for a pause to end
+func (c *loopConn) execute(frames []byte) {
+	if c.closed {
+		return
+	}
+	c.feed.buf = append(c.feed.buf, frames...)
+	if !c.parked {
+		c.run()
+		return
+	}
+	if len(c.feed.buf) > maxQueryBuffer {
+		log.Printf("❌ Closing client %s for overcoming the query buffer limit", c.addr)
+		c.loop.post(loopEvent{kind: eventKill, conn: c})
+		c.unregister()
+	}
+}
+
+// resume runs the requests held up by a pause once it is over
+func (c *loopConn) resume() {
+	c.parked = false
+	if !c.closed {
+		c.run()
+	}
+}
+
+// run runs the queued requests and posts the replies back to the loop. A
+// command held up by CLIENT PAUSE is set aside with the requests after
+// it, and the connection parked until the pause ends, since waiting would
+// hold up the executor and every other client with it.
+func (c *loopConn) run() {
+	s := c.loop.s
+	for !c.closed {
+		args := c.held
+		c.held = nil
+		if args == nil {
+			c.parser.SetLimits(s.protocolLimits())
+			var err error
+			args, err = c.parser.ReadCommand()
+			if err == errNeedMore {
+				break
+			}
+			if err != nil {
+				log.Printf("❌ Parse error from %s: %v", c.addr, err)
+				c.encoder.WriteError(fmt.Sprintf("ERR %v", err))
+				c.closed = true
+				break
+			}
+		}
+
+		if resume := s.handler.PausedUntil(c.sess, args); resume != nil {
+			c.held, c.parked = args, true
+			go func() {
+				<-resume
+				s.exec.submit(c.resume)
+			}()
+			break
+		}
+
+		c.sess.Track(time.Now(), len(c.feed.buf), 0,
+			c.replies.Len()+c.encoder.Buffered()+int(c.outLen.Load()))
+		s.execute(c.encoder, c.sess, args)
+		c.closed = c.sess.CloseAfterReply()
+	}
+	c.encoder.Flush()
+
+	if m := c.sess.Monitor(); m != nil && c.monitorDone == nil {
+		c.monitorDone = make(chan struct{})
+		c.monitoring.Store(true)
+		m.SetLimit(s.params.outputLimits.Get()[classPubSub].hard)
+		go c.streamMonitor(m, c.monitorDone)
+	}
+
+	if c.replies.Len() > 0 || c.closed {
+		c.loop.post(loopEvent{kind: eventReply, conn: c, data: bytes.Clone(c.replies.Bytes()), close: c.closed})
+		c.replies.Reset()
+	}
+	if c.closed {
+		c.unregister()
+	}
+}
+
+// unregister removes the connection's client once it is closed
+func (c *loopConn) unregister() {
+	c.closed = true
+	if !c.registered {
+		return
+	}
+	c.registered = false
+	if c.monitorDone != nil {
+		close(c.monitorDone)
+	}
+	c.loop.s.handler.Unregister(c.sess)
+}
+
+// streamMonitor posts the commands queued for a client that ran MONITOR
+// to the loop until done is closed
+func (c *loopConn) streamMonitor(monitor *commands.Monitor, done <-chan struct{}) {
+	var lines []string
+	var buf bytes.Buffer
+	encoder := protocol.NewEncoder(&buf)
+	for {
+		select {
+		case <-monitor.Ready():
+		case <-done:
+			return
+		}
+
+		lines = monitor.Take(lines[:0])
+		for _, line := range lines {
+			encoder.WriteSimpleString(line)
+		}
+		c.loop.post(loopEvent{kind: eventReply, conn: c, data: bytes.Clone(buf.Bytes())})
+		buf.Reset()
+	}
+}
@@ -0,0 +1,422 @@
+//go:build linux
+
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Shaso41/Backend-SystemFocus/internal/tlstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readBulk reads a bulk string reply
+func readBulk(t *testing.T, reader *bufio.Reader) []byte {
+	header, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, byte('$'), header[0], header)
+	n, err := strconv.Atoi(strings.TrimSpace(header[1:]))
+	require.NoError(t, err)
+	data := make([]byte, n+2)
+	_, err = io.ReadFull(reader, data)
+	require.NoError(t, err)
+	return data[:n]
+}
+
+func TestServer_EventLoop(t *testing.T) {
+	srv := New("localhost:16404", WithConfigValue("io-model", "eventloop"))
+	go srv.Start()
+	time.Sleep(100 * time.Millisecond)
+	defer srv.Stop()
+
+	conn, err := net.Dial("tcp", "localhost:16404")
+	require.NoError(t, err)
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// Pipelined commands are answered without waiting for the rest of an
+	// incomplete one
+	var batch strings.Builder
+	for i := 0; i < 100; i++ {
+		batch.WriteString("*1\r\n$4\r\nPING\r\n")
+	}
+	batch.WriteString("*1\r\n$4\r\nPI")
+	_, err = conn.Write([]byte(batch.String()))
+	require.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, "+PONG\r\n", line)
+	}
+	_, err = conn.Write([]byte("NG\r\n"))
+	require.NoError(t, err)
+	line, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "+PONG\r\n", line)
+
+	// Values larger than a socket buffer take several reads and writes
+	value := bytes.Repeat([]byte("0123456789"), 800*1024)
+	_, err = fmt.Fprintf(conn, "*3\r\n$3\r\nSET\r\n$3\r\nbig\r\n$%d\r\n%s\r\n", len(value), value)
+	require.NoError(t, err)
+	line, err = reader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "+OK\r\n", line)
+	_, err = conn.Write([]byte("GET big\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, value, readBulk(t, reader))
+
+	// Protocol errors close the connection after the error reply
+	_, err = conn.Write([]byte("*1\r\n$x\r\n"))
+	require.NoError(t, err)
+	line, err = reader.ReadString('\n')
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(line, "-ERR Protocol error"), line)
+	_, err = reader.ReadString('\n')
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestServer_EventLoopProtocolLimits(t *testing.T) {
+	srv := New("localhost:16414", WithConfigValue("io-model", "eventloop"))
+	go srv.Start()
+	time.Sleep(100 * time.Millisecond)
+	defer srv.Stop()
+
+	// Requests over the limits are refused as soon as their header
+	// arrives, rather than buffered until complete
+	for _, request := range []string{
+		"*2\r\n$3\r\nGET\r\n$2000000000\r\n",
+		strings.Repeat("*1\r\n", 100),
+	} {
+		conn, err := net.Dial("tcp", "localhost:16414")
+		require.NoError(t, err)
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(2 * time.Second))
+		reader := bufio.NewReader(conn)
+
+		_, err = conn.Write([]byte(request))
+		require.NoError(t, err)
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(line, "-ERR Protocol error"), line)
+		_, err = reader.ReadString('\n')
+		assert.Equal(t, io.EOF, err)
+	}
+}
+
+func TestServer_EventLoopSerializesClients(t *testing.T) {
+	srv := New("localhost:16405", WithConfigValue("io-model", "eventloop"))
+	go srv.Start()
+	time.Sleep(100 * time.Millisecond)
+	defer srv.Stop()
+
+	// Each client sets its own bits of a shared string, which read-modify-
+	// write commands only get right when they do not interleave
+	const clients, bits = 20, 50
+	var wg sync.WaitGroup
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			conn, err := net.Dial("tcp", "localhost:16405")
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer conn.Close()
+			conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+			var batch strings.Builder
+			for j := 0; j < bits; j++ {
+				fmt.Fprintf(&batch, "SETBIT shared %d 1\r\n", i*bits+j)
+			}
+			_, err = conn.Write([]byte(batch.String()))
+			if !assert.NoError(t, err) {
+				return
+			}
+			reader := bufio.NewReader(conn)
+			for j := 0; j < bits; j++ {
+				line, err := reader.ReadString('\n')
+				if !assert.NoError(t, err) || !assert.Equal(t, ":0\r\n", line) {
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	conn, err := net.Dial("tcp", "localhost:16405")
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+	_, err = conn.Write([]byte("BITCOUNT shared\r\n"))
+	require.NoError(t, err)
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf(":%d\r\n", clients*bits), line)
+}
+
+func TestServer_EventLoopOwnsStore(t *testing.T) {
+	srv := New("localhost:16417", WithConfigValue("io-model", "eventloop"),
+		WithConfigValue("hz", "100"))
+	go srv.Start()
+	time.Sleep(100 * time.Millisecond)
+	defer srv.Stop()
+
+	conn, err := net.Dial("tcp", "localhost:16417")
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	reader := bufio.NewReader(conn)
+
+	// Enough keys to resize the tables, half of them soon expiring, so
+	// that the cleanup and rehashing run as executor jobs between commands
+	const keys = 5000
+	var batch strings.Builder
+	for i := 0; i < keys; i++ {
+		if i%2 == 0 {
+			fmt.Fprintf(&batch, "SET key:%d v EX 1\r\n", i)
+		} else {
+			fmt.Fprintf(&batch, "SET key:%d v\r\n", i)
+		}
+	}
+	_, err = conn.Write([]byte(batch.String()))
+	require.NoError(t, err)
+	for i := 0; i < keys; i++ {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		require.Equal(t, "+OK\r\n", line)
+	}
+
+	metrics := func() string {
+		rec := httptest.NewRecorder()
+		srv.MetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+		require.Equal(t, 200, rec.Code)
+		return rec.Body.String()
+	}
+	assert.Eventually(t, func() bool {
+		return strings.Contains(metrics(), fmt.Sprintf("redis_expired_keys_total %d\n", keys/2))
+	}, 5*time.Second, 50*time.Millisecond)
+	assert.Contains(t, metrics(), fmt.Sprintf(`redis_db_keys{db="db0"} %d`+"\n", keys/2))
+
+	// The cleanup interval changes from a command on the executor, which
+	// the cleanup goroutine itself may be waiting for
+	_, err = conn.Write([]byte("CONFIG SET hz 10\r\nPING\r\n"))
+	require.NoError(t, err)
+	for _, want := range []string{"+OK\r\n", "+PONG\r\n"} {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, want, line)
+	}
+
+	// Once the executor is gone the metrics are no longer served
+	srv.Stop()
+	rec := httptest.NewRecorder()
+	srv.MetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, 503, rec.Code)
+}
+
+func TestServer_EventLoopClients(t *testing.T) {
+	srv := New("localhost:16406", WithConfigValue("io-model", "eventloop"),
+		WithConfigValue("maxclients", "2"), WithConfigValue("timeout", "1"))
+	go srv.Start()
+	time.Sleep(100 * time.Millisecond)
+	defer srv.Stop()
+
+	dial := func() (net.Conn, *bufio.Reader) {
+		conn, err := net.Dial("tcp", "localhost:16406")
+		require.NoError(t, err)
+		conn.SetDeadline(time.Now().Add(3 * time.Second))
+		return conn, bufio.NewReader(conn)
+	}
+	send := func(conn net.Conn, reader *bufio.Reader, command string) string {
+		_, err := conn.Write([]byte(command + "\r\n"))
+		require.NoError(t, err)
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		return line
+	}
+
+	victim, victimReader := dial()
+	defer victim.Close()
+	assert.Equal(t, "+OK\r\n", send(victim, victimReader, "CLIENT SETNAME victim"))
+	admin, adminReader := dial()
+	defer admin.Close()
+	assert.Equal(t, "+PONG\r\n", send(admin, adminReader, "PING"))
+
+	// Over the limit, clients get an error and are disconnected
+	third, thirdReader := dial()
+	defer third.Close()
+	line, err := thirdReader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "-ERR max number of clients reached\r\n", line)
+	_, err = thirdReader.ReadString('\n')
+	assert.Equal(t, io.EOF, err)
+
+	// Killed clients are disconnected
+	assert.Equal(t, ":1\r\n", send(admin, adminReader, "CLIENT KILL ADDR "+victim.LocalAddr().String()))
+	_, err = victimReader.ReadString('\n')
+	assert.Equal(t, io.EOF, err)
+
+	// A client killing itself gets the reply first
+	assert.Equal(t, ":1\r\n", send(admin, adminReader, "CLIENT KILL SKIPME no"))
+	_, err = adminReader.ReadString('\n')
+	assert.Equal(t, io.EOF, err)
+
+	// Idle clients are dropped after the timeout
+	idle, idleReader := dial()
+	defer idle.Close()
+	assert.Equal(t, "+PONG\r\n", send(idle, idleReader, "PING"))
+	start := time.Now()
+	_, err = idleReader.ReadString('\n')
+	assert.Equal(t, io.EOF, err)
+	assert.Greater(t, time.Since(start), 900*time.Millisecond)
+}
+
+func TestServer_EventLoopMonitor(t *testing.T) {
+	srv := New("localhost:16407", WithConfigValue("io-model", "eventloop"))
+	go srv.Start()
+	time.Sleep(100 * time.Millisecond)
+	defer srv.Stop()
+
+	monitor, err := net.Dial("tcp", "localhost:16407")
+	require.NoError(t, err)
+	defer monitor.Close()
+	monitorReader := bufio.NewReader(monitor)
+	monitor.SetReadDeadline(time.Now().Add(3 * time.Second))
+	_, err = monitor.Write([]byte("MONITOR\r\n"))
+	require.NoError(t, err)
+	line, err := monitorReader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "+OK\r\n", line)
+
+	client, err := net.Dial("tcp", "localhost:16407")
+	require.NoError(t, err)
+	defer client.Close()
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, err = client.Write([]byte("SET k v\r\n"))
+	require.NoError(t, err)
+	_, err = bufio.NewReader(client).ReadString('\n')
+	require.NoError(t, err)
+
+	line, err = monitorReader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Contains(t, line, `] "SET" "k" "v"`)
+}
+
+func TestServer_EventLoopMonitorOutputLimit(t *testing.T) {
+	testMonitorOutputLimit(t, "localhost:16419", WithConfigValue("io-model", "eventloop"))
+}
+
+func TestServer_EventLoopPause(t *testing.T) {
+	srv := New("localhost:16413", WithConfigValue("io-model", "eventloop"))
+	go srv.Start()
+	time.Sleep(100 * time.Millisecond)
+	defer srv.Stop()
+
+	dial := func() (net.Conn, *bufio.Reader) {
+		conn, err := net.Dial("tcp", "localhost:16413")
+		require.NoError(t, err)
+		conn.SetDeadline(time.Now().Add(3 * time.Second))
+		return conn, bufio.NewReader(conn)
+	}
+	send := func(conn net.Conn, reader *bufio.Reader, command string) string {
+		_, err := conn.Write([]byte(command + "\r\n"))
+		require.NoError(t, err)
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		return line
+	}
+
+	admin, adminReader := dial()
+	defer admin.Close()
+	writer, writerReader := dial()
+	defer writer.Close()
+	assert.Equal(t, "+OK\r\n", send(admin, adminReader, "SET k old"))
+	assert.Equal(t, "+OK\r\n", send(admin, adminReader, "CLIENT PAUSE 10000 WRITE"))
+
+	// A paused write does not hold up the executor: reads from other
+	// clients, and CLIENT UNPAUSE, run long before the pause would end
+	start := time.Now()
+	_, err := writer.Write([]byte("SET k new\r\nGET k\r\n"))
+	require.NoError(t, err)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, "$3\r\n", send(admin, adminReader, "GET k"))
+	line, err := adminReader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "old\r\n", line)
+	assert.Equal(t, "+OK\r\n", send(admin, adminReader, "CLIENT UNPAUSE"))
+
+	// The paused client then runs its commands in order
+	line, err = writerReader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "+OK\r\n", line)
+	assert.Equal(t, []byte("new"), readBulk(t, writerReader))
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
+
+func TestServer_EventLoopWithTLS(t *testing.T) {
+	files := tlstest.Generate(t, t.TempDir(), 1)
+	srv := New("localhost:16408", WithConfigValue("io-model", "eventloop"),
+		WithTLS("localhost:16409", TLSOptions{
+			CertFile:   files.ServerCert,
+			KeyFile:    files.ServerKey,
+			CAFile:     files.CACert,
+			ClientAuth: tls.RequireAndVerifyClientCert,
+		}))
+	go srv.Start()
+	time.Sleep(100 * time.Millisecond)
+	defer srv.Stop()
+
+	// TLS connections keep their goroutine but run commands on the
+	// executor too
+	reply, _, err := tlsPing("localhost:16409", tlsClientConfig(t, files, true))
+	assert.NoError(t, err)
+	assert.Equal(t, "+PONG\r\n", reply)
+}
+
+// BenchmarkServer_IOModel runs SET from many clients at once against both
+// I/O models. Compare them with -cpu 1,2,4,8.
+func BenchmarkServer_IOModel(b *testing.B) {
+	for i, model := range []string{ioGoroutines, ioEventLoop} {
+		b.Run(model, func(b *testing.B) {
+			address := fmt.Sprintf("localhost:%d", 16410+i)
+			srv := New(address, WithConfigValue("io-model", model))
+			go srv.Start()
+			time.Sleep(100 * time.Millisecond)
+			defer srv.Stop()
+
+			b.SetParallelism(8)
+			b.RunParallel(func(pb *testing.PB) {
+				conn, err := net.Dial("tcp", address)
+				if err != nil {
+					b.Error(err)
+					return
+				}
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				command := []byte(fmt.Sprintf("*3\r\n$3\r\nSET\r\n$8\r\nkey:%04d\r\n$5\r\nvalue\r\n", time.Now().UnixNano()%10000))
+				for pb.Next() {
+					if _, err := conn.Write(command); err != nil {
+						b.Error(err)
+						return
+					}
+					if _, err := reader.ReadString('\n'); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,24 @@
+//go:build !linux
+
+package server
+
+import (
+	"errors"
+	"net"
+)
+
+// eventLoop is only implemented with epoll on Linux
+type eventLoop struct{}
+
+// newEventLoop fails, as the event loop needs epoll
+func newEventLoop(s *Server) (*eventLoop, error) {
+	return nil, errors.New("the event loop I/O model is only supported on Linux")
+}
+
+func (l *eventLoop) add(conn net.Conn) {}
+
+func (l *eventLoop) run() {}
+
+func (l *eventLoop) wakeup() {}
+
+func (l *eventLoop) close() {}
@@ -0,0 +1,80 @@
+package server
+
+// executorQueue is how many jobs may wait for the executor before
+// submitting more blocks
+const executorQueue = 1024
+
+// executor runs the commands of every client on a single goroutine, one
+// job at a time and in the order they were submitted, so that each command
+// sees the effects of all commands before it and none running alongside.
+// The store's background work and the metrics reader run here as well, so
+// the store needs no locks
+type executor struct {
+	jobs    chan func()
+	stopCh  <-chan struct{}
+	stopped chan struct{} // closed once run returns
+}
+
+// newExecutor creates an executor that stops running jobs once stopCh is
+// closed
+func newExecutor(stopCh <-chan struct{}) *executor {
+	return &executor{
+		jobs:    make(chan func(), executorQueue),
+		stopCh:  stopCh,
+		stopped: make(chan struct{}),
+	}
+}
+
+// run runs submitted jobs until the executor is stopped
+func (e *executor) run() {
+	defer close(e.stopped)
+
+	for {
+		select {
+		case job := <-e.jobs:
+			job()
+		case <-e.stopCh:
+			return
+		}
+	}
+}
+
+// submit queues job to run after those submitted before it. It reports
+// false if the executor was stopped, in which case job may never run.
+func (e *executor) submit(job func()) bool {
+	select {
+	case <-e.stopCh:
+		return false
+	default:
+	}
+	select {
+	case e.jobs <- job:
+		return true
+	case <-e.stopCh:
+		return false
+	}
+}
+
+// do runs job on the executor and waits for it to finish. It reports false
+// if the executor stopped without running it.
+func (e *executor) do(job func()) bool {
+	done := make(chan struct{})
+	if !e.submit(func() {
+		job()
+		close(done)
+	}) {
+		return false
+	}
+	select {
+	case <-done:
+		return true
+	case <-e.stopped:
+		// A job that started before the executor stopped has finished
+		select {
+		case <-done:
+			return true
+		default:
+			return false
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecutor(t *testing.T) {
+	stopCh := make(chan struct{})
+	e := newExecutor(stopCh)
+	go e.run()
+
+	// Jobs run one at a time, in the order they were submitted
+	var order []int
+	for i := 0; i < 100; i++ {
+		i := i
+		assert.True(t, e.submit(func() { order = append(order, i) }))
+	}
+
+	// Jobs from many goroutines share state without locks, which the race
+	// detector checks
+	count := 0
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.True(t, e.do(func() { count++ }))
+		}()
+	}
+	wg.Wait()
+	assert.True(t, e.do(func() { assert.Equal(t, 10, count) }))
+	assert.Len(t, order, 100)
+	for i, n := range order {
+		assert.Equal(t, i, n)
+	}
+
+	// Once stopped, jobs are refused
+	close(stopCh)
+	<-e.stopped
+	ran := false
+	assert.False(t, e.do(func() { ran = true }))
+	assert.False(t, ran)
+}
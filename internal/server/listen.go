@@ -0,0 +1,53 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// unixScheme prefixes unix socket addresses, as in unix:///run/redis.sock
+const unixScheme = "unix://"
+
+// splitAddress returns the network and address to listen on for a server
+// address. Addresses of the form unix:///path, and absolute paths, name
+// unix sockets; anything else is a TCP host:port.
+func splitAddress(address string) (network, addr string) {
+	if strings.HasPrefix(address, unixScheme) {
+		return "unix", strings.TrimPrefix(address, unixScheme)
+	}
+	if strings.HasPrefix(address, "/") {
+		return "unix", address
+	}
+	return "tcp", address
+}
+
+// listenOn opens a listener for a server address. A stale socket file left
+// behind by an earlier run is removed first, and the new one gets perm
+// unless it is zero.
+func listenOn(address string, perm os.FileMode) (net.Listener, error) {
+	network, addr := splitAddress(address)
+	if network != "unix" {
+		return net.Listen(network, addr)
+	}
+
+	if info, err := os.Stat(addr); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", addr)
+		}
+		os.Remove(addr)
+	}
+
+	listener, err := net.Listen(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	if perm != 0 {
+		if err := os.Chmod(addr, perm); err != nil {
+			listener.Close()
+			return nil, fmt.Errorf("failed to set unix socket permissions: %w", err)
+		}
+	}
+	return listener, nil
+}
@@ -0,0 +1,173 @@
+package server
+
+import (
+	"bufio"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Shaso41/Backend-SystemFocus/internal/commands"
+)
+
+// latencyBuckets are the upper bounds, in nanoseconds, of the command
+// latency histogram buckets: powers of two from about a microsecond to a
+// second, which the latency histograms count exactly
+var latencyBuckets = func() []int64 {
+	bounds := make([]int64, 21)
+	for i := range bounds {
+		bounds[i] = 1024 << i
+	}
+	return bounds
+}()
+
+// MetricsHandler serves the server's statistics in the Prometheus text
+// exposition format
+func (s *Server) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stats, ok := s.stats()
+		if !ok {
+			http.Error(w, errStopped.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		m := &metricsWriter{w: bufio.NewWriter(w)}
+		s.writeMetrics(m, stats)
+		m.w.Flush()
+	})
+}
+
+// stats returns the handler's statistics. With the event loop they are
+// read on the executor, since the store then takes no locks; ok is false
+// once the executor has stopped.
+func (s *Server) stats() (stats commands.Stats, ok bool) {
+	s.mu.Lock()
+	exec := s.exec
+	s.mu.Unlock()
+	if exec == nil {
+		return s.handler.Stats(), true
+	}
+	ok = exec.do(func() { stats = s.handler.Stats() })
+	return stats, ok
+}
+
+// writeMetrics writes every metric to m, taking the handler figures from
+// stats
+func (s *Server) writeMetrics(m *metricsWriter, stats commands.Stats) {
+	m.family("redis_uptime_in_seconds", "gauge", "Seconds since the server started")
+	m.sample("redis_uptime_in_seconds", nil, stats.Uptime.Seconds())
+
+	m.family("redis_connected_clients", "gauge", "Clients connected")
+	m.sample("redis_connected_clients", nil, float64(stats.ConnectedClients))
+	m.family("redis_connections_received_total", "counter", "Connections accepted")
+	m.sample("redis_connections_received_total", nil, float64(stats.TotalConnections))
+	m.family("redis_rejected_connections_total", "counter", "Connections refused for exceeding maxclients")
+	m.sample("redis_rejected_connections_total", nil, float64(stats.RejectedConnections))
+
+	m.family("redis_commands_processed_total", "counter", "Commands processed")
+	m.sample("redis_commands_processed_total", nil, float64(stats.CommandsProcessed))
+	m.family("redis_error_replies_total", "counter", "Error replies sent")
+	m.sample("redis_error_replies_total", nil, float64(stats.ErrorReplies))
+
+	commands := s.handler.CommandStats()
+	m.family("redis_commands_total", "counter", "Calls per command")
+	for _, cs := range commands {
+		m.sample("redis_commands_total", []string{"cmd", cs.Name}, float64(cs.Calls))
+	}
+	m.family("redis_commands_duration_seconds_total", "counter", "Time spent running each command")
+	for _, cs := range commands {
+		m.sample("redis_commands_duration_seconds_total", []string{"cmd", cs.Name}, cs.Duration.Seconds())
+	}
+	m.family("redis_commands_rejected_calls_total", "counter", "Calls per command refused before running")
+	for _, cs := range commands {
+		m.sample("redis_commands_rejected_calls_total", []string{"cmd", cs.Name}, float64(cs.Rejected))
+	}
+	m.family("redis_commands_failed_calls_total", "counter", "Calls per command that returned an error")
+	for _, cs := range commands {
+		m.sample("redis_commands_failed_calls_total", []string{"cmd", cs.Name}, float64(cs.Failed))
+	}
+
+	m.family("redis_commands_latency_seconds", "histogram", "Latency of each command while latency-tracking is on")
+	for _, cs := range commands {
+		if cs.Latencies == nil || cs.Latencies.Count() == 0 {
+			continue
+		}
+		below := make([]int64, 0, len(latencyBuckets))
+		cs.Latencies.Log2(latencyBuckets[0], func(bound, n int64) {
+			if len(below) < len(latencyBuckets) {
+				below = append(below, n)
+			}
+		})
+		for len(below) < len(latencyBuckets) {
+			below = append(below, cs.Latencies.Count())
+		}
+		for i, bound := range latencyBuckets {
+			le := strconv.FormatFloat(time.Duration(bound).Seconds(), 'g', -1, 64)
+			m.sample("redis_commands_latency_seconds_bucket", []string{"cmd", cs.Name, "le", le}, float64(below[i]))
+		}
+		m.sample("redis_commands_latency_seconds_bucket", []string{"cmd", cs.Name, "le", "+Inf"}, float64(cs.Latencies.Count()))
+		m.sample("redis_commands_latency_seconds_sum", []string{"cmd", cs.Name}, cs.Duration.Seconds())
+		m.sample("redis_commands_latency_seconds_count", []string{"cmd", cs.Name}, float64(cs.Latencies.Count()))
+	}
+
+	m.family("redis_db_keys", "gauge", "Keys per database")
+	m.sample("redis_db_keys", []string{"db", "db0"}, float64(stats.Keys))
+	m.family("redis_db_keys_expiring", "gauge", "Keys with an expiration per database")
+	m.sample("redis_db_keys_expiring", []string{"db", "db0"}, float64(stats.ExpiringKeys))
+	m.family("redis_expired_keys_total", "counter", "Keys removed on expiry")
+	m.sample("redis_expired_keys_total", nil, float64(stats.ExpiredKeys))
+	m.family("redis_keyspace_hits_total", "counter", "Reads that found their key")
+	m.sample("redis_keyspace_hits_total", nil, float64(stats.KeyspaceHits))
+	m.family("redis_keyspace_misses_total", "counter", "Reads that did not find their key")
+	m.sample("redis_keyspace_misses_total", nil, float64(stats.KeyspaceMisses))
+	m.family("redis_evicted_keys_total", "counter", "Keys evicted to stay within memory limits")
+	m.sample("redis_evicted_keys_total", nil, 0)
+
+	m.family("redis_memory_used_bytes", "gauge", "Heap memory in use")
+	m.sample("redis_memory_used_bytes", nil, float64(s.handler.MemoryStats().Used))
+	m.family("redis_lazyfree_pending_objects", "gauge", "Objects waiting to be released in the background")
+	m.sample("redis_lazyfree_pending_objects", nil, float64(stats.LazyfreePendingObjects))
+	m.family("redis_lazyfreed_objects_total", "counter", "Objects released in the background")
+	m.sample("redis_lazyfreed_objects_total", nil, float64(stats.LazyfreedObjects))
+
+	// The server keeps its data in memory only
+	m.family("redis_persistence_enabled", "gauge", "Whether each kind of persistence is enabled")
+	m.sample("redis_persistence_enabled", []string{"type", "rdb"}, 0)
+	m.sample("redis_persistence_enabled", []string{"type", "aof"}, 0)
+	m.family("redis_loading_dump_file", "gauge", "Whether a dump file is being loaded")
+	m.sample("redis_loading_dump_file", nil, 0)
+}
+
+// metricsWriter writes metrics in the Prometheus text exposition format
+type metricsWriter struct {
+	w *bufio.Writer
+}
+
+// family starts a metric family of the given type
+func (m *metricsWriter) family(name, typ, help string) {
+	m.w.WriteString("# HELP " + name + " " + help + "\n")
+	m.w.WriteString("# TYPE " + name + " " + typ + "\n")
+}
+
+// sample writes one value of a metric, with labels given as name and
+// value pairs
+func (m *metricsWriter) sample(name string, labels []string, value float64) {
+	m.w.WriteString(name)
+	for i := 0; i < len(labels); i += 2 {
+		if i == 0 {
+			m.w.WriteByte('{')
+		} else {
+			m.w.WriteByte(',')
+		}
+		m.w.WriteString(labels[i] + `="` + labelEscaper.Replace(labels[i+1]) + `"`)
+	}
+	if len(labels) > 0 {
+		m.w.WriteByte('}')
+	}
+	m.w.WriteByte(' ')
+	m.w.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	m.w.WriteByte('\n')
+}
+
+// labelEscaper escapes label values as the exposition format requires
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
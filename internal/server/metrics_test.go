@@ -0,0 +1,76 @@
+package server
+
+import (
+	"bufio"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_Metrics(t *testing.T) {
+	srv := New("")
+	defer srv.store.Close()
+
+	srv.handler.Execute([]interface{}{"SET", "k", "v"})
+	srv.handler.Execute([]interface{}{"SET", "temp", "v", "EX", "100"})
+	srv.handler.Execute([]interface{}{"GET", "k"})
+	srv.handler.Execute([]interface{}{"GET", "k", "extra"})
+
+	rec := httptest.NewRecorder()
+	srv.MetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, 200, rec.Code)
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", rec.Header().Get("Content-Type"))
+
+	body := rec.Body.String()
+	sample := regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*(\{[a-zA-Z_][a-zA-Z0-9_]*="[^"]*"(,[a-zA-Z_][a-zA-Z0-9_]*="[^"]*")*\})? \S+$`)
+	values := make(map[string]float64)
+	for _, line := range strings.Split(strings.TrimSuffix(body, "\n"), "\n") {
+		if strings.HasPrefix(line, "# HELP ") || strings.HasPrefix(line, "# TYPE ") {
+			continue
+		}
+		require.Regexp(t, sample, line)
+		i := strings.LastIndexByte(line, ' ')
+		v, err := strconv.ParseFloat(line[i+1:], 64)
+		require.NoError(t, err, line)
+		values[line[:i]] = v
+	}
+
+	assert.Equal(t, 4.0, values["redis_commands_processed_total"])
+	assert.Equal(t, 1.0, values["redis_error_replies_total"])
+	assert.Equal(t, 2.0, values[`redis_commands_total{cmd="set"}`])
+	assert.Equal(t, 1.0, values[`redis_commands_rejected_calls_total{cmd="get"}`])
+	assert.Equal(t, 2.0, values[`redis_db_keys{db="db0"}`])
+	assert.Equal(t, 1.0, values[`redis_db_keys_expiring{db="db0"}`])
+	assert.Equal(t, 0.0, values["redis_expired_keys_total"])
+	assert.Equal(t, 1.0, values["redis_keyspace_hits_total"])
+	assert.Equal(t, 0.0, values["redis_keyspace_misses_total"])
+	assert.Equal(t, 0.0, values[`redis_persistence_enabled{type="rdb"}`])
+	assert.Greater(t, values["redis_memory_used_bytes"], 0.0)
+	assert.Contains(t, body, "# TYPE redis_commands_latency_seconds histogram\n")
+
+	// Histogram buckets are cumulative and end with every call
+	previous := 0.0
+	for _, bound := range latencyBuckets {
+		le := strconv.FormatFloat(time.Duration(bound).Seconds(), 'g', -1, 64)
+		v, ok := values[`redis_commands_latency_seconds_bucket{cmd="get",le="`+le+`"}`]
+		require.True(t, ok, le)
+		assert.GreaterOrEqual(t, v, previous)
+		previous = v
+	}
+	assert.Equal(t, 1.0, values[`redis_commands_latency_seconds_bucket{cmd="get",le="+Inf"}`])
+	assert.Equal(t, 1.0, values[`redis_commands_latency_seconds_count{cmd="get"}`])
+}
+
+func TestMetricsWriter_EscapesLabels(t *testing.T) {
+	var b strings.Builder
+	m := &metricsWriter{w: bufio.NewWriter(&b)}
+	m.sample("x", []string{"cmd", "a\"b\\c\nd"}, 1.5)
+	m.w.Flush()
+	assert.Equal(t, `x{cmd="a\"b\\c\nd"} 1.5`+"\n", b.String())
+}
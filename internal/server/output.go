@@ -0,0 +1,210 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Shaso41/Backend-SystemFocus/internal/config"
+)
+
+// Client classes with their own output buffer limits. Clients that ran
+// MONITOR are pubsub clients, since they are sent a stream of messages
+// however slowly they read. The server has no replicas; their class is
+// kept so that configurations written for Redis still load.
+const (
+	classNormal = iota
+	classReplica
+	classPubSub
+)
+
+// classNames names the client classes in client-output-buffer-limit
+var classNames = [...]string{"normal", "replica", "pubsub"}
+
+// outputChunk is the most written to a connection at once, so that the
+// soft limit is checked again as a large buffer drains
+const outputChunk = 64 * 1024
+
+// outputLimit bounds the replies buffered for a client. A client whose
+// buffer exceeds hard bytes, or stays above soft bytes for more than
+// softSeconds, is disconnected. Zero disables a limit.
+type outputLimit struct {
+	hard, soft, softSeconds int64
+}
+
+// outputLimits holds the limits of each client class
+type outputLimits [len(classNames)]outputLimit
+
+// defaultOutputLimits mirror Redis: normal clients are unlimited
+var defaultOutputLimits = outputLimits{
+	classNormal:  {},
+	classReplica: {hard: 256 << 20, soft: 64 << 20, softSeconds: 60},
+	classPubSub:  {hard: 32 << 20, soft: 8 << 20, softSeconds: 60},
+}
+
+// registerOutputLimits registers client-output-buffer-limit, whose value
+// is a sequence of "class hard soft seconds" groups. Setting it changes
+// only the classes given.
+func registerOutputLimits(c *config.Config) *config.Value[outputLimits] {
+	var v *config.Value[outputLimits]
+	v = config.Custom(c, config.Spec{Name: "client-output-buffer-limit", Mutable: true, MultiWord: true,
+		Doc: "Output buffer limits as <class> <hard> <soft> <soft-seconds> for the normal, replica and pubsub classes"},
+		defaultOutputLimits, func(s string) (outputLimits, error) {
+			limits := defaultOutputLimits
+			if v != nil {
+				limits = v.Get()
+			}
+			return parseOutputLimits(limits, s)
+		}, formatOutputLimits)
+	return v
+}
+
+// parseOutputLimits applies the groups in s to limits
+func parseOutputLimits(limits outputLimits, s string) (outputLimits, error) {
+	words := strings.Fields(s)
+	if len(words) == 0 || len(words)%4 != 0 {
+		return limits, fmt.Errorf("Wrong number of arguments in buffer limit configuration.")
+	}
+
+	for i := 0; i < len(words); i += 4 {
+		class := -1
+		switch strings.ToLower(words[i]) {
+		case "normal":
+			class = classNormal
+		case "replica", "slave":
+			class = classReplica
+		case "pubsub":
+			class = classPubSub
+		default:
+			return limits, fmt.Errorf("Invalid client class specified in buffer limit configuration.")
+		}
+
+		hard, err1 := config.ParseMemory(words[i+1])
+		soft, err2 := config.ParseMemory(words[i+2])
+		seconds, err3 := strconv.ParseInt(words[i+3], 10, 64)
+		if err1 != nil || err2 != nil || err3 != nil || seconds < 0 {
+			return limits, fmt.Errorf("Error in hard, soft or soft_seconds setting in buffer limit configuration.")
+		}
+		limits[class] = outputLimit{hard: hard, soft: soft, softSeconds: seconds}
+	}
+	return limits, nil
+}
+
+// formatOutputLimits shows all classes the way CONFIG GET reports them
+func formatOutputLimits(limits outputLimits) string {
+	groups := make([]string, len(limits))
+	for class, l := range limits {
+		groups[class] = fmt.Sprintf("%s %d %d %d", classNames[class], l.hard, l.soft, l.softSeconds)
+	}
+	return strings.Join(groups, " ")
+}
+
+// outputLimitError reports a client disconnected for its output buffer
+type outputLimitError struct {
+	class    int
+	limit    string
+	buffered int
+}
+
+func (e *outputLimitError) Error() string {
+	return fmt.Sprintf("%d bytes of output buffered, over the %s limit for %s clients",
+		e.buffered, e.limit, classNames[e.class])
+}
+
+// output buffers the replies for a connection until they are flushed,
+// enforcing the output buffer limits of the client's class. It is used by
+// the connection's goroutine only.
+type output struct {
+	conn  net.Conn
+	class int
+	limit outputLimit
+	buf   []byte
+
+	// When the buffer went over the soft limit, or zero while under it
+	softSince time.Time
+}
+
+// Write buffers replies, failing once the buffer exceeds the limits
+func (o *output) Write(p []byte) (int, error) {
+	o.buf = append(o.buf, p...)
+	if err := o.check(len(o.buf)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Len returns the number of bytes buffered
+func (o *output) Len() int {
+	return len(o.buf)
+}
+
+// check compares n buffered bytes to the client's limits
+func (o *output) check(n int) error {
+	if o.limit.hard > 0 && int64(n) > o.limit.hard {
+		return &outputLimitError{class: o.class, limit: "hard", buffered: n}
+	}
+	if o.limit.soft == 0 || int64(n) <= o.limit.soft {
+		o.softSince = time.Time{}
+		return nil
+	}
+
+	now := time.Now()
+	if o.softSince.IsZero() {
+		o.softSince = now
+	} else if now.Sub(o.softSince) > time.Duration(o.limit.softSeconds)*time.Second {
+		return &outputLimitError{class: o.class, limit: "soft", buffered: n}
+	}
+	return nil
+}
+
+// Flush writes the buffered replies to the connection. While the buffer is
+// over the soft limit, writes time out when the client has been over it
+// for too long; otherwise deadline, which may be zero, applies.
+func (o *output) Flush(deadline time.Time) error {
+	defer o.reset()
+
+	softDeadline := false
+	for off := 0; off < len(o.buf); {
+		if err := o.check(len(o.buf) - off); err != nil {
+			return err
+		}
+		if !o.softSince.IsZero() {
+			d := o.softSince.Add(time.Duration(o.limit.softSeconds) * time.Second)
+			if deadline.IsZero() || d.Before(deadline) {
+				o.conn.SetWriteDeadline(d)
+				softDeadline = true
+			}
+		} else if softDeadline {
+			o.conn.SetWriteDeadline(deadline)
+			softDeadline = false
+		}
+
+		n, err := o.conn.Write(o.buf[off:min(len(o.buf), off+outputChunk)])
+		off += n
+		if err != nil {
+			if softDeadline && errors.Is(err, os.ErrDeadlineExceeded) {
+				return &outputLimitError{class: o.class, limit: "soft", buffered: len(o.buf) - off}
+			}
+			return err
+		}
+	}
+
+	if softDeadline {
+		o.conn.SetWriteDeadline(deadline)
+	}
+	return nil
+}
+
+// reset empties the buffer, letting go of memory grown for large replies
+func (o *output) reset() {
+	o.softSince = time.Time{}
+	if cap(o.buf) > outputChunk {
+		o.buf = nil
+		return
+	}
+	o.buf = o.buf[:0]
+}
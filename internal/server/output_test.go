@@ -0,0 +1,156 @@
+package server
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Shaso41/Backend-SystemFocus/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutputLimits_Config(t *testing.T) {
+	c := config.New()
+	limits := registerOutputLimits(c)
+
+	value, _ := c.Lookup("client-output-buffer-limit")
+	assert.Equal(t, "normal 0 0 0 replica 268435456 67108864 60 pubsub 33554432 8388608 60", value)
+
+	// Only the classes given change
+	require.NoError(t, c.Set("client-output-buffer-limit", "pubsub 1mb 512kb 10 normal 4gb 1gb 5"))
+	assert.Equal(t, outputLimit{hard: 1 << 20, soft: 512 << 10, softSeconds: 10}, limits.Get()[classPubSub])
+	assert.Equal(t, outputLimit{hard: 4 << 30, soft: 1 << 30, softSeconds: 5}, limits.Get()[classNormal])
+	assert.Equal(t, defaultOutputLimits[classReplica], limits.Get()[classReplica])
+
+	assert.EqualError(t, c.Set("client-output-buffer-limit", "normal 1 2"),
+		"client-output-buffer-limit: Wrong number of arguments in buffer limit configuration.")
+	assert.EqualError(t, c.Set("client-output-buffer-limit", "master 1 2 3"),
+		"client-output-buffer-limit: Invalid client class specified in buffer limit configuration.")
+	assert.EqualError(t, c.Set("client-output-buffer-limit", "slave 1 x 3"),
+		"client-output-buffer-limit: Error in hard, soft or soft_seconds setting in buffer limit configuration.")
+}
+
+func TestOutput_HardLimit(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+
+	out := &output{conn: server, limit: outputLimit{hard: 100}}
+	_, err := out.Write(make([]byte, 60))
+	assert.NoError(t, err)
+	_, err = out.Write(make([]byte, 60))
+	var limitErr *outputLimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, "120 bytes of output buffered, over the hard limit for normal clients", err.Error())
+}
+
+func TestOutput_SoftLimit(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+
+	out := &output{conn: server, class: classPubSub, limit: outputLimit{soft: 10, softSeconds: 1}}
+
+	// Staying under the soft limit is fine however slow the client reads
+	go io.Copy(io.Discard, io.LimitReader(client, 5))
+	_, err := out.Write([]byte("small"))
+	require.NoError(t, err)
+	assert.NoError(t, out.Flush(time.Time{}))
+	assert.Equal(t, 0, out.Len())
+
+	// Over it, a client that does not read is cut off after softSeconds
+	_, err = out.Write([]byte(strings.Repeat("x", 100)))
+	require.NoError(t, err)
+	start := time.Now()
+	err = out.Flush(time.Time{})
+	var limitErr *outputLimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, "soft", limitErr.limit)
+	assert.InDelta(t, time.Second, time.Since(start), float64(500*time.Millisecond))
+}
+
+func TestServer_OutputBufferLimit(t *testing.T) {
+	srv := New("localhost:16401")
+	go srv.Start()
+	time.Sleep(100 * time.Millisecond)
+	defer srv.Stop()
+
+	conn, err := net.Dial("tcp", "localhost:16401")
+	require.NoError(t, err)
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	for _, cmd := range []string{
+		"CONFIG SET client-output-buffer-limit \"normal 1kb 0 0\"\r\n",
+		"SET big " + strings.Repeat("v", 2000) + "\r\n",
+	} {
+		_, err = conn.Write([]byte(cmd))
+		require.NoError(t, err)
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, "+OK\r\n", line)
+	}
+
+	_, err = conn.Write([]byte("GET big\r\n"))
+	require.NoError(t, err)
+
+	// The reply to GET does not fit, so the client is disconnected
+	_, err = reader.ReadString('\n')
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestServer_MonitorOutputLimit(t *testing.T) {
+	testMonitorOutputLimit(t, "localhost:16418")
+}
+
+// testMonitorOutputLimit checks that clients that ran MONITOR are held to
+// the pubsub limits
+func testMonitorOutputLimit(t *testing.T, addr string, opts ...Option) {
+	srv := New(addr, opts...)
+	go srv.Start()
+	time.Sleep(100 * time.Millisecond)
+	defer srv.Stop()
+
+	// The limits of normal clients leave monitoring clients unlimited
+	monitor, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer monitor.Close()
+	monitorReader := bufio.NewReader(monitor)
+	monitor.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, err = monitor.Write([]byte("CONFIG SET client-output-buffer-limit \"normal 1kb 0 0 pubsub 100mb 0 0\"\r\nMONITOR\r\n"))
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		line, err := monitorReader.ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, "+OK\r\n", line)
+	}
+
+	client, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer client.Close()
+	clientReader := bufio.NewReader(client)
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	send := func(cmd string) {
+		_, err := client.Write([]byte(cmd))
+		require.NoError(t, err)
+		line, err := clientReader.ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, "+OK\r\n", line)
+	}
+
+	send("SET big " + strings.Repeat("v", 2000) + "\r\n")
+	line, err := monitorReader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Contains(t, line, `] "SET" "big"`)
+
+	// Their own limit applies once it is lower than a monitored command
+	send("CONFIG SET client-output-buffer-limit \"pubsub 1kb 0 0\"\r\n")
+	send("SET big " + strings.Repeat("v", 2000) + "\r\n")
+	_, err = monitorReader.ReadString('\n')
+	assert.Equal(t, io.EOF, err)
+}
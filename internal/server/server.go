@@ -1,172 +1,635 @@
 package server
 
 import (
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
 
 	"github.com/Shaso41/Backend-SystemFocus/internal/commands"
+	"github.com/Shaso41/Backend-SystemFocus/internal/config"
 	"github.com/Shaso41/Backend-SystemFocus/internal/protocol"
 	"github.com/Shaso41/Backend-SystemFocus/internal/store"
 )
 
+// errStopped is reported for commands cut short by the server stopping
+var errStopped = errors.New("server stopped")
+
 // Server represents the Redis-like TCP server
 type Server struct {
-	address  string
-	listener net.Listener
+	params *params
+	tls    *tlsLoader
+
+	mu        sync.Mutex
+	listeners []net.Listener
+
 	store    *store.Store
 	handler  *commands.Handler
+	exec     *executor
+	loop     *eventLoop
+	err      error
 	stopCh   chan struct{}
 	stopOnce sync.Once
 }
 
-// New creates a new server instance
-func New(address string) *Server {
-	s := store.New()
-	return &Server{
-		address: address,
-		store:   s,
-		handler: commands.NewHandler(s),
+// Option configures optional server behaviour
+type Option func(*Server)
+
+// WithConfigFile loads parameters from a redis.conf-style file, which
+// CONFIG REWRITE later updates. Options given after it override the file.
+func WithConfigFile(path string) Option {
+	return func(s *Server) {
+		if s.err == nil {
+			s.fail(s.handler.Config().Load(path))
+		}
+	}
+}
+
+// WithConfigValue sets a configuration parameter, as a line of the
+// configuration file would. CONFIG GET lists the parameter names.
+func WithConfigValue(name, value string) Option {
+	return func(s *Server) {
+		s.set(name, value)
+	}
+}
+
+// WithListen adds another address to serve plain connections on, in the
+// same forms New accepts
+func WithListen(address string) Option {
+	return func(s *Server) {
+		s.set("addr", strings.Join(append(s.params.addr.Get(), address), " "))
+	}
+}
+
+// WithUnixSocketPerm sets the file permissions of unix sockets the server
+// listens on, such as 0770 to admit a group. By default they follow the
+// process umask.
+func WithUnixSocketPerm(perm os.FileMode) Option {
+	return func(s *Server) {
+		s.set("unixsocketperm", strconv.FormatUint(uint64(perm), 8))
+	}
+}
+
+// WithProtocolLimits sets the limits on request sizes accepted from
+// clients. Requests that exceed them are rejected with a protocol error
+// and the connection is closed.
+func WithProtocolLimits(limits protocol.Limits) Option {
+	return func(s *Server) {
+		s.set("proto-max-bulk-len", strconv.Itoa(limits.MaxBulkLen))
+		s.set("proto-max-multibulk-len", strconv.Itoa(limits.MaxArrayLen))
+		s.set("proto-max-inline-len", strconv.Itoa(limits.MaxInlineLen))
+		s.set("proto-max-nesting", strconv.Itoa(limits.MaxDepth))
+	}
+}
+
+// WithRequirePass requires clients to authenticate as the default user
+// with password before running commands
+func WithRequirePass(password string) Option {
+	return func(s *Server) {
+		s.set("requirepass", password)
+	}
+}
+
+// WithACLFile loads users from the ACL file at path when the server
+// starts. Users it defines, including the default user, take precedence
+// over WithRequirePass.
+func WithACLFile(path string) Option {
+	return func(s *Server) {
+		s.set("aclfile", path)
+	}
+}
+
+// WithTLS adds a TLS listener on address, using the certificates in opts.
+// Passing an empty address to New serves TLS only.
+func WithTLS(address string, opts TLSOptions) Option {
+	return func(s *Server) {
+		s.set("tls-addr", address)
+		s.set("tls-cert-file", opts.CertFile)
+		s.set("tls-key-file", opts.KeyFile)
+		s.set("tls-ca-cert-file", opts.CAFile)
+		for name, mode := range tlsAuthModes {
+			if mode == opts.ClientAuth {
+				s.set("tls-auth-clients", name)
+				return
+			}
+		}
+		s.fail(fmt.Errorf("unsupported TLS client authentication mode %v", opts.ClientAuth))
+	}
+}
+
+// New creates a new server instance listening on address, which is either
+// a TCP host:port or a unix socket given as unix:///path or an absolute
+// path. An empty address adds no listener, for servers that only listen
+// on addresses given with WithListen or WithTLS. Invalid options are
+// reported by Start.
+func New(address string, opts ...Option) *Server {
+	st := store.New()
+	s := &Server{
+		store:   st,
+		handler: commands.NewHandler(st),
 		stopCh:  make(chan struct{}),
 	}
+	s.params = registerParams(s.handler.Config())
+	s.watchParams()
+
+	s.set("addr", address)
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
-// Start starts the TCP server
+// Params describes the configuration parameters of the server and its
+// command handler, with their defaults
+func Params() []config.ParamInfo {
+	st := store.New()
+	defer st.Close()
+
+	c := commands.NewHandler(st).Config()
+	registerParams(c)
+	return c.Params()
+}
+
+// set changes a configuration parameter while the server is set up,
+// remembering the first failure for Start to report
+func (s *Server) set(name, value string) {
+	if s.err == nil {
+		s.fail(s.handler.Config().Set(name, value))
+	}
+}
+
+// fail records err unless an earlier error was recorded
+func (s *Server) fail(err error) {
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+// Start starts the server's listeners and serves connections until the
+// server is stopped
 func (s *Server) Start() error {
-	listener, err := net.Listen("tcp", s.address)
+	if s.err != nil {
+		return fmt.Errorf("invalid configuration: %w", s.err)
+	}
+	if err := s.configureACL(); err != nil {
+		return fmt.Errorf("failed to configure ACL: %w", err)
+	}
+
+	var loop *eventLoop
+	if s.params.ioModel.Get() == ioEventLoop {
+		var err error
+		if loop, err = newEventLoop(s); err != nil {
+			return fmt.Errorf("failed to start server: %w", err)
+		}
+	}
+
+	if s.params.tlsAddr.Get() != "" {
+		loader, err := newTLSLoader(s.tlsOptions)
+		if err != nil {
+			closeLoop(loop)
+			return fmt.Errorf("failed to start server: %w", err)
+		}
+		s.tls = loader
+	}
+
+	listeners, err := s.listen()
 	if err != nil {
+		closeLoop(loop)
 		return fmt.Errorf("failed to start server: %w", err)
 	}
 
-	s.listener = listener
-	log.Printf("🚀 Redis Clone server started on %s", s.address)
+	s.mu.Lock()
+	select {
+	case <-s.stopCh:
+		// Stopped before the listeners were up
+		s.mu.Unlock()
+		closeAll(listeners)
+		closeLoop(loop)
+		return nil
+	default:
+	}
+	s.listeners = listeners
+	if loop != nil {
+		// Every command runs on the executor, so the store needs no locks
+		// once its background work runs there too
+		s.exec, s.loop = newExecutor(s.stopCh), loop
+		s.store.RunOn(s.exec.do)
+		go s.exec.run()
+		go s.loop.run()
+	}
+	s.mu.Unlock()
+
 	log.Printf("📊 Ready to accept connections...")
 
-	// Handle graceful shutdown
+	// Handle graceful shutdown and certificate reloads
 	go s.handleShutdown()
+	go s.handleReload()
+
+	// Accept connections on every listener until stopped
+	var wg sync.WaitGroup
+	for _, listener := range listeners {
+		wg.Add(1)
+		go func(listener net.Listener) {
+			defer wg.Done()
+			s.serve(listener)
+		}(listener)
+	}
+	wg.Wait()
+	return nil
+}
+
+// listen opens the configured listeners
+func (s *Server) listen() ([]net.Listener, error) {
+	var listeners []net.Listener
+
+	addresses := s.params.addr.Get()
+	if path := s.params.unixSocket.Get(); path != "" {
+		addresses = append(addresses, unixScheme+path)
+	}
+	for _, address := range addresses {
+		listener, err := listenOn(address, s.unixSocketPerm())
+		if err != nil {
+			closeAll(listeners)
+			return nil, err
+		}
+		listeners = append(listeners, listener)
+		log.Printf("🚀 Redis Clone server started on %s", address)
+	}
+
+	if address := s.params.tlsAddr.Get(); address != "" {
+		listener, err := listenOn(address, s.unixSocketPerm())
+		if err != nil {
+			closeAll(listeners)
+			return nil, err
+		}
+		listeners = append(listeners, tls.NewListener(listener, s.tls.config()))
+		log.Printf("🔒 Redis Clone server started on %s (TLS)", address)
+	}
 
-	// Accept connections
+	if len(listeners) == 0 {
+		return nil, fmt.Errorf("no listen address configured")
+	}
+	return listeners, nil
+}
+
+// serve accepts connections on listener until it is closed
+func (s *Server) serve(listener net.Listener) {
 	for {
-		select {
-		case <-s.stopCh:
-			return nil
-		default:
-			conn, err := listener.Accept()
-			if err != nil {
-				select {
-				case <-s.stopCh:
-					return nil
-				default:
-					log.Printf("Error accepting connection: %v", err)
-					continue
-				}
+		conn, err := listener.Accept()
+		if err != nil {
+			select {
+			case <-s.stopCh:
+				return
+			default:
 			}
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Printf("Error accepting connection: %v", err)
+			continue
+		}
 
-			// Handle connection in a new goroutine
-			go s.handleConnection(conn)
+		// Handle connection in a new goroutine, or from the event loop.
+		// TLS connections always have their own goroutine.
+		s.setKeepAlive(conn)
+		if _, isTLS := conn.(*tls.Conn); s.loop != nil && !isTLS {
+			s.loop.add(conn)
+			continue
 		}
+		go s.handleConnection(conn)
+	}
+}
+
+// ReloadTLS reads the TLS certificate, key and CA files again. Connections
+// made afterwards use the new certificates; established ones are kept. On
+// error the previous certificates stay in use.
+func (s *Server) ReloadTLS() error {
+	if s.tls == nil {
+		return fmt.Errorf("TLS is not enabled")
+	}
+	return s.tls.reload()
+}
+
+// configureACL loads the configured ACL file. The password given by
+// requirepass is applied as soon as it is set.
+func (s *Server) configureACL() error {
+	if path := s.params.aclFile.Get(); path != "" {
+		return s.handler.ACL().LoadFile(path)
 	}
+	return nil
 }
 
 // handleConnection handles a single client connection
 func (s *Server) handleConnection(conn net.Conn) {
 	defer conn.Close()
 
-	clientAddr := conn.RemoteAddr().String()
+	clientAddr := clientAddr(conn)
 	log.Printf("✅ New client connected: %s", clientAddr)
 
 	// Set connection timeout
-	conn.SetDeadline(time.Now().Add(5 * time.Minute))
+	deadline := s.idleDeadline(time.Now())
+	conn.SetDeadline(deadline)
+
+	// Complete the TLS handshake up front so failures are reported as such
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		if err := tlsConn.Handshake(); err != nil {
+			log.Printf("❌ TLS handshake with %s failed: %v", clientAddr, err)
+			return
+		}
+	}
 
 	parser := protocol.NewParser(conn)
-	encoder := protocol.NewEncoder(conn)
+	out := &output{conn: conn, class: classNormal}
+	encoder := protocol.NewEncoder(out)
+	encoder.SetAutoFlush(false)
+
+	sess := s.handler.NewSession()
+	sess.Addr = clientAddr
+	sess.LocalAddr = conn.LocalAddr().String()
+	if err := s.handler.Register(sess, conn); err != nil {
+		log.Printf("❌ Refused client %s: %v", clientAddr, err)
+		encoder.WriteError(err.Error())
+		s.flush(encoder, out, deadline)
+		return
+	}
+	defer s.handler.Unregister(sess)
+
+	// Once the client runs MONITOR, a second goroutine streams commands to
+	// it, and writes to the connection are made holding writeMu
+	var writeMu sync.Mutex
+	var monitor *commands.Monitor
+	done := make(chan struct{})
+	defer close(done)
 
+	out.limit = s.params.outputLimits.Get()[out.class]
 	for {
-		// Reset deadline on each command
-		conn.SetDeadline(time.Now().Add(5 * time.Minute))
+		// Reset limits on each command, so that CONFIG SET applies to
+		// established connections too
+		parser.SetLimits(s.protocolLimits())
 
 		// Parse command
-		data, err := parser.Parse()
+		args, err := parser.ReadCommand()
 		if err != nil {
-			if err.Error() != "EOF" {
+			// Replies to the last commands may still be buffered, when they
+			// were followed by an empty request. Connections closed by
+			// CLIENT KILL end like disconnects.
+			writeMu.Lock()
+			if err != io.EOF && !errors.Is(err, net.ErrClosed) {
 				log.Printf("❌ Parse error from %s: %v", clientAddr, err)
 				encoder.WriteError(fmt.Sprintf("ERR %v", err))
 			}
+			s.flush(encoder, out, deadline)
+			writeMu.Unlock()
 			break
 		}
 
-		// Convert to command arguments
-		args, ok := data.([]interface{})
-		if !ok {
-			encoder.WriteError("ERR invalid command format")
-			continue
+		locked := monitor != nil
+		if locked {
+			writeMu.Lock()
 		}
 
-		// Execute command
-		result, err := s.handler.Execute(args)
-		if err != nil {
-			encoder.WriteError(err.Error())
-			continue
+		// Reset deadline on each command; monitoring clients never time out
+		now := time.Now()
+		if monitor == nil {
+			deadline = s.idleDeadline(now)
+			conn.SetDeadline(deadline)
 		}
+		out.limit = s.params.outputLimits.Get()[out.class]
+		queryBuffer, queryBufferFree := parser.Buffered()
+		sess.Track(now, queryBuffer, queryBufferFree, encoder.Buffered()+out.Len())
 
-		// Send response
-		if err := s.writeResponse(encoder, result); err != nil {
-			log.Printf("❌ Write error to %s: %v", clientAddr, err)
+		if s.exec != nil {
+			err = s.executeShared(encoder, sess, args)
+		} else {
+			err = s.execute(encoder, sess, args)
+		}
+		closing := sess.CloseAfterReply()
+
+		// Pipelined commands already buffered are executed before replying,
+		// so a whole batch of replies goes out in a single write
+		if err == nil && (closing || !parser.Pending()) {
+			err = s.flush(encoder, out, deadline)
+		}
+		if monitor != nil {
+			monitor.SetLimit(out.limit.hard)
+		}
+		if locked {
+			writeMu.Unlock()
+		}
+
+		if err != nil {
+			logWriteError(clientAddr, err)
+			break
+		}
+		if closing {
 			break
 		}
+
+		if m := sess.Monitor(); m != nil && monitor == nil {
+			monitor, deadline = m, time.Time{}
+			out.class = classPubSub
+			out.limit = s.params.outputLimits.Get()[out.class]
+			monitor.SetLimit(out.limit.hard)
+			conn.SetDeadline(deadline)
+			go s.streamMonitor(conn, clientAddr, monitor, encoder, out, &writeMu, done)
+		}
 	}
 
 	log.Printf("👋 Client disconnected: %s", clientAddr)
 }
 
-// writeResponse writes the appropriate RESP response based on result type
-func (s *Server) writeResponse(encoder *protocol.Encoder, result interface{}) error {
-	switch v := result.(type) {
-	case nil:
-		return encoder.WriteNull()
-	case commands.SimpleString:
-		return encoder.WriteSimpleString(string(v))
-	case commands.BulkString:
-		return encoder.WriteBulkString(string(v))
-	case int64:
-		return encoder.WriteInteger(v)
-	case []string:
-		return encoder.WriteArray(v)
-	default:
-		return encoder.WriteError("ERR unknown response type")
+// executeShared runs a command on the event loop's executor, which runs
+// the commands of every client. A command held up by CLIENT PAUSE waits
+// here rather than on the executor, where it would hold up every other
+// client too.
+func (s *Server) executeShared(encoder *protocol.Encoder, sess *commands.Session, args [][]byte) error {
+	for {
+		var resume <-chan struct{}
+		var err error
+		ok := s.exec.do(func() {
+			if resume = s.handler.PausedUntil(sess, args); resume == nil {
+				err = s.execute(encoder, sess, args)
+			}
+		})
+		if !ok {
+			return errStopped
+		}
+		if resume == nil {
+			return err
+		}
+		select {
+		case <-resume:
+		case <-s.stopCh:
+			return errStopped
+		}
+	}
+}
+
+// clientAddr returns the address a client is known by
+func clientAddr(conn net.Conn) string {
+	addr := conn.RemoteAddr().String()
+	if addr == "" || addr == "@" {
+		// Unix socket peers are unnamed; report the socket like Redis does
+		addr = conn.LocalAddr().String() + ":0"
+	}
+	return addr
+}
+
+// flush sends the replies buffered by encoder and out to the client
+func (s *Server) flush(encoder *protocol.Encoder, out *output, deadline time.Time) error {
+	if err := encoder.Flush(); err != nil {
+		return err
+	}
+	return out.Flush(deadline)
+}
+
+// streamMonitor sends the commands queued for a client that ran MONITOR
+// until done is closed
+func (s *Server) streamMonitor(conn net.Conn, clientAddr string, monitor *commands.Monitor,
+	encoder *protocol.Encoder, out *output, writeMu *sync.Mutex, done <-chan struct{}) {
+	var lines []string
+	for {
+		select {
+		case <-monitor.Ready():
+		case <-done:
+			return
+		}
+
+		lines = monitor.Take(lines[:0])
+		writeMu.Lock()
+		out.limit = s.params.outputLimits.Get()[out.class]
+		monitor.SetLimit(out.limit.hard)
+		var err error
+		for _, line := range lines {
+			if err = encoder.WriteSimpleString(line); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			err = s.flush(encoder, out, time.Time{})
+		}
+		writeMu.Unlock()
+
+		if err != nil {
+			select {
+			case <-done:
+			default:
+				logWriteError(clientAddr, err)
+				conn.Close()
+			}
+			return
+		}
 	}
 }
 
+// logWriteError logs why replies could not be sent to a client
+func logWriteError(clientAddr string, err error) {
+	var limitErr *outputLimitError
+	if errors.As(err, &limitErr) {
+		log.Printf("❌ Closing client %s for overcoming output buffer limits: %v", clientAddr, err)
+		return
+	}
+	log.Printf("❌ Write error to %s: %v", clientAddr, err)
+}
+
+// execute runs one parsed command and buffers its reply in encoder
+func (s *Server) execute(encoder *protocol.Encoder, sess *commands.Session, args [][]byte) error {
+	// Execute command
+	result, err := s.handler.Exec(sess, args)
+	if err != nil {
+		return encoder.WriteError(err.Error())
+	}
+
+	// HELLO may have switched the protocol, which applies to its own reply
+	encoder.SetProtocol(sess.Protocol)
+
+	// Send response
+	return s.writeResponse(encoder, result)
+}
+
+// writeResponse writes the RESP encoding of a command result, which may be
+// an arbitrarily nested reply
+func (s *Server) writeResponse(encoder *protocol.Encoder, result interface{}) error {
+	return encoder.WriteValue(result)
+}
+
 // handleShutdown handles graceful shutdown on SIGINT/SIGTERM
 func (s *Server) handleShutdown() {
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
 
-	<-sigCh
+	select {
+	case <-sigCh:
+	case <-s.stopCh:
+		return
+	}
 	log.Println("\n🛑 Shutting down server...")
 
-	close(s.stopCh)
-	if s.listener != nil {
-		s.listener.Close()
-	}
-	s.store.Close()
+	s.Stop()
 
 	log.Println("✅ Server stopped gracefully")
 	os.Exit(0)
 }
 
+// handleReload reloads the TLS certificates on SIGHUP
+func (s *Server) handleReload() {
+	if s.tls == nil {
+		return
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGHUP)
+	defer signal.Stop(sigCh)
+
+	for {
+		select {
+		case <-sigCh:
+			if err := s.ReloadTLS(); err != nil {
+				log.Printf("❌ TLS reload failed, keeping current certificates: %v", err)
+			} else {
+				log.Printf("🔒 TLS certificates reloaded")
+			}
+		case <-s.stopCh:
+			return
+		}
+	}
+}
+
 // Stop stops the server
 func (s *Server) Stop() {
 	s.stopOnce.Do(func() {
+		s.mu.Lock()
 		close(s.stopCh)
-		if s.listener != nil {
-			s.listener.Close()
+		closeAll(s.listeners)
+		if s.loop != nil {
+			s.loop.wakeup()
 		}
+		s.mu.Unlock()
+
 		s.store.Close()
 	})
 }
+
+// closeLoop releases an event loop that never ran, if there is one
+func closeLoop(loop *eventLoop) {
+	if loop != nil {
+		loop.close()
+	}
+}
+
+// closeAll closes every listener
+func closeAll(listeners []net.Listener) {
+	for _, listener := range listeners {
+		listener.Close()
+	}
+}
@@ -2,11 +2,19 @@ package server
 
 import (
 	"bufio"
+	"io"
 	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/Shaso41/Backend-SystemFocus/internal/protocol"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestServer_StartAndStop(t *testing.T) {
@@ -149,3 +157,419 @@ func TestServer_MultipleConcurrentClients(t *testing.T) {
 		assert.True(t, success)
 	}
 }
+
+func TestServer_HelloSwitchesProtocol(t *testing.T) {
+	srv := New("localhost:16382")
+
+	go srv.Start()
+	time.Sleep(100 * time.Millisecond)
+	defer srv.Stop()
+
+	conn, err := net.Dial("tcp", "localhost:16382")
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+
+	// A missing key is a RESP2 null bulk string before negotiation
+	_, err = conn.Write([]byte("*2\r\n$3\r\nGET\r\n$7\r\nmissing\r\n"))
+	assert.NoError(t, err)
+	response, err := reader.ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, "$-1\r\n", response)
+
+	_, err = conn.Write([]byte("*2\r\n$5\r\nHELLO\r\n$1\r\n3\r\n"))
+	assert.NoError(t, err)
+	response, err = reader.ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, "%7\r\n", response)
+
+	// Drain the seven key/value pairs of the HELLO map
+	for i := 0; i < 14; i++ {
+		line, err := reader.ReadString('\n')
+		assert.NoError(t, err)
+		if line[0] == '$' {
+			_, err = reader.ReadString('\n')
+			assert.NoError(t, err)
+		}
+	}
+
+	_, err = conn.Write([]byte("*2\r\n$3\r\nGET\r\n$7\r\nmissing\r\n"))
+	assert.NoError(t, err)
+	response, err = reader.ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, "_\r\n", response)
+}
+
+func TestServer_Pipelining(t *testing.T) {
+	srv := New("localhost:16383")
+
+	go srv.Start()
+	time.Sleep(100 * time.Millisecond)
+	defer srv.Stop()
+
+	conn, err := net.Dial("tcp", "localhost:16383")
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+
+	// Many commands in one write, followed by the start of another one
+	var batch strings.Builder
+	for i := 0; i < 100; i++ {
+		batch.WriteString("*1\r\n$4\r\nPING\r\n")
+	}
+	batch.WriteString("*1\r\n$4\r\nPI")
+	_, err = conn.Write([]byte(batch.String()))
+	assert.NoError(t, err)
+
+	// Replies to the complete commands arrive without waiting for the rest
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for i := 0; i < 100; i++ {
+		response, err := reader.ReadString('\n')
+		assert.NoError(t, err)
+		assert.Equal(t, "+PONG\r\n", response)
+	}
+
+	_, err = conn.Write([]byte("NG\r\n"))
+	assert.NoError(t, err)
+	response, err := reader.ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, "+PONG\r\n", response)
+}
+
+func TestServer_EmptyRequestsAfterCommand(t *testing.T) {
+	srv := New("localhost:16415")
+
+	go srv.Start()
+	time.Sleep(100 * time.Millisecond)
+	defer srv.Stop()
+
+	for _, empty := range []string{"\r\n", "*0\r\n", "*-1\r\n", "   \r\n"} {
+		conn, err := net.Dial("tcp", "localhost:16415")
+		require.NoError(t, err)
+		reader := bufio.NewReader(conn)
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+		// The reply is sent although no further command follows
+		_, err = conn.Write([]byte("PING\r\n" + empty))
+		require.NoError(t, err)
+		response, err := reader.ReadString('\n')
+		assert.NoError(t, err, "%q", empty)
+		assert.Equal(t, "+PONG\r\n", response, "%q", empty)
+
+		// And when the client stops writing right after
+		_, err = conn.Write([]byte("PING\r\n" + empty))
+		require.NoError(t, err)
+		conn.(*net.TCPConn).CloseWrite()
+		response, err = reader.ReadString('\n')
+		assert.NoError(t, err, "%q", empty)
+		assert.Equal(t, "+PONG\r\n", response, "%q", empty)
+		conn.Close()
+	}
+}
+
+// benchmarkPipeline sends b.N SET commands over one connection, depth
+// commands at a time, and waits for every reply
+func benchmarkPipeline(b *testing.B, depth int) {
+	srv := New("localhost:16384")
+	go srv.Start()
+	time.Sleep(100 * time.Millisecond)
+	defer srv.Stop()
+
+	conn, err := net.Dial("tcp", "localhost:16384")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	var batch []byte
+	for i := 0; i < depth; i++ {
+		batch = append(batch, "*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$5\r\nvalue\r\n"...)
+	}
+
+	b.ResetTimer()
+	for sent := 0; sent < b.N; sent += depth {
+		n := depth
+		if b.N-sent < n {
+			n = b.N - sent
+		}
+		if _, err := conn.Write(batch[:n*len(batch)/depth]); err != nil {
+			b.Fatal(err)
+		}
+		for i := 0; i < n; i++ {
+			if _, err := reader.ReadString('\n'); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkServer_NoPipeline(b *testing.B) {
+	benchmarkPipeline(b, 1)
+}
+
+func BenchmarkServer_Pipeline1000(b *testing.B) {
+	benchmarkPipeline(b, 1000)
+}
+
+func TestServer_ProtocolLimitClosesConnection(t *testing.T) {
+	srv := New("localhost:16385", WithProtocolLimits(protocol.Limits{MaxBulkLen: 1024}))
+
+	go srv.Start()
+	time.Sleep(100 * time.Millisecond)
+	defer srv.Stop()
+
+	conn, err := net.Dial("tcp", "localhost:16385")
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$2000000000\r\n"))
+	assert.NoError(t, err)
+
+	reader := bufio.NewReader(conn)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	response, err := reader.ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, "-ERR Protocol error: invalid bulk length\r\n", response)
+
+	// The server hangs up after a protocol error
+	_, err = reader.ReadString('\n')
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestServer_InlineQuotedArguments(t *testing.T) {
+	srv := New("localhost:16386")
+
+	go srv.Start()
+	time.Sleep(100 * time.Millisecond)
+	defer srv.Stop()
+
+	conn, err := net.Dial("tcp", "localhost:16386")
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("SET greeting \"hello world\"\r\nGET greeting\r\nSET bad \"open\r\n"))
+	assert.NoError(t, err)
+
+	reader := bufio.NewReader(conn)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	var replies []string
+	for i := 0; i < 4; i++ {
+		line, err := reader.ReadString('\n')
+		assert.NoError(t, err)
+		replies = append(replies, line)
+	}
+	assert.Equal(t, []string{
+		"+OK\r\n",
+		"$11\r\n",
+		"hello world\r\n",
+		"-ERR Protocol error: unbalanced quotes in request\r\n",
+	}, replies)
+}
+
+func TestServer_LineBreaksInCommandName(t *testing.T) {
+	srv := New("localhost:16416")
+
+	go srv.Start()
+	time.Sleep(100 * time.Millisecond)
+	defer srv.Stop()
+
+	conn, err := net.Dial("tcp", "localhost:16416")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// The error echoes the name on one line, inline or as a bulk string
+	_, err = conn.Write([]byte("\"X\\r\\n+OK\"\r\n*1\r\n$6\r\nX\r\n+OK\r\nPING\r\n"))
+	require.NoError(t, err)
+
+	reader := bufio.NewReader(conn)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var replies []string
+	for i := 0; i < 3; i++ {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		replies = append(replies, line)
+	}
+	assert.Equal(t, []string{
+		"-ERR unknown command 'X  +OK'\r\n",
+		"-ERR unknown command 'X  +OK'\r\n",
+		"+PONG\r\n",
+	}, replies)
+}
+
+func TestServer_MultipleListeners(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "redis.sock")
+	srv := New("localhost:16396",
+		WithListen("unix://"+socket),
+		WithListen("localhost:16397"),
+		WithUnixSocketPerm(0o770),
+	)
+
+	go srv.Start()
+	time.Sleep(100 * time.Millisecond)
+
+	info, err := os.Stat(socket)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o770), info.Mode().Perm())
+
+	for _, target := range [][2]string{{"tcp", "localhost:16396"}, {"unix", socket}, {"tcp", "localhost:16397"}} {
+		conn, err := net.Dial(target[0], target[1])
+		require.NoError(t, err, target[1])
+
+		conn.Write([]byte("PING\r\n"))
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		response, err := bufio.NewReader(conn).ReadString('\n')
+		assert.NoError(t, err, target[1])
+		assert.Equal(t, "+PONG\r\n", response, target[1])
+		conn.Close()
+	}
+
+	// The socket file is removed on shutdown
+	srv.Stop()
+	_, err = os.Stat(socket)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestServer_UnixSocketReplacesStaleFile(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "redis.sock")
+
+	// A socket file left behind by a process that did not clean up
+	stale, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	srv := New(socket)
+	go srv.Start()
+	time.Sleep(100 * time.Millisecond)
+	defer srv.Stop()
+
+	conn, err := net.Dial("unix", socket)
+	require.NoError(t, err)
+	conn.Close()
+}
+
+func TestServer_UnixSocketPathIsNotASocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.txt")
+	require.NoError(t, os.WriteFile(path, []byte("keep me"), 0o600))
+
+	err := New("unix://" + path).Start()
+	assert.Error(t, err)
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "keep me", string(data))
+}
+
+func TestServer_ClientKill(t *testing.T) {
+	srv := New("localhost:16399")
+
+	go srv.Start()
+	time.Sleep(100 * time.Millisecond)
+	defer srv.Stop()
+
+	victim, err := net.Dial("tcp", "localhost:16399")
+	require.NoError(t, err)
+	defer victim.Close()
+	victimReader := bufio.NewReader(victim)
+	_, err = victim.Write([]byte("CLIENT SETNAME victim\r\n"))
+	require.NoError(t, err)
+	victim.SetReadDeadline(time.Now().Add(2 * time.Second))
+	line, err := victimReader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "+OK\r\n", line)
+
+	admin, err := net.Dial("tcp", "localhost:16399")
+	require.NoError(t, err)
+	defer admin.Close()
+	adminReader := bufio.NewReader(admin)
+	admin.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	_, err = admin.Write([]byte("CLIENT LIST\r\n"))
+	require.NoError(t, err)
+	header, err := adminReader.ReadString('\n')
+	require.NoError(t, err)
+	n, err := strconv.Atoi(strings.TrimSpace(header[1:]))
+	require.NoError(t, err)
+	list := make([]byte, n+2)
+	_, err = io.ReadFull(adminReader, list)
+	require.NoError(t, err)
+	assert.Contains(t, string(list), "name=victim")
+	assert.Equal(t, 2, strings.Count(string(list), "\n")-1)
+
+	_, err = admin.Write([]byte("CLIENT KILL ADDR " + victim.LocalAddr().String() + "\r\n"))
+	require.NoError(t, err)
+	line, err = adminReader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, ":1\r\n", line)
+
+	// The victim's connection is closed by the server
+	_, err = victimReader.ReadString('\n')
+	assert.Equal(t, io.EOF, err)
+
+	// A client killing itself gets the reply first
+	_, err = admin.Write([]byte("CLIENT KILL SKIPME no\r\n"))
+	require.NoError(t, err)
+	line, err = adminReader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, ":1\r\n", line)
+	_, err = adminReader.ReadString('\n')
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestServer_Monitor(t *testing.T) {
+	srv := New("localhost:16402", WithConfigValue("timeout", "1"))
+
+	go srv.Start()
+	time.Sleep(100 * time.Millisecond)
+	defer srv.Stop()
+
+	monitor, err := net.Dial("tcp", "localhost:16402")
+	require.NoError(t, err)
+	defer monitor.Close()
+	monitorReader := bufio.NewReader(monitor)
+	monitor.SetReadDeadline(time.Now().Add(3 * time.Second))
+	_, err = monitor.Write([]byte("MONITOR\r\n"))
+	require.NoError(t, err)
+	line, err := monitorReader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "+OK\r\n", line)
+
+	// Monitoring clients outlive the idle timeout
+	time.Sleep(1500 * time.Millisecond)
+
+	client, err := net.Dial("tcp", "localhost:16402")
+	require.NoError(t, err)
+	defer client.Close()
+	clientReader := bufio.NewReader(client)
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, err = client.Write([]byte("SET k \"a b\"\r\nAUTH secret\r\nGET k\r\n"))
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, err := clientReader.ReadString('\n')
+		require.NoError(t, err)
+	}
+
+	addr := regexp.QuoteMeta(client.LocalAddr().String())
+	for _, want := range []string{`"SET" "k" "a b"`, `"AUTH" "\(redacted\)"`, `"GET" "k"`} {
+		line, err := monitorReader.ReadString('\n')
+		require.NoError(t, err)
+		assert.Regexp(t, `^\+\d+\.\d{6} \[0 `+addr+`\] `+want+"\r\n$", line)
+	}
+
+	// The monitoring client can still run commands, whose replies may come
+	// before or after their own monitor line
+	_, err = monitor.Write([]byte("PING\r\n"))
+	require.NoError(t, err)
+	var lines []string
+	for i := 0; i < 2; i++ {
+		line, err := monitorReader.ReadString('\n')
+		require.NoError(t, err)
+		lines = append(lines, line)
+	}
+	assert.Contains(t, lines, "+PONG\r\n")
+	assert.Contains(t, strings.Join(lines, ""), `] "PING"`)
+}
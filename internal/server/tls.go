@@ -0,0 +1,95 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync/atomic"
+)
+
+// TLSOptions configures the TLS listener
+type TLSOptions struct {
+	// CertFile and KeyFile hold the PEM server certificate chain and key
+	CertFile string
+	KeyFile  string
+
+	// CAFile holds the PEM certificates of the authorities trusted to sign
+	// client certificates. It is required when ClientAuth verifies them.
+	CAFile string
+
+	// ClientAuth selects whether clients must present a certificate:
+	// tls.NoClientCert, tls.VerifyClientCertIfGiven or
+	// tls.RequireAndVerifyClientCert
+	ClientAuth tls.ClientAuthType
+}
+
+// tlsLoader holds the TLS configuration built from the certificate files
+// and swaps in a fresh one when they are reloaded, so that new connections
+// pick up renewed certificates without a restart
+type tlsLoader struct {
+	options func() TLSOptions
+	current atomic.Pointer[tls.Config]
+}
+
+// newTLSLoader loads the certificate files named by options, which is
+// consulted again on every reload so that changed file names take effect
+func newTLSLoader(options func() TLSOptions) (*tlsLoader, error) {
+	l := &tlsLoader{options: options}
+	if err := l.reload(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// reload reads the certificate files again. On error the previous
+// configuration stays in use.
+func (l *tlsLoader) reload() error {
+	opts := l.options()
+	cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   opts.ClientAuth,
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if opts.CAFile != "" {
+		pool, err := loadCertPool(opts.CAFile)
+		if err != nil {
+			return err
+		}
+		config.ClientCAs = pool
+	} else if opts.ClientAuth >= tls.VerifyClientCertIfGiven {
+		return fmt.Errorf("a CA certificate file is required to verify client certificates")
+	}
+
+	l.current.Store(config)
+	return nil
+}
+
+// config returns the configuration for a TLS listener, which hands every
+// new connection the most recently loaded certificates
+func (l *tlsLoader) config() *tls.Config {
+	return &tls.Config{
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return l.current.Load(), nil
+		},
+	}
+}
+
+// loadCertPool reads PEM certificates from path into a pool
+func loadCertPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load CA certificates: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+	return pool, nil
+}
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Shaso41/Backend-SystemFocus/internal/tlstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tlsClientConfig trusts the test CA and, with withCert, presents the test
+// client certificate
+func tlsClientConfig(t *testing.T, files tlstest.Files, withCert bool) *tls.Config {
+	pem, err := os.ReadFile(files.CACert)
+	require.NoError(t, err)
+	pool := x509.NewCertPool()
+	require.True(t, pool.AppendCertsFromPEM(pem))
+
+	config := &tls.Config{RootCAs: pool, ServerName: "localhost"}
+	if withCert {
+		cert, err := tls.LoadX509KeyPair(files.ClientCert, files.ClientKey)
+		require.NoError(t, err)
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config
+}
+
+// tlsPing sends PING over a new TLS connection and returns the reply and
+// the serial number of the server certificate
+func tlsPing(address string, config *tls.Config) (string, int64, error) {
+	conn, err := tls.Dial("tcp", address, config)
+	if err != nil {
+		return "", 0, err
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+	if _, err := conn.Write([]byte("PING\r\n")); err != nil {
+		return "", 0, err
+	}
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return "", 0, err
+	}
+	return reply, conn.ConnectionState().PeerCertificates[0].SerialNumber.Int64(), nil
+}
+
+func TestServer_TLSMutualAuth(t *testing.T) {
+	files := tlstest.Generate(t, t.TempDir(), 1)
+	srv := New("", WithTLS("localhost:16387", TLSOptions{
+		CertFile:   files.ServerCert,
+		KeyFile:    files.ServerKey,
+		CAFile:     files.CACert,
+		ClientAuth: tls.RequireAndVerifyClientCert,
+	}))
+
+	go srv.Start()
+	time.Sleep(100 * time.Millisecond)
+	defer srv.Stop()
+
+	reply, _, err := tlsPing("localhost:16387", tlsClientConfig(t, files, true))
+	assert.NoError(t, err)
+	assert.Equal(t, "+PONG\r\n", reply)
+
+	// Without a client certificate the server refuses the connection
+	_, _, err = tlsPing("localhost:16387", tlsClientConfig(t, files, false))
+	assert.Error(t, err)
+}
+
+func TestServer_TLSAlongsidePlain(t *testing.T) {
+	files := tlstest.Generate(t, t.TempDir(), 1)
+	srv := New("localhost:16388", WithTLS("localhost:16389", TLSOptions{
+		CertFile: files.ServerCert,
+		KeyFile:  files.ServerKey,
+	}))
+
+	go srv.Start()
+	time.Sleep(100 * time.Millisecond)
+	defer srv.Stop()
+
+	reply, _, err := tlsPing("localhost:16389", tlsClientConfig(t, files, false))
+	assert.NoError(t, err)
+	assert.Equal(t, "+PONG\r\n", reply)
+
+	conn, err := tls.Dial("tcp", "localhost:16389", &tls.Config{ServerName: "localhost"})
+	if err == nil {
+		conn.Close()
+	}
+	assert.Error(t, err, "the test CA is not trusted by default")
+}
+
+func TestServer_TLSReload(t *testing.T) {
+	dir := t.TempDir()
+	files := tlstest.Generate(t, dir, 1)
+	srv := New("", WithTLS("localhost:16395", TLSOptions{
+		CertFile: files.ServerCert,
+		KeyFile:  files.ServerKey,
+	}))
+
+	go srv.Start()
+	time.Sleep(100 * time.Millisecond)
+	defer srv.Stop()
+
+	_, serial, err := tlsPing("localhost:16395", tlsClientConfig(t, files, false))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), serial)
+
+	// Broken files are rejected and the old certificate stays in use
+	assert.NoError(t, os.WriteFile(files.ServerCert, []byte("garbage"), 0o600))
+	assert.Error(t, srv.ReloadTLS())
+	_, serial, err = tlsPing("localhost:16395", tlsClientConfig(t, files, false))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), serial)
+
+	files = tlstest.Generate(t, dir, 2)
+	assert.NoError(t, srv.ReloadTLS())
+
+	_, serial, err = tlsPing("localhost:16395", tlsClientConfig(t, files, false))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), serial)
+}
+
+func TestServer_TLSMissingCertificate(t *testing.T) {
+	srv := New("", WithTLS("localhost:0", TLSOptions{
+		CertFile: "/nonexistent/server.crt",
+		KeyFile:  "/nonexistent/server.key",
+	}))
+
+	err := srv.Start()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to load TLS certificate")
+}
+
+func TestServer_TLSClientAuthNeedsCA(t *testing.T) {
+	files := tlstest.Generate(t, t.TempDir(), 1)
+	srv := New("", WithTLS("localhost:0", TLSOptions{
+		CertFile:   files.ServerCert,
+		KeyFile:    files.ServerKey,
+		ClientAuth: tls.RequireAndVerifyClientCert,
+	}))
+
+	assert.Error(t, srv.Start())
+}
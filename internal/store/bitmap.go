@@ -0,0 +1,469 @@
+package store
+
+import (
+	"math/bits"
+	"time"
+)
+
+// BitOp is a bitwise operation applied by Store.BitOp
+type BitOp int
+
+// Supported BITOP operations
+const (
+	BitOpAnd BitOp = iota
+	BitOpOr
+	BitOpXor
+	BitOpNot
+)
+
+// BitFieldKind is the kind of a single BITFIELD subcommand
+type BitFieldKind int
+
+// Supported BITFIELD subcommands
+const (
+	BitFieldGet BitFieldKind = iota
+	BitFieldSet
+	BitFieldIncrBy
+)
+
+// Overflow controls how BITFIELD SET and INCRBY handle out-of-range results
+type Overflow int
+
+// Supported BITFIELD overflow modes
+const (
+	OverflowWrap Overflow = iota
+	OverflowSat
+	OverflowFail
+)
+
+// BitFieldOp describes one GET, SET or INCRBY subcommand of BITFIELD.
+// Offset is an absolute bit offset; Value is the value to set or the
+// increment to add.
+type BitFieldOp struct {
+	Kind     BitFieldKind
+	Signed   bool
+	Bits     uint
+	Offset   uint64
+	Value    int64
+	Overflow Overflow
+}
+
+// BitFieldResult is the outcome of a single BitFieldOp. Failed is set when
+// the operation overflowed under OverflowFail and nothing was written.
+type BitFieldResult struct {
+	Value  int64
+	Failed bool
+}
+
+// SetBit sets or clears the bit at offset in the string stored at key and
+// returns the previous bit. The string is grown with zero bytes as needed
+// and any existing expiration is kept.
+func (s *Store) SetBit(key string, offset uint64, on bool) int {
+	sh := s.shardFor(key)
+	sh.lock()
+	defer sh.unlock()
+
+	now := time.Now()
+	buf := sh.bytesFor(key, now, offset>>3+1)
+
+	old := getBit(buf, offset)
+	if on {
+		buf[offset>>3] |= 0x80 >> (offset & 7)
+	} else {
+		buf[offset>>3] &^= 0x80 >> (offset & 7)
+	}
+
+	sh.overwrite(key, buf, now)
+	return old
+}
+
+// GetBit returns the bit at offset in the string stored at key. Bits past
+// the end of the string, and bits of missing keys, are zero.
+func (s *Store) GetBit(key string, offset uint64) int {
+	sh := s.shardFor(key)
+	sh.rlock()
+	defer sh.runlock()
+
+	val, exists := sh.lookupRead(key)
+	if !exists {
+		return 0
+	}
+	return getBit(val.Data, offset)
+}
+
+// BitCount counts the set bits of the string stored at key. When ranged is
+// false the whole string is counted; otherwise start and end are inclusive
+// indexes, counted from the end when negative, in bytes or in bits when
+// bitUnit is set.
+func (s *Store) BitCount(key string, start, end int64, ranged, bitUnit bool) int64 {
+	sh := s.shardFor(key)
+	sh.rlock()
+	defer sh.runlock()
+
+	val, exists := sh.lookupRead(key)
+	if !exists {
+		return 0
+	}
+	data := val.Data
+
+	if !ranged {
+		return popCount(data)
+	}
+
+	firstBit, lastBit, ok := bitRange(len(data), start, end, bitUnit)
+	if !ok {
+		return 0
+	}
+
+	var count int64
+	firstByte, lastByte := firstBit>>3, lastBit>>3
+	for i := firstByte; i <= lastByte; i++ {
+		b := data[i]
+		if i == firstByte {
+			b &= 0xff >> (firstBit & 7)
+		}
+		if i == lastByte {
+			b &= 0xff << (7 - lastBit&7)
+		}
+		count += int64(bits.OnesCount8(b))
+	}
+	return count
+}
+
+// BitPos returns the position of the first bit set to bit in the string
+// stored at key, or -1 when there is none. Range arguments follow BitCount;
+// hasStart and hasEnd report which bounds were given. When looking for a
+// clear bit without an explicit end, the string is treated as padded with
+// zeros on the right, as Redis does.
+func (s *Store) BitPos(key string, bit int, start, end int64, hasStart, hasEnd, bitUnit bool) int64 {
+	sh := s.shardFor(key)
+	sh.rlock()
+	defer sh.runlock()
+
+	val, exists := sh.lookupRead(key)
+	if !exists {
+		if bit == 1 {
+			return -1
+		}
+		return 0
+	}
+	data := val.Data
+
+	if !hasStart {
+		start, bitUnit = 0, false
+	}
+	if !hasEnd {
+		end = -1
+	}
+
+	firstBit, lastBit, ok := bitRange(len(data), start, end, bitUnit)
+	if !ok {
+		return -1
+	}
+
+	// Whole bytes that cannot contain the wanted bit are skipped
+	skip := byte(0x00)
+	if bit == 0 {
+		skip = 0xff
+	}
+	for pos := firstBit; pos <= lastBit; {
+		if pos&7 == 0 && pos+7 <= lastBit && data[pos>>3] == skip {
+			pos += 8
+			continue
+		}
+		if getBit(data, uint64(pos)) == bit {
+			return pos
+		}
+		pos++
+	}
+
+	if bit == 0 && !hasEnd {
+		return lastBit + 1
+	}
+	return -1
+}
+
+// BitOp stores the result of applying op to the strings at srcKeys into
+// destKey and returns the length of the result. Missing keys and shorter
+// strings are treated as zero-padded. An empty result deletes destKey.
+func (s *Store) BitOp(op BitOp, destKey string, srcKeys []string) int64 {
+	unlock := s.lockKeys(append([]string{destKey}, srcKeys...)...)
+	defer unlock()
+
+	now := time.Now()
+	srcs := make([][]byte, len(srcKeys))
+	maxLen := 0
+	for i, key := range srcKeys {
+		if val, exists := s.shardFor(key).lookupRead(key); exists {
+			srcs[i] = val.Data
+		}
+		if len(srcs[i]) > maxLen {
+			maxLen = len(srcs[i])
+		}
+	}
+
+	dest := s.shardFor(destKey)
+	if maxLen == 0 {
+		dest.data.Delete(destKey)
+		dest.expires.Delete(destKey)
+		return 0
+	}
+
+	result := make([]byte, maxLen)
+	for i := range result {
+		var acc byte
+		for j, src := range srcs {
+			var b byte
+			if i < len(src) {
+				b = src[i]
+			}
+			if j == 0 {
+				acc = b
+				continue
+			}
+			switch op {
+			case BitOpAnd:
+				acc &= b
+			case BitOpOr:
+				acc |= b
+			case BitOpXor:
+				acc ^= b
+			}
+		}
+		if op == BitOpNot {
+			acc = ^acc
+		}
+		result[i] = acc
+	}
+
+	dest.data.Set(destKey, &Value{
+		Data:      result,
+		CreatedAt: now,
+	})
+	dest.expires.Delete(destKey)
+	return int64(maxLen)
+}
+
+// BitField runs ops in order against the string stored at key. When any op
+// writes, the string is grown to fit every field and stored back, keeping
+// its expiration.
+func (s *Store) BitField(key string, ops []BitFieldOp) []BitFieldResult {
+	sh := s.shardFor(key)
+	sh.lock()
+	defer sh.unlock()
+
+	now := time.Now()
+	var need uint64
+	writes := false
+	for _, op := range ops {
+		if op.Kind == BitFieldGet {
+			continue
+		}
+		writes = true
+		if end := (op.Offset + uint64(op.Bits) + 7) >> 3; end > need {
+			need = end
+		}
+	}
+
+	var buf []byte
+	if writes {
+		buf = sh.bytesFor(key, now, need)
+	} else if val, exists := sh.lookupRead(key); exists {
+		buf = val.Data
+	}
+
+	results := make([]BitFieldResult, len(ops))
+	for i, op := range ops {
+		old := readField(buf, op.Offset, op.Bits, op.Signed)
+		if op.Kind == BitFieldGet {
+			results[i] = BitFieldResult{Value: old}
+			continue
+		}
+
+		value, incr := op.Value, int64(0)
+		if op.Kind == BitFieldIncrBy {
+			value, incr = old, op.Value
+		}
+
+		var next int64
+		var ok bool
+		if op.Signed {
+			next, ok = signedFieldResult(value, incr, op.Bits, op.Overflow)
+		} else {
+			next, ok = unsignedFieldResult(uint64(value), incr, op.Bits, op.Overflow)
+		}
+		if !ok {
+			results[i] = BitFieldResult{Failed: true}
+			continue
+		}
+
+		writeField(buf, op.Offset, op.Bits, uint64(next))
+		if op.Kind == BitFieldSet {
+			results[i] = BitFieldResult{Value: old}
+		} else {
+			results[i] = BitFieldResult{Value: next}
+		}
+	}
+
+	if writes {
+		sh.overwrite(key, buf, now)
+	}
+	return results
+}
+
+// bytesFor returns a private copy of the live string at key, zero-padded
+// to at least size bytes, that can be modified and stored back without
+// affecting slices already returned by Get. The caller must have locked the shard.
+func (sh *shard) bytesFor(key string, now time.Time, size uint64) []byte {
+	var data []byte
+	if val, exists := sh.lookup(key, now); exists {
+		data = val.Data
+	}
+	if uint64(len(data)) > size {
+		size = uint64(len(data))
+	}
+	buf := make([]byte, size)
+	copy(buf, data)
+	return buf
+}
+
+// getBit returns the bit at offset, most significant bit first
+func getBit(data []byte, offset uint64) int {
+	idx := offset >> 3
+	if idx >= uint64(len(data)) {
+		return 0
+	}
+	return int(data[idx]>>(7-offset&7)) & 1
+}
+
+// popCount counts the set bits in data
+func popCount(data []byte) int64 {
+	var count int64
+	for _, b := range data {
+		count += int64(bits.OnesCount8(b))
+	}
+	return count
+}
+
+// bitRange converts inclusive start/end indexes over a string of length
+// bytes into an inclusive bit range, clamping to the string. It reports
+// false when the range is empty.
+func bitRange(length int, start, end int64, bitUnit bool) (int64, int64, bool) {
+	total := int64(length)
+	if bitUnit {
+		total *= 8
+	}
+	if total == 0 {
+		return 0, 0, false
+	}
+
+	if start < 0 {
+		start += total
+	}
+	if end < 0 {
+		end += total
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end < 0 {
+		end = 0
+	}
+	if end >= total {
+		end = total - 1
+	}
+	if start > end {
+		return 0, 0, false
+	}
+
+	if bitUnit {
+		return start, end, true
+	}
+	return start * 8, end*8 + 7, true
+}
+
+// readField reads an n-bit big-endian field at offset, sign-extending it
+// when signed. Bits past the end of data read as zero.
+func readField(data []byte, offset uint64, n uint, signed bool) int64 {
+	var v uint64
+	for i := uint64(0); i < uint64(n); i++ {
+		v = v<<1 | uint64(getBit(data, offset+i))
+	}
+	if signed && n < 64 && v&(1<<(n-1)) != 0 {
+		v |= ^uint64(0) << n
+	}
+	return int64(v)
+}
+
+// writeField stores the low n bits of v big-endian at offset. data must
+// already be large enough.
+func writeField(data []byte, offset uint64, n uint, v uint64) {
+	for i := uint64(0); i < uint64(n); i++ {
+		pos := offset + i
+		mask := byte(0x80 >> (pos & 7))
+		if v&(1<<(uint64(n)-1-i)) != 0 {
+			data[pos>>3] |= mask
+		} else {
+			data[pos>>3] &^= mask
+		}
+	}
+}
+
+// signedFieldResult computes value+incr for an n-bit signed field under
+// the given overflow mode. It reports false when the mode is
+// OverflowFail and the result does not fit.
+func signedFieldResult(value, incr int64, n uint, mode Overflow) (int64, bool) {
+	max := int64(uint64(1)<<(n-1) - 1)
+	min := -max - 1
+
+	overflow := value > max || (incr > 0 && value > max-incr)
+	underflow := value < min || (incr < 0 && value < min-incr)
+	if !overflow && !underflow {
+		return value + incr, true
+	}
+
+	switch mode {
+	case OverflowSat:
+		if overflow {
+			return max, true
+		}
+		return min, true
+	case OverflowFail:
+		return 0, false
+	default:
+		// Two's complement wrap-around, then sign-extend back from n bits
+		v := uint64(value) + uint64(incr)
+		if n < 64 {
+			v &= 1<<n - 1
+			if v&(1<<(n-1)) != 0 {
+				v |= ^uint64(0) << n
+			}
+		}
+		return int64(v), true
+	}
+}
+
+// unsignedFieldResult computes value+incr for an n-bit unsigned field
+// under the given overflow mode. It reports false when the mode is
+// OverflowFail and the result does not fit.
+func unsignedFieldResult(value uint64, incr int64, n uint, mode Overflow) (int64, bool) {
+	max := uint64(1)<<n - 1
+
+	overflow := value > max || (incr > 0 && uint64(incr) > max-value)
+	underflow := incr < 0 && uint64(-incr) > value
+	if !overflow && !underflow {
+		return int64(value + uint64(incr)), true
+	}
+
+	switch mode {
+	case OverflowSat:
+		if overflow {
+			return int64(max), true
+		}
+		return 0, true
+	case OverflowFail:
+		return 0, false
+	default:
+		return int64((value + uint64(incr)) & max), true
+	}
+}
@@ -0,0 +1,153 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore_SetBitAndGetBit(t *testing.T) {
+	store := New()
+	defer store.Close()
+
+	assert.Equal(t, 0, store.SetBit("bits", 7, true))
+	assert.Equal(t, 1, store.SetBit("bits", 7, true))
+	assert.Equal(t, 1, store.GetBit("bits", 7))
+	assert.Equal(t, 0, store.GetBit("bits", 6))
+	assert.Equal(t, 0, store.GetBit("bits", 1000))
+
+	val, _ := store.Get("bits")
+	assert.Equal(t, []byte("\x01"), val)
+
+	// Growing pads with zero bytes
+	store.SetBit("bits", 23, true)
+	val, _ = store.Get("bits")
+	assert.Equal(t, []byte("\x01\x00\x01"), val)
+
+	assert.Equal(t, 1, store.SetBit("bits", 7, false))
+	assert.Equal(t, 0, store.GetBit("bits", 7))
+}
+
+func TestStore_SetBitKeepsTTL(t *testing.T) {
+	store := New()
+	defer store.Close()
+
+	store.Set("bits", []byte("a"), 10*time.Second)
+	store.SetBit("bits", 0, true)
+
+	ttl := store.TTL("bits")
+	assert.True(t, ttl > 0 && ttl <= 10)
+}
+
+func TestStore_BitCount(t *testing.T) {
+	store := New()
+	defer store.Close()
+
+	store.Set("key", []byte("foobar"), 0)
+
+	assert.Equal(t, int64(26), store.BitCount("key", 0, -1, false, false))
+	assert.Equal(t, int64(4), store.BitCount("key", 0, 0, true, false))
+	assert.Equal(t, int64(6), store.BitCount("key", 1, 1, true, false))
+	assert.Equal(t, int64(18), store.BitCount("key", 1, -2, true, false))
+	assert.Equal(t, int64(17), store.BitCount("key", 5, 30, true, true))
+	assert.Equal(t, int64(0), store.BitCount("key", 4, 2, true, false))
+	assert.Equal(t, int64(0), store.BitCount("missing", 0, -1, false, false))
+}
+
+func TestStore_BitPos(t *testing.T) {
+	store := New()
+	defer store.Close()
+
+	store.Set("key", []byte("\xff\xf0\x00"), 0)
+	assert.Equal(t, int64(12), store.BitPos("key", 0, 0, 0, false, false, false))
+	assert.Equal(t, int64(8), store.BitPos("key", 1, 1, 0, true, false, false))
+	assert.Equal(t, int64(-1), store.BitPos("key", 1, 2, -1, true, true, false))
+	assert.Equal(t, int64(7), store.BitPos("key", 1, 7, 15, true, true, true))
+
+	store.Set("ones", []byte("\xff\xff"), 0)
+	// Without an explicit end the string is considered zero padded
+	assert.Equal(t, int64(16), store.BitPos("ones", 0, 0, 0, false, false, false))
+	assert.Equal(t, int64(-1), store.BitPos("ones", 0, 0, -1, true, true, false))
+
+	assert.Equal(t, int64(0), store.BitPos("missing", 0, 0, 0, false, false, false))
+	assert.Equal(t, int64(-1), store.BitPos("missing", 1, 0, 0, false, false, false))
+}
+
+func TestStore_BitOp(t *testing.T) {
+	store := New()
+	defer store.Close()
+
+	store.Set("a", []byte("\xf0\x0f"), 0)
+	store.Set("b", []byte("\xff"), 0)
+
+	assert.Equal(t, int64(2), store.BitOp(BitOpAnd, "and", []string{"a", "b"}))
+	val, _ := store.Get("and")
+	assert.Equal(t, []byte("\xf0\x00"), val)
+
+	store.BitOp(BitOpOr, "or", []string{"a", "b"})
+	val, _ = store.Get("or")
+	assert.Equal(t, []byte("\xff\x0f"), val)
+
+	store.BitOp(BitOpXor, "xor", []string{"a", "b"})
+	val, _ = store.Get("xor")
+	assert.Equal(t, []byte("\x0f\x0f"), val)
+
+	store.BitOp(BitOpNot, "not", []string{"a"})
+	val, _ = store.Get("not")
+	assert.Equal(t, []byte("\x0f\xf0"), val)
+
+	// An empty result removes the destination
+	assert.Equal(t, int64(0), store.BitOp(BitOpOr, "a", []string{"missing"}))
+	assert.False(t, store.Exists("a"))
+}
+
+func TestStore_BitField(t *testing.T) {
+	store := New()
+	defer store.Close()
+
+	results := store.BitField("bf", []BitFieldOp{
+		{Kind: BitFieldSet, Signed: false, Bits: 8, Offset: 0, Value: 255},
+		{Kind: BitFieldGet, Signed: false, Bits: 8, Offset: 0},
+		{Kind: BitFieldGet, Signed: true, Bits: 8, Offset: 0},
+		{Kind: BitFieldIncrBy, Signed: false, Bits: 4, Offset: 8, Value: 5},
+	})
+	assert.Equal(t, []BitFieldResult{{Value: 0}, {Value: 255}, {Value: -1}, {Value: 5}}, results)
+
+	val, _ := store.Get("bf")
+	assert.Equal(t, []byte("\xff\x50"), val)
+}
+
+func TestStore_BitFieldOverflow(t *testing.T) {
+	store := New()
+	defer store.Close()
+
+	incr := func(mode Overflow, signed bool, by int64) BitFieldResult {
+		return store.BitField("bf", []BitFieldOp{
+			{Kind: BitFieldIncrBy, Signed: signed, Bits: 8, Offset: 0, Value: by, Overflow: mode},
+		})[0]
+	}
+
+	store.BitField("bf", []BitFieldOp{{Kind: BitFieldSet, Bits: 8, Value: 250}})
+	assert.Equal(t, BitFieldResult{Value: 4}, incr(OverflowWrap, false, 10))
+
+	store.BitField("bf", []BitFieldOp{{Kind: BitFieldSet, Bits: 8, Value: 250}})
+	assert.Equal(t, BitFieldResult{Value: 255}, incr(OverflowSat, false, 10))
+	assert.Equal(t, BitFieldResult{Value: 0}, incr(OverflowSat, false, -300))
+	assert.Equal(t, BitFieldResult{Failed: true}, incr(OverflowFail, false, -1))
+
+	store.BitField("bf", []BitFieldOp{{Kind: BitFieldSet, Signed: true, Bits: 8, Value: 120}})
+	assert.Equal(t, BitFieldResult{Value: -126}, incr(OverflowWrap, true, 10))
+	assert.Equal(t, BitFieldResult{Value: -128}, incr(OverflowSat, true, -10))
+	assert.Equal(t, BitFieldResult{Failed: true}, incr(OverflowFail, true, -1))
+	assert.Equal(t, BitFieldResult{Value: 127}, incr(OverflowSat, true, 1000))
+}
+
+func TestStore_BitFieldReadOnlyDoesNotCreateKey(t *testing.T) {
+	store := New()
+	defer store.Close()
+
+	results := store.BitField("bf", []BitFieldOp{{Kind: BitFieldGet, Signed: true, Bits: 16, Offset: 100}})
+	assert.Equal(t, []BitFieldResult{{Value: 0}}, results)
+	assert.False(t, store.Exists("bf"))
+}
@@ -0,0 +1,159 @@
+package store
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/Shaso41/Backend-SystemFocus/internal/dict"
+)
+
+// lazyFreeQueue is how many batches of detached values may wait for the
+// lazy free worker. Callers free further batches themselves.
+const lazyFreeQueue = 1024
+
+// lazyFreeBatch holds values and whole keyspaces detached from the store
+// together, counted as objects
+type lazyFreeBatch struct {
+	values  []*Value
+	data    []*dict.Dict[*Value]
+	expires []*dict.Dict[time.Time]
+	objects int64
+}
+
+// lazyFreer releases values detached from the keyspace on a background
+// goroutine, so that commands only pay for unlinking them. Memory is
+// reclaimed by the garbage collector once nothing refers to it; what the
+// worker takes off the command path is clearing detached keyspaces, which
+// takes time proportional to their size, and dropping the last references
+// to values.
+type lazyFreer struct {
+	batches chan *lazyFreeBatch
+
+	// Objects queued and not released yet, and released so far
+	pending atomic.Int64
+	freed   atomic.Int64
+}
+
+// newLazyFreer creates a lazy freer whose worker is started with run
+func newLazyFreer() *lazyFreer {
+	return &lazyFreer{batches: make(chan *lazyFreeBatch, lazyFreeQueue)}
+}
+
+// free hands a batch to the worker, or releases it right away when the
+// worker is too far behind
+func (f *lazyFreer) free(batch *lazyFreeBatch) {
+	if batch.objects == 0 {
+		return
+	}
+	f.pending.Add(batch.objects)
+	select {
+	case f.batches <- batch:
+	default:
+		f.release(batch)
+	}
+}
+
+// run releases queued batches until stopCh is closed
+func (f *lazyFreer) run(stopCh <-chan struct{}) {
+	for {
+		select {
+		case batch := <-f.batches:
+			f.release(batch)
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// release clears the dicts of a batch and counts its objects as freed
+func (f *lazyFreer) release(batch *lazyFreeBatch) {
+	for _, data := range batch.data {
+		data.Clear()
+	}
+	for _, expires := range batch.expires {
+		expires.Clear()
+	}
+	clear(batch.values)
+
+	f.pending.Add(-batch.objects)
+	f.freed.Add(batch.objects)
+}
+
+// Unlink removes keys from the store like Delete, leaving their values to
+// be released in the background, and returns how many existed. Keys that
+// expired but were not yet removed are removed as well, counted as expired
+// rather than unlinked.
+func (s *Store) Unlink(keys ...string) int {
+	unlock := s.lockKeys(keys...)
+	now := time.Now()
+	batch := &lazyFreeBatch{}
+	unlinked := 0
+	for _, key := range keys {
+		sh := s.shardFor(key)
+		if _, live := sh.lookup(key, now); live {
+			unlinked++
+		} else if _, expiring := sh.expires.Get(key); expiring {
+			sh.expiredKeys.Add(1)
+		}
+		if val, exists := sh.data.Delete(key); exists {
+			sh.expires.Delete(key)
+			batch.values = append(batch.values, val)
+		}
+	}
+	unlock()
+
+	batch.objects = int64(len(batch.values))
+	s.lazy.free(batch)
+	return unlinked
+}
+
+// Flush removes every key. With async the keyspace is swapped for an
+// empty one and cleared in the background; otherwise it is cleared before
+// Flush returns.
+func (s *Store) Flush(async bool) {
+	for i := range s.shards {
+		s.shards[i].lock()
+	}
+	defer func() {
+		for i := range s.shards {
+			s.shards[i].unlock()
+		}
+	}()
+
+	if !async {
+		for i := range s.shards {
+			s.shards[i].data.Clear()
+			s.shards[i].expires.Clear()
+		}
+		return
+	}
+
+	batch := &lazyFreeBatch{}
+	for i := range s.shards {
+		sh := &s.shards[i]
+		batch.data = append(batch.data, sh.data)
+		batch.expires = append(batch.expires, sh.expires)
+		batch.objects += int64(sh.data.Len())
+		sh.data = dict.New[*Value]()
+		sh.expires = dict.New[time.Time]()
+	}
+	s.lazy.free(batch)
+}
+
+// SetLazyExpire sets whether the values of expired keys removed by the
+// background cleanup are released by the lazy free worker
+func (s *Store) SetLazyExpire(lazy bool) {
+	s.lazyExpire.Store(lazy)
+}
+
+// LazyFreePending returns the number of objects waiting to be released in
+// the background
+func (s *Store) LazyFreePending() int64 {
+	return s.lazy.pending.Load()
+}
+
+// LazyFreed returns the number of objects released in the background so
+// far
+func (s *Store) LazyFreed() int64 {
+	return s.lazy.freed.Load()
+}
@@ -0,0 +1,95 @@
+package store
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore_Unlink(t *testing.T) {
+	store := New()
+	defer store.Close()
+
+	store.Set("a", []byte("1"), 0)
+	store.Set("b", []byte("2"), time.Hour)
+	assert.Equal(t, 2, store.Unlink("a", "b", "missing"))
+	assert.False(t, store.Exists("a"))
+	assert.Equal(t, 0, store.ExpiringCount())
+
+	assert.Eventually(t, func() bool { return store.LazyFreed() == 2 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, int64(0), store.LazyFreePending())
+	assert.Equal(t, 0, store.Unlink("a"))
+
+	store.ResetStats()
+	assert.Equal(t, int64(0), store.LazyFreed())
+}
+
+func TestStore_UnlinkExpired(t *testing.T) {
+	store := New()
+	defer store.Close()
+
+	// Left for Unlink to find rather than the cleanup
+	store.SetCleanupInterval(time.Hour)
+	store.Set("expired", []byte("v"), time.Millisecond)
+	store.Set("live", []byte("v"), time.Hour)
+	time.Sleep(5 * time.Millisecond)
+
+	assert.Equal(t, 1, store.Unlink("expired", "live"))
+	assert.Equal(t, 0, store.Count())
+	assert.Equal(t, 0, store.ExpiringCount())
+	assert.Equal(t, int64(1), store.ExpiredKeys())
+	assert.Eventually(t, func() bool { return store.LazyFreed() == 2 }, time.Second, 5*time.Millisecond)
+}
+
+func TestStore_Flush(t *testing.T) {
+	store := New()
+	defer store.Close()
+
+	for i := 0; i < 1000; i++ {
+		store.Set(strconv.Itoa(i), []byte("value"), time.Hour)
+	}
+	store.Flush(false)
+	assert.Equal(t, 0, store.Count())
+	assert.Equal(t, 0, store.ExpiringCount())
+	assert.Equal(t, int64(0), store.LazyFreed())
+
+	for i := 0; i < 1000; i++ {
+		store.Set(strconv.Itoa(i), []byte("value"), time.Hour)
+	}
+	store.Flush(true)
+	assert.Equal(t, 0, store.Count())
+	assert.Equal(t, 0, store.ExpiringCount())
+	assert.Eventually(t, func() bool { return store.LazyFreed() == 1000 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, int64(0), store.LazyFreePending())
+
+	// The store stays usable
+	store.Set("k", []byte("v"), 0)
+	assert.True(t, store.Exists("k"))
+}
+
+func TestStore_LazyExpire(t *testing.T) {
+	store := New()
+	defer store.Close()
+
+	store.SetCleanupInterval(10 * time.Millisecond)
+	store.Set("eager", []byte("v"), time.Millisecond)
+	assert.Eventually(t, func() bool { return store.ExpiredKeys() == 1 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, int64(0), store.LazyFreed())
+
+	store.SetLazyExpire(true)
+	store.Set("lazy", []byte("v"), time.Millisecond)
+	assert.Eventually(t, func() bool { return store.LazyFreed() == 1 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, int64(2), store.ExpiredKeys())
+}
+
+func TestLazyFreer_FullQueue(t *testing.T) {
+	// Without a worker, batches past the queue are released by the caller
+	f := newLazyFreer()
+	for i := 0; i < lazyFreeQueue+10; i++ {
+		f.free(&lazyFreeBatch{values: []*Value{{}}, objects: 1})
+	}
+	assert.Equal(t, int64(lazyFreeQueue), f.pending.Load())
+	assert.Equal(t, int64(10), f.freed.Load())
+}
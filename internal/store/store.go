@@ -1,205 +1,582 @@
 package store
 
 import (
+	"hash/maphash"
+	"math/bits"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/Shaso41/Backend-SystemFocus/internal/dict"
 )
 
-// Value represents a stored value with metadata
+// Value represents a stored value with metadata. Data is binary safe and
+// never modified in place once stored, so slices handed out by Get remain
+// valid after later writes to the key.
 type Value struct {
-	Data      string
+	Data      []byte
 	CreatedAt time.Time
 }
 
-// Store is a thread-safe in-memory key-value store
+// DefaultCleanupInterval is how often expired keys are removed unless
+// changed with SetCleanupInterval
+const DefaultCleanupInterval = time.Second
+
+// DefaultShards is the number of shards New splits the keyspace into
+const DefaultShards = 64
+
+// activeRehashTime is how long each background cleanup may spend per shard
+// moving the buckets of resizing tables, so that resizes finish even when
+// no more writes come
+const activeRehashTime = time.Millisecond
+
+// Store is a thread-safe in-memory key-value store. Keys are spread by
+// hash over independently locked shards, so writes to different keys
+// rarely wait on each other. Operations on several keys lock every shard
+// involved in index order, which keeps them atomic without deadlocking.
+//
+// A store handed to a single goroutine with RunOn takes no locks at all.
 type Store struct {
+	shards []shard
+	mask   uint64
+	seed   maphash.Seed
+	stopCh chan struct{}
+
+	// The cleanup interval, in nanoseconds, and the signal that it changed
+	interval   atomic.Int64
+	intervalCh chan struct{}
+
+	// Passes the function set by RunOn to the cleanup goroutine
+	runOnCh chan func(job func()) bool
+
+	// Told how long each removal of expired keys took
+	expireCycle atomic.Pointer[func(time.Duration)]
+
+	// Releases unlinked values and flushed keyspaces in the background,
+	// and the values of expired keys when lazyExpire is set
+	lazy       *lazyFreer
+	lazyExpire atomic.Bool
+}
+
+// shard holds the keys hashing to it under its own lock, along with its
+// share of the store's counters so that readers on different shards do
+// not contend on them
+type shard struct {
 	mu      sync.RWMutex
-	data    map[string]*Value
-	expires map[string]time.Time
-	stopCh  chan struct{}
+	data    *dict.Dict[*Value]
+	expires *dict.Dict[time.Time]
+
+	// owned is set by RunOn, after which mu is not used
+	owned bool
+
+	// Keys removed by the background cleanup
+	expiredKeys atomic.Int64
+
+	// Reads that found, or did not find, their key
+	keyspaceHits   atomic.Int64
+	keyspaceMisses atomic.Int64
+
+	// Keeps neighbouring shards off the same cache lines
+	_ [64]byte
 }
 
-// New creates a new Store instance and starts the cleanup goroutine
+// New creates a new Store instance with DefaultShards shards and starts
+// the cleanup goroutine
 func New() *Store {
+	return NewSharded(DefaultShards)
+}
+
+// NewSharded creates a new Store instance split into n shards, rounded up
+// to a power of two, and starts the cleanup goroutine
+func NewSharded(n int) *Store {
+	n = 1 << bits.Len(uint(max(n, 1)-1))
 	s := &Store{
-		data:    make(map[string]*Value),
-		expires: make(map[string]time.Time),
-		stopCh:  make(chan struct{}),
+		shards:     make([]shard, n),
+		mask:       uint64(n - 1),
+		seed:       maphash.MakeSeed(),
+		stopCh:     make(chan struct{}),
+		intervalCh: make(chan struct{}, 1),
+		runOnCh:    make(chan func(job func()) bool),
+		lazy:       newLazyFreer(),
+	}
+	for i := range s.shards {
+		s.shards[i].data = dict.New[*Value]()
+		s.shards[i].expires = dict.New[time.Time]()
 	}
-	
-	// Start background cleanup goroutine
+
+	// Start background cleanup and lazy free goroutines
 	go s.cleanupExpired()
-	
+	go s.lazy.run(s.stopCh)
+
 	return s
 }
 
-// Set stores a key-value pair with optional expiration
-func (s *Store) Set(key, value string, expiration time.Duration) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	
-	s.data[key] = &Value{
+// RunOn hands the store to a single goroutine. Every later operation must
+// be made on that goroutine, and the store no longer takes any locks;
+// run must run job there, after the jobs passed before it, and report
+// false if it never will. The removal of expired keys and the rehashing
+// of resizing tables are passed to run a shard at a time, so commands
+// waiting for the goroutine run in between.
+//
+// RunOn must be called before the store is used by more than one
+// goroutine, and before run starts running jobs.
+func (s *Store) RunOn(run func(job func()) bool) {
+	select {
+	case s.runOnCh <- run:
+	case <-s.stopCh:
+		return
+	}
+	// The cleanup goroutine is between cycles, and runs none outside run
+	// from now on
+	for i := range s.shards {
+		s.shards[i].owned = true
+	}
+}
+
+// lock write-locks the shard, unless the store runs on one goroutine
+func (sh *shard) lock() {
+	if !sh.owned {
+		sh.mu.Lock()
+	}
+}
+
+// unlock undoes lock
+func (sh *shard) unlock() {
+	if !sh.owned {
+		sh.mu.Unlock()
+	}
+}
+
+// rlock read-locks the shard, unless the store runs on one goroutine
+func (sh *shard) rlock() {
+	if !sh.owned {
+		sh.mu.RLock()
+	}
+}
+
+// runlock undoes rlock
+func (sh *shard) runlock() {
+	if !sh.owned {
+		sh.mu.RUnlock()
+	}
+}
+
+// shardIndex returns the index of the shard holding key
+func (s *Store) shardIndex(key string) int {
+	return int(maphash.String(s.seed, key) & s.mask)
+}
+
+// shardFor returns the shard holding key
+func (s *Store) shardFor(key string) *shard {
+	return &s.shards[s.shardIndex(key)]
+}
+
+// lockKeys write-locks the shards holding keys, in index order so that
+// concurrent multi-key operations cannot deadlock, and returns the
+// function that unlocks them
+func (s *Store) lockKeys(keys ...string) (unlock func()) {
+	indexes := make([]int, len(keys))
+	for i, key := range keys {
+		indexes[i] = s.shardIndex(key)
+	}
+	sort.Ints(indexes)
+
+	locked := indexes[:0]
+	for i, index := range indexes {
+		if i > 0 && indexes[i-1] == index {
+			continue
+		}
+		s.shards[index].lock()
+		locked = append(locked, index)
+	}
+	return func() {
+		for _, index := range locked {
+			s.shards[index].unlock()
+		}
+	}
+}
+
+// Set stores a key-value pair with optional expiration. The store takes
+// ownership of value, which the caller must not modify afterwards.
+func (s *Store) Set(key string, value []byte, expiration time.Duration) {
+	sh := s.shardFor(key)
+	sh.lock()
+	defer sh.unlock()
+
+	sh.data.Set(key, &Value{
 		Data:      value,
 		CreatedAt: time.Now(),
-	}
-	
+	})
+
 	if expiration > 0 {
-		s.expires[key] = time.Now().Add(expiration)
+		sh.expires.Set(key, time.Now().Add(expiration))
 	} else {
-		delete(s.expires, key)
+		sh.expires.Delete(key)
 	}
 }
 
-// Get retrieves a value by key
-func (s *Store) Get(key string) (string, bool) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	
-	// Check if key is expired
-	if expireTime, exists := s.expires[key]; exists {
-		if time.Now().After(expireTime) {
-			return "", false
-		}
-	}
-	
-	val, exists := s.data[key]
+// Get retrieves a value by key. The returned slice must not be modified.
+func (s *Store) Get(key string) ([]byte, bool) {
+	sh := s.shardFor(key)
+	sh.rlock()
+	defer sh.runlock()
+
+	val, exists := sh.lookupRead(key)
 	if !exists {
-		return "", false
+		return nil, false
 	}
-	
+
 	return val.Data, true
 }
 
 // Delete removes a key from the store
 func (s *Store) Delete(key string) bool {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	
-	_, exists := s.data[key]
+	sh := s.shardFor(key)
+	sh.lock()
+	defer sh.unlock()
+
+	_, exists := sh.data.Delete(key)
 	if exists {
-		delete(s.data, key)
-		delete(s.expires, key)
+		sh.expires.Delete(key)
 	}
-	
+
 	return exists
 }
 
 // Exists checks if a key exists and is not expired
 func (s *Store) Exists(key string) bool {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	
-	// Check if key is expired
-	if expireTime, exists := s.expires[key]; exists {
-		if time.Now().After(expireTime) {
-			return false
-		}
-	}
-	
-	_, exists := s.data[key]
+	sh := s.shardFor(key)
+	sh.rlock()
+	defer sh.runlock()
+
+	_, exists := sh.lookupRead(key)
 	return exists
 }
 
 // Keys returns all non-expired keys matching a simple pattern
-// For simplicity, only supports "*" wildcard
+// For simplicity, only supports "*" wildcard. Shards are visited one at a
+// time, so keys written meanwhile may or may not be included.
 func (s *Store) Keys(pattern string) []string {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	
 	keys := make([]string, 0)
 	now := time.Now()
-	
-	for key := range s.data {
-		// Check if expired
-		if expireTime, exists := s.expires[key]; exists {
-			if now.After(expireTime) {
-				continue
+
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.rlock()
+		sh.data.Range(func(key string, _ *Value) bool {
+			// Check if expired
+			if expireTime, exists := sh.expires.Get(key); exists {
+				if now.After(expireTime) {
+					return true
+				}
+			}
+
+			// Simple pattern matching (only supports "*")
+			if pattern == "*" {
+				keys = append(keys, key)
+			}
+			return true
+		})
+		sh.runlock()
+	}
+
+	return keys
+}
+
+// Scan returns some of the non-expired keys, starting from cursor, and
+// the cursor to continue from, which is 0 once every key was returned. A
+// full scan starts from cursor 0 and returns every key present throughout
+// at least once, though some may come up more than once.
+//
+// The low bits of the cursor select a shard and the high bits are the
+// cursor of its dict. Each call stops once it has count keys or visited
+// ten times as many buckets.
+func (s *Store) Scan(cursor uint64, count int) (uint64, []string) {
+	shift := bits.OnesCount64(s.mask)
+	index, next := cursor&s.mask, cursor>>shift
+	keys := make([]string, 0, count)
+	now := time.Now()
+
+	for visits := 0; len(keys) < count && visits < count*10; {
+		sh := &s.shards[index]
+		sh.rlock()
+		for len(keys) < count && visits < count*10 {
+			next = sh.data.Scan(next, func(key string, _ *Value) {
+				if expireTime, exists := sh.expires.Get(key); !exists || !now.After(expireTime) {
+					keys = append(keys, key)
+				}
+			})
+			visits++
+			if next == 0 {
+				break
 			}
 		}
-		
-		// Simple pattern matching (only supports "*")
-		if pattern == "*" {
-			keys = append(keys, key)
+		sh.runlock()
+
+		if next == 0 {
+			if index == s.mask {
+				return 0, keys
+			}
+			index++
 		}
 	}
-	
-	return keys
+	return next<<shift | index, keys
 }
 
 // Expire sets an expiration time on an existing key
 func (s *Store) Expire(key string, duration time.Duration) bool {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	
-	if _, exists := s.data[key]; !exists {
+	sh := s.shardFor(key)
+	sh.lock()
+	defer sh.unlock()
+
+	if _, exists := sh.data.Get(key); !exists {
 		return false
 	}
-	
-	s.expires[key] = time.Now().Add(duration)
+
+	sh.expires.Set(key, time.Now().Add(duration))
 	return true
 }
 
 // TTL returns the time-to-live for a key in seconds
 // Returns -1 if key doesn't exist, -2 if key exists but has no expiration
 func (s *Store) TTL(key string) int64 {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	
-	if _, exists := s.data[key]; !exists {
+	sh := s.shardFor(key)
+	sh.rlock()
+	defer sh.runlock()
+
+	if _, exists := sh.data.Get(key); !exists {
 		return -1
 	}
-	
-	expireTime, hasExpiration := s.expires[key]
+
+	expireTime, hasExpiration := sh.expires.Get(key)
 	if !hasExpiration {
 		return -2
 	}
-	
+
 	ttl := time.Until(expireTime).Seconds()
 	if ttl < 0 {
 		return -1
 	}
-	
+
 	return int64(ttl)
 }
 
 // Count returns the number of keys in the store
 func (s *Store) Count() int {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	
-	return len(s.data)
+	n := 0
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.rlock()
+		n += sh.data.Len()
+		sh.runlock()
+	}
+	return n
+}
+
+// ExpiringCount returns the number of keys with an expiration
+func (s *Store) ExpiringCount() int {
+	n := 0
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.rlock()
+		n += sh.expires.Len()
+		sh.runlock()
+	}
+	return n
 }
 
-// Close stops the cleanup goroutine
+// ExpiredKeys returns the number of expired keys removed so far
+func (s *Store) ExpiredKeys() int64 {
+	return s.sum(func(sh *shard) *atomic.Int64 { return &sh.expiredKeys })
+}
+
+// KeyspaceHits returns the number of reads that found their key
+func (s *Store) KeyspaceHits() int64 {
+	return s.sum(func(sh *shard) *atomic.Int64 { return &sh.keyspaceHits })
+}
+
+// KeyspaceMisses returns the number of reads that did not find their key
+func (s *Store) KeyspaceMisses() int64 {
+	return s.sum(func(sh *shard) *atomic.Int64 { return &sh.keyspaceMisses })
+}
+
+// ResetStats clears the counts of expired keys, keyspace hits and misses
+// and objects freed in the background
+func (s *Store) ResetStats() {
+	s.lazy.freed.Store(0)
+	for i := range s.shards {
+		s.shards[i].expiredKeys.Store(0)
+		s.shards[i].keyspaceHits.Store(0)
+		s.shards[i].keyspaceMisses.Store(0)
+	}
+}
+
+// sum adds up one counter over all shards
+func (s *Store) sum(counter func(sh *shard) *atomic.Int64) int64 {
+	var n int64
+	for i := range s.shards {
+		n += counter(&s.shards[i]).Load()
+	}
+	return n
+}
+
+// Close stops the cleanup and lazy free goroutines
 func (s *Store) Close() {
 	close(s.stopCh)
 }
 
-// cleanupExpired runs in the background and removes expired keys
+// SetCleanupInterval changes how often expired keys are removed in the
+// background, starting from the next tick. It does not wait for the
+// cleanup goroutine, which may itself be waiting for the caller when the
+// store runs on a single goroutine.
+func (s *Store) SetCleanupInterval(d time.Duration) {
+	s.interval.Store(int64(d))
+	select {
+	case s.intervalCh <- struct{}{}:
+	default:
+		// A change is already signalled, and picks up d
+	}
+}
+
+// OnExpireCycle registers fn to be told how long each background removal
+// of expired keys took, replacing any function registered before
+func (s *Store) OnExpireCycle(fn func(elapsed time.Duration)) {
+	s.expireCycle.Store(&fn)
+}
+
+// cleanupExpired runs in the background, removing expired keys and
+// finishing resizes
 func (s *Store) cleanupExpired() {
-	ticker := time.NewTicker(1 * time.Second)
+	ticker := time.NewTicker(DefaultCleanupInterval)
 	defer ticker.Stop()
-	
+
+	// Until RunOn, the cycles run here, locking a shard at a time
+	run := func(job func()) bool {
+		job()
+		return true
+	}
 	for {
 		select {
 		case <-ticker.C:
-			s.removeExpiredKeys()
+			if !s.cleanup(run) {
+				return
+			}
+		case <-s.intervalCh:
+			ticker.Reset(time.Duration(s.interval.Load()))
+		case run = <-s.runOnCh:
 		case <-s.stopCh:
 			return
 		}
 	}
 }
 
-// removeExpiredKeys removes all expired keys from the store
-func (s *Store) removeExpiredKeys() {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	
-	now := time.Now()
-	for key, expireTime := range s.expires {
+// cleanup removes expired keys and then moves buckets of resizing tables,
+// passing each shard to run as a separate job, and reports the time the
+// removals took to the expire cycle function. It reports false if run
+// refused a job.
+func (s *Store) cleanup(run func(job func()) bool) bool {
+	var batch *lazyFreeBatch
+	if s.lazyExpire.Load() {
+		batch = &lazyFreeBatch{}
+	}
+	var elapsed time.Duration
+	for i := range s.shards {
+		sh := &s.shards[i]
+		if !run(func() {
+			start := time.Now()
+			sh.removeExpiredKeys(start, batch)
+			elapsed += time.Since(start)
+			sh.rehash()
+		}) {
+			return false
+		}
+	}
+
+	if batch != nil {
+		batch.objects = int64(len(batch.values))
+		s.lazy.free(batch)
+	}
+	if fn := s.expireCycle.Load(); fn != nil {
+		(*fn)(elapsed)
+	}
+	return true
+}
+
+// rehash moves buckets of the shard's resizing tables, holding the shard
+// for at most activeRehashTime
+func (sh *shard) rehash() {
+	sh.lock()
+	defer sh.unlock()
+
+	start := time.Now()
+	for sh.data.Rehash(100) && time.Since(start) < activeRehashTime {
+	}
+	for sh.expires.Rehash(100) && time.Since(start) < activeRehashTime {
+	}
+}
+
+// lookup returns the live value for key, treating expired keys as missing.
+// The caller must have locked the shard.
+func (sh *shard) lookup(key string, now time.Time) (*Value, bool) {
+	if expireTime, exists := sh.expires.Get(key); exists && now.After(expireTime) {
+		return nil, false
+	}
+	return sh.data.Get(key)
+}
+
+// lookupRead is lookup for commands reading the key, counting the read as
+// a keyspace hit or miss. The clock is only read for keys with an
+// expiration. The caller must have locked the shard.
+func (sh *shard) lookupRead(key string) (*Value, bool) {
+	val, exists := sh.data.Get(key)
+	if expireTime, expiring := sh.expires.Get(key); exists && expiring && time.Now().After(expireTime) {
+		val, exists = nil, false
+	}
+	if exists {
+		sh.keyspaceHits.Add(1)
+	} else {
+		sh.keyspaceMisses.Add(1)
+	}
+	return val, exists
+}
+
+// overwrite replaces the data stored at key while keeping any expiration
+// of a live key. An expired key is replaced by a fresh, persistent one.
+// The caller must have write-locked the shard.
+func (sh *shard) overwrite(key string, data []byte, now time.Time) {
+	if val, exists := sh.lookup(key, now); exists {
+		val.Data = data
+		return
+	}
+	sh.data.Set(key, &Value{
+		Data:      data,
+		CreatedAt: now,
+	})
+	sh.expires.Delete(key)
+}
+
+// removeExpiredKeys removes the keys of the shard that expired by now,
+// adding their values to batch unless it is nil
+func (sh *shard) removeExpiredKeys(now time.Time, batch *lazyFreeBatch) {
+	sh.lock()
+	defer sh.unlock()
+
+	// Deleting moves buckets between tables, so the keys are collected
+	// before any is removed
+	var expired []string
+	sh.expires.Range(func(key string, expireTime time.Time) bool {
 		if now.After(expireTime) {
-			delete(s.data, key)
-			delete(s.expires, key)
+			expired = append(expired, key)
+		}
+		return true
+	})
+	for _, key := range expired {
+		val, _ := sh.data.Delete(key)
+		if batch != nil {
+			batch.values = append(batch.values, val)
 		}
+		sh.expires.Delete(key)
+		sh.expiredKeys.Add(1)
 	}
 }
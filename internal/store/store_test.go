@@ -1,21 +1,27 @@
 package store
 
 import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestStore_SetAndGet(t *testing.T) {
 	store := New()
 	defer store.Close()
 
-	store.Set("key1", "value1", 0)
-	
+	store.Set("key1", []byte("value1"), 0)
+
 	val, exists := store.Get("key1")
 	assert.True(t, exists)
-	assert.Equal(t, "value1", val)
+	assert.Equal(t, []byte("value1"), val)
 }
 
 func TestStore_GetNonExistent(t *testing.T) {
@@ -30,11 +36,11 @@ func TestStore_Delete(t *testing.T) {
 	store := New()
 	defer store.Close()
 
-	store.Set("key1", "value1", 0)
+	store.Set("key1", []byte("value1"), 0)
 	deleted := store.Delete("key1")
-	
+
 	assert.True(t, deleted)
-	
+
 	_, exists := store.Get("key1")
 	assert.False(t, exists)
 }
@@ -51,8 +57,8 @@ func TestStore_Exists(t *testing.T) {
 	store := New()
 	defer store.Close()
 
-	store.Set("key1", "value1", 0)
-	
+	store.Set("key1", []byte("value1"), 0)
+
 	assert.True(t, store.Exists("key1"))
 	assert.False(t, store.Exists("nonexistent"))
 }
@@ -62,37 +68,167 @@ func TestStore_Expiration(t *testing.T) {
 	defer store.Close()
 
 	// Set key with 100ms expiration
-	store.Set("key1", "value1", 100*time.Millisecond)
-	
+	store.Set("key1", []byte("value1"), 100*time.Millisecond)
+
 	// Should exist immediately
 	val, exists := store.Get("key1")
 	assert.True(t, exists)
-	assert.Equal(t, "value1", val)
-	
+	assert.Equal(t, []byte("value1"), val)
+
 	// Wait for expiration
 	time.Sleep(150 * time.Millisecond)
-	
+
 	// Should not exist after expiration
 	_, exists = store.Get("key1")
 	assert.False(t, exists)
 }
 
+func TestStore_SetCleanupInterval(t *testing.T) {
+	store := New()
+	defer store.Close()
+
+	store.SetCleanupInterval(10 * time.Millisecond)
+	store.Set("key1", []byte("value1"), 20*time.Millisecond)
+	store.Set("key2", []byte("value2"), 0)
+	assert.Equal(t, 2, store.Count())
+	assert.Equal(t, 1, store.ExpiringCount())
+
+	// Removed by the background cleanup well before the default interval
+	assert.Eventually(t, func() bool { return store.Count() == 1 }, 500*time.Millisecond, 10*time.Millisecond)
+	assert.Equal(t, 0, store.ExpiringCount())
+	assert.Equal(t, int64(1), store.ExpiredKeys())
+}
+
+func TestStore_OnExpireCycle(t *testing.T) {
+	store := New()
+	defer store.Close()
+
+	cycles := make(chan time.Duration, 10)
+	store.OnExpireCycle(func(elapsed time.Duration) {
+		select {
+		case cycles <- elapsed:
+		default:
+		}
+	})
+	store.SetCleanupInterval(10 * time.Millisecond)
+
+	select {
+	case elapsed := <-cycles:
+		assert.GreaterOrEqual(t, elapsed, time.Duration(0))
+	case <-time.After(time.Second):
+		t.Fatal("no expire cycle reported")
+	}
+}
+
+func TestStore_RunOn(t *testing.T) {
+	store := New()
+	defer store.Close()
+
+	// One goroutine runs every job, as the server's executor does
+	jobs := make(chan func())
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case job := <-jobs:
+				job()
+			case <-stop:
+				return
+			}
+		}
+	}()
+	run := func(job func()) bool {
+		done := make(chan struct{})
+		select {
+		case jobs <- func() { job(); close(done) }:
+		case <-stop:
+			return false
+		}
+		<-done
+		return true
+	}
+	do := func(job func()) {
+		finished := make(chan struct{})
+		go func() {
+			run(job)
+			close(finished)
+		}()
+		select {
+		case <-finished:
+		case <-time.After(2 * time.Second):
+			t.Fatal("job did not finish")
+		}
+	}
+
+	var cleanups atomic.Int64
+	store.RunOn(func(job func()) bool {
+		cleanups.Add(1)
+		return run(job)
+	})
+
+	// The store no longer locks its shards, which are held here to prove it
+	for i := range store.shards {
+		store.shards[i].mu.Lock()
+	}
+	do(func() {
+		store.Set("key1", []byte("value1"), 20*time.Millisecond)
+		store.Set("key2", []byte("value2"), 0)
+		store.SetCleanupInterval(10 * time.Millisecond)
+	})
+
+	// Expired keys are removed by jobs of the same goroutine
+	assert.Eventually(t, func() bool {
+		n := 0
+		do(func() { n = store.Count() })
+		return n == 1
+	}, time.Second, 10*time.Millisecond)
+	assert.GreaterOrEqual(t, cleanups.Load(), int64(len(store.shards)))
+	do(func() {
+		_, exists := store.Get("key2")
+		assert.True(t, exists)
+		assert.Equal(t, int64(1), store.ExpiredKeys())
+	})
+}
+
+func TestStore_KeyspaceHits(t *testing.T) {
+	store := New()
+	defer store.Close()
+
+	store.Set("key1", []byte("value1"), 0)
+	store.Get("key1")
+	store.Get("missing")
+	store.Exists("key1")
+	store.GetBit("missing", 0)
+	assert.Equal(t, int64(2), store.KeyspaceHits())
+	assert.Equal(t, int64(2), store.KeyspaceMisses())
+
+	// Writes are not reads
+	store.Set("key2", []byte("value2"), 0)
+	store.Delete("key2")
+	assert.Equal(t, int64(2), store.KeyspaceHits())
+
+	store.ResetStats()
+	assert.Equal(t, int64(0), store.KeyspaceHits())
+	assert.Equal(t, int64(0), store.KeyspaceMisses())
+}
+
 func TestStore_Expire(t *testing.T) {
 	store := New()
 	defer store.Close()
 
-	store.Set("key1", "value1", 0)
-	
+	store.Set("key1", []byte("value1"), 0)
+
 	// Set expiration
 	success := store.Expire("key1", 100*time.Millisecond)
 	assert.True(t, success)
-	
+
 	// Should exist immediately
 	assert.True(t, store.Exists("key1"))
-	
+
 	// Wait for expiration
 	time.Sleep(150 * time.Millisecond)
-	
+
 	// Should not exist after expiration
 	assert.False(t, store.Exists("key1"))
 }
@@ -104,14 +240,14 @@ func TestStore_TTL(t *testing.T) {
 	// Key doesn't exist
 	ttl := store.TTL("nonexistent")
 	assert.Equal(t, int64(-1), ttl)
-	
+
 	// Key exists without expiration
-	store.Set("key1", "value1", 0)
+	store.Set("key1", []byte("value1"), 0)
 	ttl = store.TTL("key1")
 	assert.Equal(t, int64(-2), ttl)
-	
+
 	// Key exists with expiration
-	store.Set("key2", "value2", 10*time.Second)
+	store.Set("key2", []byte("value2"), 10*time.Second)
 	ttl = store.TTL("key2")
 	assert.True(t, ttl > 0 && ttl <= 10)
 }
@@ -120,10 +256,10 @@ func TestStore_Keys(t *testing.T) {
 	store := New()
 	defer store.Close()
 
-	store.Set("key1", "value1", 0)
-	store.Set("key2", "value2", 0)
-	store.Set("key3", "value3", 0)
-	
+	store.Set("key1", []byte("value1"), 0)
+	store.Set("key2", []byte("value2"), 0)
+	store.Set("key3", []byte("value3"), 0)
+
 	keys := store.Keys("*")
 	assert.Equal(t, 3, len(keys))
 }
@@ -133,12 +269,12 @@ func TestStore_Count(t *testing.T) {
 	defer store.Close()
 
 	assert.Equal(t, 0, store.Count())
-	
-	store.Set("key1", "value1", 0)
-	store.Set("key2", "value2", 0)
-	
+
+	store.Set("key1", []byte("value1"), 0)
+	store.Set("key2", []byte("value2"), 0)
+
 	assert.Equal(t, 2, store.Count())
-	
+
 	store.Delete("key1")
 	assert.Equal(t, 1, store.Count())
 }
@@ -148,26 +284,164 @@ func TestStore_ConcurrentAccess(t *testing.T) {
 	defer store.Close()
 
 	done := make(chan bool)
-	
+
 	// Multiple goroutines writing
 	for i := 0; i < 10; i++ {
 		go func(n int) {
 			for j := 0; j < 100; j++ {
 				key := "key"
-				store.Set(key, "value", 0)
+				store.Set(key, []byte("value"), 0)
 				store.Get(key)
 				store.Delete(key)
 			}
 			done <- true
 		}(i)
 	}
-	
+
 	// Wait for all goroutines
 	for i := 0; i < 10; i++ {
 		<-done
 	}
 }
 
+func TestStore_Shards(t *testing.T) {
+	for _, tc := range []struct{ n, want int }{{0, 1}, {1, 1}, {3, 4}, {64, 64}, {100, 128}} {
+		store := NewSharded(tc.n)
+		assert.Len(t, store.shards, tc.want, tc.n)
+		store.Close()
+	}
+
+	store := New()
+	defer store.Close()
+	require.Len(t, store.shards, DefaultShards)
+
+	for i := 0; i < 1000; i++ {
+		store.Set(strconv.Itoa(i), []byte("value"), 0)
+	}
+	store.Set("expiring", []byte("value"), time.Hour)
+	assert.Equal(t, 1001, store.Count())
+	assert.Equal(t, 1, store.ExpiringCount())
+	assert.Len(t, store.Keys("*"), 1001)
+
+	// Keys are spread over the shards
+	used := 0
+	for i := range store.shards {
+		if store.shards[i].data.Len() > 0 {
+			used++
+		}
+	}
+	assert.Greater(t, used, DefaultShards/2)
+}
+
+// scanAll runs a full scan of store, calling between after every call,
+// and returns how many times each key came up
+func scanAll(store *Store, count int, between func()) map[string]int {
+	seen := make(map[string]int)
+	cursor := uint64(0)
+	for {
+		var keys []string
+		cursor, keys = store.Scan(cursor, count)
+		for _, key := range keys {
+			seen[key]++
+		}
+		if cursor == 0 {
+			return seen
+		}
+		between()
+	}
+}
+
+func TestStore_Scan(t *testing.T) {
+	store := New()
+	defer store.Close()
+
+	next, keys := store.Scan(0, 10)
+	assert.Equal(t, uint64(0), next)
+	assert.Empty(t, keys)
+
+	for i := 0; i < 1000; i++ {
+		store.Set(strconv.Itoa(i), []byte("value"), 0)
+	}
+	store.Set("expired", []byte("value"), time.Nanosecond)
+	time.Sleep(time.Millisecond)
+
+	// Calls stop at about count keys, and expired keys are left out
+	next, keys = store.Scan(0, 5)
+	assert.NotEqual(t, uint64(0), next)
+	assert.GreaterOrEqual(t, len(keys), 5)
+	assert.Less(t, len(keys), 20)
+	seen := scanAll(store, 10, func() {})
+	assert.Len(t, seen, 1000)
+	assert.NotContains(t, seen, "expired")
+
+	// Keys present throughout are returned while the shards' tables grow
+	added := 0
+	seen = scanAll(store, 10, func() {
+		for i := 0; i < 100 && added < 20000; i++ {
+			store.Set("new"+strconv.Itoa(added), []byte("value"), 0)
+			added++
+		}
+	})
+	for i := 0; i < 1000; i++ {
+		assert.Positive(t, seen[strconv.Itoa(i)], i)
+	}
+}
+
+func TestStore_Rehash(t *testing.T) {
+	store := NewSharded(1)
+	defer store.Close()
+
+	// Resizes left unfinished by writes are finished in the background
+	sh := &store.shards[0]
+	for i := 0; !sh.data.Rehashing(); i++ {
+		store.Set(strconv.Itoa(i), []byte("value"), time.Hour)
+	}
+	sh.rehash()
+	assert.False(t, sh.data.Rehashing())
+	assert.False(t, sh.expires.Rehashing())
+}
+
+func TestStore_LockKeys(t *testing.T) {
+	store := NewSharded(4)
+	defer store.Close()
+
+	// Shared shards are locked once, and every shard involved is locked
+	// until unlocked
+	var keys []string
+	covered := make(map[int]bool)
+	for i := 0; len(keys) < 16 || len(covered) < len(store.shards); i++ {
+		key := strconv.Itoa(i)
+		keys = append(keys, key)
+		covered[store.shardIndex(key)] = true
+	}
+	unlock := store.lockKeys(keys...)
+	for i := range store.shards {
+		assert.False(t, store.shards[i].mu.TryLock(), i)
+	}
+	unlock()
+	for i := range store.shards {
+		require.True(t, store.shards[i].mu.TryLock(), i)
+		store.shards[i].mu.Unlock()
+	}
+
+	// Multi-key operations naming keys in opposite orders do not deadlock
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(reverse bool) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				if reverse {
+					store.BitOp(BitOpOr, keys[15], []string{keys[3], keys[0]})
+				} else {
+					store.BitOp(BitOpOr, keys[0], []string{keys[3], keys[15]})
+				}
+			}
+		}(i%2 == 1)
+	}
+	wg.Wait()
+}
+
 // Benchmarks
 func BenchmarkStore_Set(b *testing.B) {
 	store := New()
@@ -175,15 +449,15 @@ func BenchmarkStore_Set(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		store.Set("key", "value", 0)
+		store.Set("key", []byte("value"), 0)
 	}
 }
 
 func BenchmarkStore_Get(b *testing.B) {
 	store := New()
 	defer store.Close()
-	
-	store.Set("key", "value", 0)
+
+	store.Set("key", []byte("value"), 0)
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
@@ -197,7 +471,7 @@ func BenchmarkStore_ConcurrentSet(b *testing.B) {
 
 	b.RunParallel(func(pb *testing.PB) {
 		for pb.Next() {
-			store.Set("key", "value", 0)
+			store.Set("key", []byte("value"), 0)
 		}
 	})
 }
@@ -205,8 +479,8 @@ func BenchmarkStore_ConcurrentSet(b *testing.B) {
 func BenchmarkStore_ConcurrentGet(b *testing.B) {
 	store := New()
 	defer store.Close()
-	
-	store.Set("key", "value", 0)
+
+	store.Set("key", []byte("value"), 0)
 
 	b.RunParallel(func(pb *testing.PB) {
 		for pb.Next() {
@@ -214,3 +488,82 @@ func BenchmarkStore_ConcurrentGet(b *testing.B) {
 		}
 	})
 }
+
+// BenchmarkStore_ParallelSet writes distinct keys from every goroutine,
+// with a single shard standing in for one store-wide lock. Run with
+// -cpu 1,2,4,8 to see write throughput scale with GOMAXPROCS.
+func BenchmarkStore_ParallelSet(b *testing.B) {
+	for _, shards := range []int{1, DefaultShards} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			store := NewSharded(shards)
+			defer store.Close()
+
+			keys := make([]string, 1024)
+			for i := range keys {
+				keys[i] = "key:" + strconv.Itoa(i)
+			}
+			value := []byte("value")
+
+			b.RunParallel(func(pb *testing.PB) {
+				i := rand.Intn(len(keys))
+				for pb.Next() {
+					store.Set(keys[i%len(keys)], value, 0)
+					i++
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkStore_ParallelMixed runs one SET for every four GETs of
+// distinct keys
+func BenchmarkStore_ParallelMixed(b *testing.B) {
+	for _, shards := range []int{1, DefaultShards} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			store := NewSharded(shards)
+			defer store.Close()
+
+			keys := make([]string, 1024)
+			for i := range keys {
+				keys[i] = "key:" + strconv.Itoa(i)
+				store.Set(keys[i], []byte("value"), 0)
+			}
+			value := []byte("value")
+
+			b.RunParallel(func(pb *testing.PB) {
+				i := rand.Intn(len(keys))
+				for pb.Next() {
+					key := keys[i%len(keys)]
+					if i%5 == 0 {
+						store.Set(key, value, 0)
+					} else {
+						store.Get(key)
+					}
+					i++
+				}
+			})
+		})
+	}
+}
+
+func TestStore_BinaryValues(t *testing.T) {
+	store := New()
+	defer store.Close()
+
+	value := []byte("line1\r\nline2\x00\xff\r\n")
+	store.Set("bin\x00key", value, 0)
+
+	val, exists := store.Get("bin\x00key")
+	assert.True(t, exists)
+	assert.Equal(t, value, val)
+
+	large := make([]byte, 8<<20)
+	for i := range large {
+		large[i] = byte(i % 251)
+	}
+	store.Set("large", large, 0)
+
+	val, exists = store.Get("large")
+	assert.True(t, exists)
+	assert.Equal(t, large, val)
+}
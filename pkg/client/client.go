@@ -2,8 +2,12 @@ package client
 
 import (
 	"bufio"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io"
 	"net"
+	"os"
 	"strconv"
 	"strings"
 )
@@ -14,9 +18,70 @@ type Client struct {
 	reader *bufio.Reader
 }
 
-// New creates a new Redis client
-func New(address string) (*Client, error) {
-	conn, err := net.Dial("tcp", address)
+// Option configures how a client connects
+type Option func(*options)
+
+type options struct {
+	tlsConfig *tls.Config
+}
+
+// WithTLS connects over TLS using config. When config sets no ServerName,
+// the host part of the address is verified.
+func WithTLS(config *tls.Config) Option {
+	return func(o *options) {
+		o.tlsConfig = config
+	}
+}
+
+// LoadTLSConfig builds a TLS configuration from PEM files: caFile holds the
+// authorities trusted to sign the server certificate (the system pool is
+// used when empty), and certFile and keyFile an optional client
+// certificate for servers that require one.
+func LoadTLSConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load CA certificates: %w", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+	}
+
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// New creates a new Redis client connected to address, either a TCP
+// host:port or a unix socket given as unix:///path
+func New(address string, opts ...Option) (*Client, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	network := "tcp"
+	if path, ok := strings.CutPrefix(address, "unix://"); ok {
+		network, address = "unix", path
+	}
+
+	var conn net.Conn
+	var err error
+	if o.tlsConfig != nil {
+		conn, err = tls.Dial(network, address, o.tlsConfig)
+	} else {
+		conn, err = net.Dial(network, address)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect: %w", err)
 	}
@@ -32,6 +97,24 @@ func (c *Client) Close() error {
 	return c.conn.Close()
 }
 
+// Auth authenticates the connection as the default user
+func (c *Client) Auth(password string) error {
+	if err := c.sendCommand("AUTH", password); err != nil {
+		return err
+	}
+	_, err := c.readSimpleString()
+	return err
+}
+
+// AuthUser authenticates the connection as the named ACL user
+func (c *Client) AuthUser(username, password string) error {
+	if err := c.sendCommand("AUTH", username, password); err != nil {
+		return err
+	}
+	_, err := c.readSimpleString()
+	return err
+}
+
 // Ping sends a PING command
 func (c *Client) Ping() (string, error) {
 	if err := c.sendCommand("PING"); err != nil {
@@ -58,6 +141,15 @@ func (c *Client) SetEx(key, value string, seconds int) error {
 	return err
 }
 
+// SetBytes sets a key to a binary value
+func (c *Client) SetBytes(key string, value []byte) error {
+	if err := c.writeCommand([]byte("SET"), []byte(key), value); err != nil {
+		return err
+	}
+	_, err := c.readSimpleString()
+	return err
+}
+
 // Get gets a value by key
 func (c *Client) Get(key string) (string, error) {
 	if err := c.sendCommand("GET", key); err != nil {
@@ -66,6 +158,14 @@ func (c *Client) Get(key string) (string, error) {
 	return c.readBulkString()
 }
 
+// GetBytes gets a binary value by key. A missing key returns a nil slice.
+func (c *Client) GetBytes(key string) ([]byte, error) {
+	if err := c.sendCommand("GET", key); err != nil {
+		return nil, err
+	}
+	return c.readBulk()
+}
+
 // Delete deletes a key
 func (c *Client) Delete(key string) (int64, error) {
 	if err := c.sendCommand("DEL", key); err != nil {
@@ -110,14 +210,34 @@ func (c *Client) TTL(key string) (int64, error) {
 
 // sendCommand sends a RESP array command
 func (c *Client) sendCommand(args ...string) error {
+	bargs := make([][]byte, len(args))
+	for i, arg := range args {
+		bargs[i] = []byte(arg)
+	}
+	return c.writeCommand(bargs...)
+}
+
+// writeCommand sends a RESP array command with binary-safe arguments
+func (c *Client) writeCommand(args ...[]byte) error {
+	size := 16
+	for _, arg := range args {
+		size += len(arg) + 16
+	}
+
 	// Build RESP array
-	var cmd strings.Builder
-	cmd.WriteString(fmt.Sprintf("*%d\r\n", len(args)))
+	cmd := make([]byte, 0, size)
+	cmd = append(cmd, '*')
+	cmd = strconv.AppendInt(cmd, int64(len(args)), 10)
+	cmd = append(cmd, '\r', '\n')
 	for _, arg := range args {
-		cmd.WriteString(fmt.Sprintf("$%d\r\n%s\r\n", len(arg), arg))
+		cmd = append(cmd, '$')
+		cmd = strconv.AppendInt(cmd, int64(len(arg)), 10)
+		cmd = append(cmd, '\r', '\n')
+		cmd = append(cmd, arg...)
+		cmd = append(cmd, '\r', '\n')
 	}
 
-	_, err := c.conn.Write([]byte(cmd.String()))
+	_, err := c.conn.Write(cmd)
 	return err
 }
 
@@ -161,32 +281,48 @@ func (c *Client) readInteger() (int64, error) {
 
 // readBulkString reads a RESP bulk string
 func (c *Client) readBulkString() (string, error) {
+	b, err := c.readBulk()
+	return string(b), err
+}
+
+// readBulk reads a binary-safe RESP bulk string. A null bulk string is
+// returned as a nil slice.
+func (c *Client) readBulk() ([]byte, error) {
 	line, err := c.reader.ReadString('\n')
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	line = strings.TrimSpace(line)
+	if len(line) > 0 && line[0] == '-' {
+		return nil, fmt.Errorf("%s", line[1:])
+	}
 	if len(line) == 0 || line[0] != '$' {
-		return "", fmt.Errorf("invalid bulk string response")
+		return nil, fmt.Errorf("invalid bulk string response")
 	}
 
 	length, err := strconv.Atoi(line[1:])
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if length == -1 {
-		return "", nil // Null
+		return nil, nil // Null
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("invalid bulk string length: %d", length)
 	}
 
+	// The payload may arrive in several reads, so read it in full
 	buf := make([]byte, length+2) // +2 for \r\n
-	_, err = c.reader.Read(buf)
-	if err != nil {
-		return "", err
+	if _, err := io.ReadFull(c.reader, buf); err != nil {
+		return nil, err
+	}
+	if buf[length] != '\r' || buf[length+1] != '\n' {
+		return nil, fmt.Errorf("bulk string is not terminated by CRLF")
 	}
 
-	return string(buf[:length]), nil
+	return buf[:length], nil
 }
 
 // readArray reads a RESP array
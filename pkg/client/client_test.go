@@ -0,0 +1,143 @@
+package client
+
+import (
+	"bytes"
+	"crypto/tls"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Shaso41/Backend-SystemFocus/internal/server"
+	"github.com/Shaso41/Backend-SystemFocus/internal/tlstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func startServer(t *testing.T, addr string, opts ...server.Option) {
+	srv := server.New(addr, opts...)
+	go srv.Start()
+	time.Sleep(100 * time.Millisecond)
+	t.Cleanup(srv.Stop)
+}
+
+func TestClient_SetAndGet(t *testing.T) {
+	startServer(t, "localhost:16390")
+
+	c, err := New("localhost:16390")
+	require.NoError(t, err)
+	defer c.Close()
+
+	assert.NoError(t, c.Set("name", "alice"))
+
+	value, err := c.Get("name")
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", value)
+
+	value, err = c.Get("missing")
+	assert.NoError(t, err)
+	assert.Equal(t, "", value)
+}
+
+func TestClient_BinaryValues(t *testing.T) {
+	startServer(t, "localhost:16391")
+
+	c, err := New("localhost:16391")
+	require.NoError(t, err)
+	defer c.Close()
+
+	value := []byte("line1\r\nline2\x00\x01\xff\r\n")
+	assert.NoError(t, c.SetBytes("bin\r\nkey", value))
+
+	got, err := c.GetBytes("bin\r\nkey")
+	assert.NoError(t, err)
+	assert.Equal(t, value, got)
+
+	got, err = c.GetBytes("missing")
+	assert.NoError(t, err)
+	assert.Nil(t, got)
+}
+
+func TestClient_LargeValues(t *testing.T) {
+	startServer(t, "localhost:16392")
+
+	c, err := New("localhost:16392")
+	require.NoError(t, err)
+	defer c.Close()
+
+	// Several megabytes always arrive in more than one read
+	value := bytes.Repeat([]byte("0123456789abcdef\r\n\x00"), 300000)
+	assert.NoError(t, c.SetBytes("large", value))
+
+	got, err := c.GetBytes("large")
+	assert.NoError(t, err)
+	assert.True(t, bytes.Equal(value, got))
+
+	// The connection is still in sync afterwards
+	pong, err := c.Ping()
+	assert.NoError(t, err)
+	assert.Equal(t, "PONG", pong)
+}
+
+func TestClient_Auth(t *testing.T) {
+	aclFile := filepath.Join(t.TempDir(), "users.acl")
+	require.NoError(t, os.WriteFile(aclFile, []byte("user reader on >readpw ~* +@read\n"), 0o600))
+	startServer(t, "localhost:16393", server.WithRequirePass("secret"), server.WithACLFile(aclFile))
+
+	c, err := New("localhost:16393")
+	require.NoError(t, err)
+	defer c.Close()
+
+	_, err = c.Get("k")
+	assert.EqualError(t, err, "NOAUTH Authentication required.")
+
+	assert.Error(t, c.Auth("wrong"))
+	assert.NoError(t, c.Auth("secret"))
+	assert.NoError(t, c.Set("k", "v"))
+
+	assert.NoError(t, c.AuthUser("reader", "readpw"))
+	value, err := c.Get("k")
+	assert.NoError(t, err)
+	assert.Equal(t, "v", value)
+
+	err = c.Set("k", "w")
+	assert.EqualError(t, err, "NOPERM this user has no permissions to run the 'set' command")
+}
+
+func TestClient_TLS(t *testing.T) {
+	files := tlstest.Generate(t, t.TempDir(), 1)
+	startServer(t, "", server.WithTLS("localhost:16394", server.TLSOptions{
+		CertFile:   files.ServerCert,
+		KeyFile:    files.ServerKey,
+		CAFile:     files.CACert,
+		ClientAuth: tls.RequireAndVerifyClientCert,
+	}))
+
+	config, err := LoadTLSConfig(files.CACert, files.ClientCert, files.ClientKey)
+	require.NoError(t, err)
+
+	c, err := New("localhost:16394", WithTLS(config))
+	require.NoError(t, err)
+	defer c.Close()
+
+	assert.NoError(t, c.Set("secure", "yes"))
+	value, err := c.Get("secure")
+	assert.NoError(t, err)
+	assert.Equal(t, "yes", value)
+
+	_, err = LoadTLSConfig(files.ServerKey, "", "")
+	assert.Error(t, err)
+}
+
+func TestClient_UnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "redis.sock")
+	startServer(t, "unix://"+socket)
+
+	c, err := New("unix://" + socket)
+	require.NoError(t, err)
+	defer c.Close()
+
+	pong, err := c.Ping()
+	assert.NoError(t, err)
+	assert.Equal(t, "PONG", pong)
+}
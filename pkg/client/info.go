@@ -0,0 +1,253 @@
+package client
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Info is the reply to INFO. The common fields are parsed into the typed
+// sections, which are left zero when the server did not report them;
+// Sections holds every field as reported.
+type Info struct {
+	Server      ServerInfo
+	Clients     ClientsInfo
+	Memory      MemoryInfo
+	Persistence PersistenceInfo
+	Stats       StatsInfo
+	Replication ReplicationInfo
+	CPU         CPUInfo
+
+	// Keys per database, by name such as "db0"
+	Keyspace map[string]KeyspaceInfo
+
+	// Fields by lower-case section name, then field name
+	Sections map[string]map[string]string
+}
+
+// ServerInfo is the server section of INFO
+type ServerInfo struct {
+	Version   string
+	Mode      string
+	OS        string
+	ArchBits  int64
+	ProcessID int64
+	RunID     string
+	TCPPort   int64
+	Uptime    time.Duration
+	Hz        int64
+}
+
+// ClientsInfo is the clients section of INFO
+type ClientsInfo struct {
+	Connected  int64
+	MaxClients int64
+	Blocked    int64
+}
+
+// MemoryInfo is the memory section of INFO, in bytes
+type MemoryInfo struct {
+	Used            int64
+	RSS             int64
+	MaxMemory       int64
+	MaxMemoryPolicy string
+
+	// Objects waiting to be released in the background
+	LazyfreePending int64
+}
+
+// PersistenceInfo is the persistence section of INFO
+type PersistenceInfo struct {
+	Loading    bool
+	AOFEnabled bool
+
+	// When the server last saved, or zero if it never did
+	LastSave time.Time
+}
+
+// StatsInfo is the stats section of INFO
+type StatsInfo struct {
+	TotalConnections    int64
+	TotalCommands       int64
+	RejectedConnections int64
+	ExpiredKeys         int64
+	EvictedKeys         int64
+	KeyspaceHits        int64
+	KeyspaceMisses      int64
+	ErrorReplies        int64
+	LazyfreedObjects    int64
+}
+
+// ReplicationInfo is the replication section of INFO
+type ReplicationInfo struct {
+	Role              string
+	ConnectedReplicas int64
+}
+
+// CPUInfo is the cpu section of INFO
+type CPUInfo struct {
+	System time.Duration
+	User   time.Duration
+}
+
+// KeyspaceInfo describes the keys of one database
+type KeyspaceInfo struct {
+	Keys    int64
+	Expires int64
+	AvgTTL  time.Duration
+}
+
+// Info returns the named sections of the server's INFO, or the default
+// ones when none are named
+func (c *Client) Info(sections ...string) (*Info, error) {
+	if err := c.sendCommand(append([]string{"INFO"}, sections...)...); err != nil {
+		return nil, err
+	}
+	text, err := c.readBulkString()
+	if err != nil {
+		return nil, err
+	}
+	return ParseInfo(text)
+}
+
+// ParseInfo parses the text of an INFO reply
+func ParseInfo(text string) (*Info, error) {
+	info := &Info{
+		Keyspace: make(map[string]KeyspaceInfo),
+		Sections: make(map[string]map[string]string),
+	}
+
+	var fields map[string]string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if line == "" {
+			continue
+		}
+		if title, ok := strings.CutPrefix(line, "#"); ok {
+			fields = make(map[string]string)
+			info.Sections[strings.ToLower(strings.TrimSpace(title))] = fields
+			continue
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok || fields == nil {
+			return nil, fmt.Errorf("invalid INFO line: %q", line)
+		}
+		fields[name] = value
+	}
+
+	p := infoParser{}
+	if s := info.Sections["server"]; s != nil {
+		info.Server = ServerInfo{
+			Version:   s["redis_version"],
+			Mode:      s["redis_mode"],
+			OS:        s["os"],
+			ArchBits:  p.int(s, "arch_bits"),
+			ProcessID: p.int(s, "process_id"),
+			RunID:     s["run_id"],
+			TCPPort:   p.int(s, "tcp_port"),
+			Uptime:    time.Duration(p.int(s, "uptime_in_seconds")) * time.Second,
+			Hz:        p.int(s, "hz"),
+		}
+	}
+	if s := info.Sections["clients"]; s != nil {
+		info.Clients = ClientsInfo{
+			Connected:  p.int(s, "connected_clients"),
+			MaxClients: p.int(s, "maxclients"),
+			Blocked:    p.int(s, "blocked_clients"),
+		}
+	}
+	if s := info.Sections["memory"]; s != nil {
+		info.Memory = MemoryInfo{
+			Used:            p.int(s, "used_memory"),
+			RSS:             p.int(s, "used_memory_rss"),
+			MaxMemory:       p.int(s, "maxmemory"),
+			MaxMemoryPolicy: s["maxmemory_policy"],
+			LazyfreePending: p.int(s, "lazyfree_pending_objects"),
+		}
+	}
+	if s := info.Sections["persistence"]; s != nil {
+		info.Persistence = PersistenceInfo{
+			Loading:    p.int(s, "loading") == 1,
+			AOFEnabled: p.int(s, "aof_enabled") == 1,
+		}
+		// Servers that never saved report 0
+		if lastSave := p.int(s, "rdb_last_save_time"); lastSave > 0 {
+			info.Persistence.LastSave = time.Unix(lastSave, 0)
+		}
+	}
+	if s := info.Sections["stats"]; s != nil {
+		info.Stats = StatsInfo{
+			TotalConnections:    p.int(s, "total_connections_received"),
+			TotalCommands:       p.int(s, "total_commands_processed"),
+			RejectedConnections: p.int(s, "rejected_connections"),
+			ExpiredKeys:         p.int(s, "expired_keys"),
+			EvictedKeys:         p.int(s, "evicted_keys"),
+			KeyspaceHits:        p.int(s, "keyspace_hits"),
+			KeyspaceMisses:      p.int(s, "keyspace_misses"),
+			ErrorReplies:        p.int(s, "total_error_replies"),
+			LazyfreedObjects:    p.int(s, "lazyfreed_objects"),
+		}
+	}
+	if s := info.Sections["replication"]; s != nil {
+		info.Replication = ReplicationInfo{
+			Role:              s["role"],
+			ConnectedReplicas: p.int(s, "connected_slaves"),
+		}
+	}
+	if s := info.Sections["cpu"]; s != nil {
+		info.CPU = CPUInfo{
+			System: p.seconds(s, "used_cpu_sys"),
+			User:   p.seconds(s, "used_cpu_user"),
+		}
+	}
+	for db, value := range info.Sections["keyspace"] {
+		// Such as keys=2,expires=1,avg_ttl=0
+		attrs := make(map[string]string)
+		for _, attr := range strings.Split(value, ",") {
+			name, value, _ := strings.Cut(attr, "=")
+			attrs[name] = value
+		}
+		info.Keyspace[db] = KeyspaceInfo{
+			Keys:    p.int(attrs, "keys"),
+			Expires: p.int(attrs, "expires"),
+			AvgTTL:  time.Duration(p.int(attrs, "avg_ttl")) * time.Millisecond,
+		}
+	}
+
+	if p.err != nil {
+		return nil, p.err
+	}
+	return info, nil
+}
+
+// infoParser converts INFO fields, keeping the first error
+type infoParser struct {
+	err error
+}
+
+// int returns the integer field name, or 0 when it is missing
+func (p *infoParser) int(fields map[string]string, name string) int64 {
+	value, ok := fields[name]
+	if !ok {
+		return 0
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil && p.err == nil {
+		p.err = fmt.Errorf("invalid INFO field %s: %q", name, value)
+	}
+	return n
+}
+
+// seconds returns the field name, a number of seconds, as a duration
+func (p *infoParser) seconds(fields map[string]string, name string) time.Duration {
+	value, ok := fields[name]
+	if !ok {
+		return 0
+	}
+	secs, err := strconv.ParseFloat(value, 64)
+	if err != nil && p.err == nil {
+		p.err = fmt.Errorf("invalid INFO field %s: %q", name, value)
+	}
+	return time.Duration(secs * float64(time.Second))
+}
@@ -0,0 +1,79 @@
+package client
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Info(t *testing.T) {
+	startServer(t, "localhost:16403")
+
+	c, err := New("localhost:16403")
+	require.NoError(t, err)
+	defer c.Close()
+
+	require.NoError(t, c.Set("name", "alice"))
+	require.NoError(t, c.SetEx("session", "abc", 100))
+	_, err = c.Get("name")
+	require.NoError(t, err)
+	_, err = c.Get("missing")
+	require.NoError(t, err)
+
+	info, err := c.Info()
+	require.NoError(t, err)
+	assert.Equal(t, int64(os.Getpid()), info.Server.ProcessID)
+	assert.Equal(t, int64(16403), info.Server.TCPPort)
+	assert.Len(t, info.Server.RunID, 40)
+	assert.Equal(t, int64(1), info.Clients.Connected)
+	assert.Greater(t, info.Memory.Used, int64(0))
+	assert.Equal(t, "noeviction", info.Memory.MaxMemoryPolicy)
+	assert.Equal(t, int64(0), info.Memory.LazyfreePending)
+	assert.Equal(t, "master", info.Replication.Role)
+	assert.Equal(t, int64(4), info.Stats.TotalCommands)
+	assert.Equal(t, int64(1), info.Stats.KeyspaceHits)
+	assert.Equal(t, int64(1), info.Stats.KeyspaceMisses)
+	assert.Greater(t, info.CPU.User+info.CPU.System, time.Duration(0))
+	assert.Equal(t, KeyspaceInfo{Keys: 2, Expires: 1}, info.Keyspace["db0"])
+	assert.NotContains(t, info.Sections, "commandstats")
+
+	info, err = c.Info("commandstats")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"commandstats"}, keys(info.Sections))
+	assert.Contains(t, info.Sections["commandstats"], "cmdstat_set")
+	assert.Empty(t, info.Server.Version)
+
+	info, err = c.Info("all")
+	require.NoError(t, err)
+	assert.Contains(t, info.Sections, "latencystats")
+	assert.Contains(t, info.Sections, "persistence")
+	assert.True(t, info.Persistence.LastSave.IsZero())
+}
+
+func TestParseInfo(t *testing.T) {
+	info, err := ParseInfo("# Server\r\nredis_version:7.2.4\r\nuptime_in_seconds:90\r\n\r\n" +
+		"# CPU\r\nused_cpu_sys:1.500000\r\n\r\n# Keyspace\r\ndb0:keys=5,expires=2,avg_ttl=3000\r\ndb3:keys=1,expires=0,avg_ttl=0\r\n")
+	require.NoError(t, err)
+	assert.Equal(t, "7.2.4", info.Server.Version)
+	assert.Equal(t, 90*time.Second, info.Server.Uptime)
+	assert.Equal(t, 1500*time.Millisecond, info.CPU.System)
+	assert.Equal(t, KeyspaceInfo{Keys: 5, Expires: 2, AvgTTL: 3 * time.Second}, info.Keyspace["db0"])
+	assert.Equal(t, KeyspaceInfo{Keys: 1}, info.Keyspace["db3"])
+	assert.Equal(t, "7.2.4", info.Sections["server"]["redis_version"])
+
+	_, err = ParseInfo("# Stats\r\nkeyspace_hits:many\r\n")
+	assert.ErrorContains(t, err, "keyspace_hits")
+	_, err = ParseInfo("no section\r\n")
+	assert.Error(t, err)
+}
+
+func keys(m map[string]map[string]string) []string {
+	var names []string
+	for name := range m {
+		names = append(names, name)
+	}
+	return names
+}